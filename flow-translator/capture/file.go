@@ -0,0 +1,148 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    file.go
+ * details: Reads and writes the capture files, one vFlow JSON message per
+ *          line, gzip compressed when the file name ends with .gz
+ *
+ */
+package capture
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+const maxLineSize = 16 * 1024 * 1024
+
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// Reader reads the messages of a capture file
+type Reader struct {
+	f       *os.File
+	gz      *gzip.Reader
+	scanner *bufio.Scanner
+}
+
+// NewReader opens the capture file, gzip compression is detected from the
+// content of the file
+func NewReader(path string) (*Reader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	r := &Reader{f: f}
+	br := bufio.NewReader(f)
+	var src io.Reader = br
+	if magic, _ := br.Peek(len(gzipMagic)); bytes.Equal(magic, gzipMagic) {
+		if r.gz, err = gzip.NewReader(br); err != nil {
+			f.Close()
+			return nil, err
+		}
+		src = r.gz
+	}
+	r.scanner = bufio.NewScanner(src)
+	r.scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	return r, nil
+}
+
+// Next returns the next message, io.EOF at the end of the file. The empty
+// lines are skipped
+func (r *Reader) Next() ([]byte, error) {
+	for r.scanner.Scan() {
+		line := bytes.TrimSpace(r.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		msg := make([]byte, len(line))
+		copy(msg, line)
+		return msg, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+// Close closes the capture file
+func (r *Reader) Close() error {
+	if r.gz != nil {
+		r.gz.Close()
+	}
+	return r.f.Close()
+}
+
+// Writer writes the messages to a capture file
+type Writer struct {
+	f  *os.File
+	gz *gzip.Writer
+	w  *bufio.Writer
+}
+
+// NewWriter creates the capture file, it is gzip compressed if the name ends
+// with .gz
+func NewWriter(path string) (*Writer, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	w := &Writer{f: f}
+	if strings.HasSuffix(path, ".gz") {
+		w.gz = gzip.NewWriter(f)
+		w.w = bufio.NewWriter(w.gz)
+	} else {
+		w.w = bufio.NewWriter(f)
+	}
+	return w, nil
+}
+
+// Write writes one message, the message must not contain new lines
+func (w *Writer) Write(msg []byte) error {
+	if _, err := w.w.Write(bytes.TrimSpace(msg)); err != nil {
+		return err
+	}
+	return w.w.WriteByte('\n')
+}
+
+// Close flushes and closes the capture file
+func (w *Writer) Close() error {
+	if err := w.w.Flush(); err != nil {
+		w.f.Close()
+		return err
+	}
+	if w.gz != nil {
+		if err := w.gz.Close(); err != nil {
+			w.f.Close()
+			return err
+		}
+	}
+	return w.f.Close()
+}
+
+// messageTime returns the time the message was received by vFlow, the
+// Timestamp in microseconds of the IPFIX/NetFlow messages or of the header
+// of the sFlow messages. The zero time is returned if there is none
+func messageTime(msg []byte) time.Time {
+	var m struct {
+		Timestamp json.Number `json:"Timestamp"`
+		Header    struct {
+			Timestamp json.Number `json:"Timestamp"`
+		} `json:"Header"`
+	}
+	if err := json.Unmarshal(msg, &m); err != nil {
+		return time.Time{}
+	}
+	ts, err := m.Timestamp.Int64()
+	if err != nil {
+		if ts, err = m.Header.Timestamp.Int64(); err != nil {
+			return time.Time{}
+		}
+	}
+	return time.Unix(0, ts*int64(time.Microsecond))
+}
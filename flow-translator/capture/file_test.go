@@ -0,0 +1,94 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    file_test.go
+ * details: Deals with the Unit Test cases for the capture files
+ *
+ */
+package capture
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCaptureFileRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "capture")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	msgs := []string{`{"AgentID":"10.1.1.1","Timestamp":1}`,
+		`{"AgentID":"10.1.1.2","Timestamp":2}`}
+	for _, name := range []string{"capture.jsonl", "capture.jsonl.gz"} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name)
+			w, err := NewWriter(path)
+			if err != nil {
+				t.Fatalf("NewWriter failed: %v", err)
+			}
+			for _, msg := range msgs {
+				if err := w.Write([]byte(msg + "\n")); err != nil {
+					t.Fatalf("Write failed: %v", err)
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatalf("Close failed: %v", err)
+			}
+
+			r, err := NewReader(path)
+			if err != nil {
+				t.Fatalf("NewReader failed: %v", err)
+			}
+			defer r.Close()
+			for _, expected := range msgs {
+				msg, err := r.Next()
+				if err != nil || string(msg) != expected {
+					t.Errorf("expected '%s', got '%s' %v", expected, msg, err)
+				}
+			}
+			if _, err := r.Next(); err != io.EOF {
+				t.Errorf("expected EOF, got %v", err)
+			}
+		})
+	}
+}
+
+func TestMessageTime(t *testing.T) {
+	tests := []struct {
+		name     string
+		msg      string
+		expected time.Time
+	}{
+		{
+			name:     "IPFIX message",
+			msg:      `{"AgentID":"10.1.1.1","Timestamp":1522035620663678}`,
+			expected: time.Unix(1522035620, 663678000),
+		},
+		{
+			name:     "sFlow message",
+			msg:      `{"Header":{"Timestamp":1522035620000001}}`,
+			expected: time.Unix(1522035620, 1000),
+		},
+		{
+			name: "No timestamp",
+			msg:  `{"AgentID":"10.1.1.1"}`,
+		},
+		{
+			name: "Invalid message",
+			msg:  `invalid`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := messageTime([]byte(tt.msg)); !got.Equal(tt.expected) {
+				t.Errorf("%s expected %v, got %v", tt.name, tt.expected, got)
+			}
+		})
+	}
+}
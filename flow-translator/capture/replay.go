@@ -0,0 +1,104 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    replay.go
+ * details: Replays a capture file through the message handlers, at the
+ *          original speed of the messages or accelerated
+ *
+ */
+package capture
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+
+	"github.com/Juniper/collector/flow-translator/metrics"
+	msghandler "github.com/Juniper/collector/flow-translator/msg-handler"
+	opts "github.com/Juniper/collector/flow-translator/options"
+)
+
+// pacer delays the messages as per their time, speed is the acceleration
+// factor, 0 to not delay the messages at all
+type pacer struct {
+	speed float64
+	first time.Time
+	start time.Time
+	sleep func(time.Duration)
+	now   func() time.Time
+}
+
+func newPacer(speed float64) *pacer {
+	return &pacer{speed: speed, sleep: time.Sleep, now: time.Now}
+}
+
+// wait waits until the message of the given time is due
+func (p *pacer) wait(t time.Time) {
+	if p.speed <= 0 || t.IsZero() {
+		return
+	}
+	if p.first.IsZero() {
+		p.first, p.start = t, p.now()
+		return
+	}
+	due := p.start.Add(time.Duration(float64(t.Sub(p.first)) / p.speed))
+	if d := due.Sub(p.now()); d > 0 {
+		p.sleep(d)
+	}
+}
+
+// ReplayFile hands the messages of the capture file to the message handlers
+// as if they were received on the given topic, and returns once they are all
+// handled
+func ReplayFile(path string, topic string, speed float64) error {
+	r, err := NewReader(path)
+	if err != nil {
+		return fmt.Errorf("Failed to open %s: %v", path, err)
+	}
+	defer r.Close()
+
+	signalCh := make(chan os.Signal, 1)
+	signal.Notify(signalCh, os.Interrupt)
+
+	opts.Logger.Printf("Replaying %s as %s at speed %v", path, topic, speed)
+	metrics.StartServer()
+	msghandler.StartMsgHandlers()
+
+	var wg sync.WaitGroup
+	p := newPacer(speed)
+	replayed := 0
+	for {
+		select {
+		case sig := <-signalCh:
+			opts.Logger.Println("Interrupt is detected", sig)
+			return nil
+		default:
+		}
+		msg, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("Failed to read %s: %v", path, err)
+		}
+		p.wait(messageTime(msg))
+		wg.Add(1)
+		err = msghandler.SendToInChannels(&msghandler.Message{
+			Topic:     topic,
+			Value:     msg,
+			Partition: -1,
+			Offset:    -1,
+			OnAck:     wg.Done,
+		})
+		if err != nil {
+			return err
+		}
+		replayed++
+	}
+	wg.Wait()
+	opts.Logger.Printf("Replayed %d messages of %s", replayed, path)
+	return nil
+}
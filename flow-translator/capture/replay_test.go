@@ -0,0 +1,48 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    replay_test.go
+ * details: Deals with the Unit Test cases for the pacing of the replay
+ *
+ */
+package capture
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPacer(t *testing.T) {
+	tests := []struct {
+		name   string
+		speed  float64
+		sleeps []time.Duration
+	}{
+		{name: "Original speed", speed: 1,
+			sleeps: []time.Duration{2 * time.Second, 4 * time.Second}},
+		{name: "Accelerated", speed: 4,
+			sleeps: []time.Duration{500 * time.Millisecond, time.Second}},
+		{name: "No delay", speed: 0},
+	}
+	base := time.Unix(1522035620, 0)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sleeps []time.Duration
+			p := newPacer(tt.speed)
+			p.now = func() time.Time { return base }
+			p.sleep = func(d time.Duration) { sleeps = append(sleeps, d) }
+			p.wait(base)
+			p.wait(base.Add(2 * time.Second))
+			p.wait(time.Time{})
+			p.wait(base.Add(4 * time.Second))
+			if len(sleeps) != len(tt.sleeps) {
+				t.Fatalf("%s expected sleeps %v, got %v", tt.name, tt.sleeps, sleeps)
+			}
+			for i := range sleeps {
+				if sleeps[i] != tt.sleeps[i] {
+					t.Errorf("%s expected sleeps %v, got %v", tt.name, tt.sleeps, sleeps)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    filewatch.go
+ * details: Reloads the data loaded from a set of files when the modification
+ *          time or size of any of them changes
+ *
+ */
+package filewatch
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// stamp is the modification time and size of a file when it was loaded
+type stamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// Files is a set of files loaded together
+type Files struct {
+	paths  []string
+	mu     sync.Mutex
+	stamps []stamp
+}
+
+// New returns the set of the files, none of them is loaded yet
+func New(paths ...string) *Files {
+	return &Files{paths: paths}
+}
+
+// Reload calls load if the modification time or size of any of the files
+// changed since the last successful load, and returns whether it did. The
+// files are checked again on the next call when load fails
+func (f *Files) Reload(load func() error) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var stamps []stamp
+	for _, path := range f.paths {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return false, err
+		}
+		stamps = append(stamps, stamp{fi.ModTime(), fi.Size()})
+	}
+	unchanged := len(f.stamps) == len(stamps)
+	for i := range f.stamps {
+		unchanged = unchanged && f.stamps[i].modTime.Equal(stamps[i].modTime) &&
+			f.stamps[i].size == stamps[i].size
+	}
+	if unchanged {
+		return false, nil
+	}
+	if err := load(); err != nil {
+		return false, err
+	}
+	f.stamps = stamps
+	return true, nil
+}
+
+// Watch calls Reload every interval, and reloaded with the result of the
+// reloads which did load the files or failed. What was loaded is expected
+// to be kept as it is while the files can not be loaded, e.g. while they
+// are being written
+func (f *Files) Watch(interval time.Duration, load func() error,
+	reloaded func(err error)) {
+	go func() {
+		for range time.Tick(interval) {
+			if ok, err := f.Reload(load); ok || err != nil {
+				reloaded(err)
+			}
+		}
+	}()
+}
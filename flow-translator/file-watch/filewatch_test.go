@@ -0,0 +1,73 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    filewatch_test.go
+ * details: Deals with the Unit Test cases for the reload of the files
+ *
+ */
+package filewatch
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "filewatch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	first, second := filepath.Join(dir, "first"), filepath.Join(dir, "second")
+	ioutil.WriteFile(first, []byte("1"), 0644)
+	ioutil.WriteFile(second, []byte("2"), 0644)
+
+	loads := 0
+	var loadErr error
+	load := func() error {
+		loads++
+		return loadErr
+	}
+	f := New(first, second)
+	if reloaded, err := f.Reload(load); !reloaded || err != nil || loads != 1 {
+		t.Fatalf("first Reload expected a load, got %v %v %d", reloaded, err, loads)
+	}
+	if reloaded, err := f.Reload(load); reloaded || err != nil || loads != 1 {
+		t.Errorf("Reload of unchanged files expected none, got %v %v %d",
+			reloaded, err, loads)
+	}
+
+	// a failed load is tried again on the next call
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(second, later, later)
+	loadErr = fmt.Errorf("Invalid file")
+	if reloaded, err := f.Reload(load); reloaded || err == nil || loads != 2 {
+		t.Errorf("Reload of invalid file expected an error, got %v %v %d",
+			reloaded, err, loads)
+	}
+	loadErr = nil
+	if reloaded, err := f.Reload(load); !reloaded || err != nil || loads != 3 {
+		t.Errorf("Reload of changed file expected a load, got %v %v %d",
+			reloaded, err, loads)
+	}
+
+	// a size change with the same modification time
+	ioutil.WriteFile(first, []byte("11"), 0644)
+	os.Chtimes(first, later, later)
+	f.Reload(load)
+	loads = 0
+	ioutil.WriteFile(first, []byte("111"), 0644)
+	os.Chtimes(first, later, later)
+	if reloaded, _ := f.Reload(load); !reloaded || loads != 1 {
+		t.Errorf("Reload of resized file expected a load, got %v %d", reloaded, loads)
+	}
+
+	os.Remove(second)
+	if reloaded, err := f.Reload(load); reloaded || err == nil {
+		t.Errorf("Reload of missing file expected an error, got %v %v", reloaded, err)
+	}
+}
@@ -8,21 +8,147 @@
 package main
 
 import (
+	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/Juniper/collector/flow-translator/capture"
+	"github.com/Juniper/collector/flow-translator/ipfix"
 	kc "github.com/Juniper/collector/flow-translator/kafka-consumer"
 	opts "github.com/Juniper/collector/flow-translator/options"
+	ul "github.com/Juniper/collector/flow-translator/udp-listener"
 	"github.com/urfave/cli"
 )
 
 var version string
 
-func handleKafkaConsumer(c *cli.Context) error {
+// parseArgs parses the config file and loads the IPFIX element definitions
+// it refers to
+func parseArgs(c *cli.Context) {
 	opts.ParseArgs(c)
+	for _, path := range []string{opts.IPFIXElementsFile,
+		opts.IPFIXEntElementsFile} {
+		if path == "" {
+			continue
+		}
+		n, err := ipfix.LoadElementsFile(path)
+		if err != nil {
+			opts.Logger.Fatalf("IPFIX elements file %s load error: %v", path, err)
+		}
+		opts.Logger.Printf("Loaded %d IPFIX elements of %s", n, path)
+	}
+}
+
+func handleKafkaConsumer(c *cli.Context) error {
+	parseArgs(c)
 	kc.KafkaConsumer()
 	return nil
 }
 
+func handleIPFIXListener(c *cli.Context) error {
+	parseArgs(c)
+	ul.IPFIXListener()
+	return nil
+}
+
+func handleNetflow9Listener(c *cli.Context) error {
+	parseArgs(c)
+	ul.Netflow9Listener()
+	return nil
+}
+
+func handleNetflow5Listener(c *cli.Context) error {
+	parseArgs(c)
+	ul.Netflow5Listener()
+	return nil
+}
+
+func handleSFlowListener(c *cli.Context) error {
+	parseArgs(c)
+	ul.SFlowListener()
+	return nil
+}
+
+func handleDLQRedrive(c *cli.Context) error {
+	parseArgs(c)
+	return kc.DLQRedrive()
+}
+
+func handleReplay(c *cli.Context) error {
+	parseArgs(c)
+	from, err := time.Parse(time.RFC3339, c.String("from"))
+	if err != nil {
+		return fmt.Errorf("Invalid --from time: %v", err)
+	}
+	to := time.Now()
+	if c.String("to") != "" {
+		if to, err = time.Parse(time.RFC3339, c.String("to")); err != nil {
+			return fmt.Errorf("Invalid --to time: %v", err)
+		}
+	}
+	return kc.Replay(from, to, c.String("target"))
+}
+
+func handleReplayFile(c *cli.Context) error {
+	parseArgs(c)
+	if c.String("input") == "" {
+		return fmt.Errorf("No --input capture file")
+	}
+	// the kafka message handler and the dead letter topic publish with the
+	// producers of the kafka consumer, which imports the capture package
+	kc.StartProducers()
+	return capture.ReplayFile(c.String("input"), c.String("topic"),
+		c.Float64("speed"))
+}
+
+func handleCapture(c *cli.Context) error {
+	parseArgs(c)
+	if c.String("output") == "" {
+		return fmt.Errorf("No --output capture file")
+	}
+	return kc.Capture(c.String("output"), c.String("topic"), c.Int("count"),
+		c.Duration("duration"))
+}
+
+// pcapPorts maps the collector ports of the pcap command flags to the
+// decoder names, a port can only be bound to one decoder
+func pcapPorts(c *cli.Context) (map[uint16]string, error) {
+	ports := map[uint16]string{}
+	for _, dec := range []string{opts.DecoderIPFIX, opts.DecoderNetflow9,
+		opts.DecoderNetflow5, opts.DecoderSFlow} {
+		flag := dec + "-ports"
+		for _, s := range strings.Split(c.String(flag), ",") {
+			if s = strings.TrimSpace(s); s == "" {
+				continue
+			}
+			port, err := strconv.ParseUint(s, 10, 16)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid --%s port %s", flag, s)
+			}
+			if other, ok := ports[uint16(port)]; ok {
+				return nil, fmt.Errorf("Port %d bound to both %s and %s",
+					port, other, dec)
+			}
+			ports[uint16(port)] = dec
+		}
+	}
+	return ports, nil
+}
+
+func handlePcap(c *cli.Context) error {
+	parseArgs(c)
+	if c.String("input") == "" {
+		return fmt.Errorf("No --input pcap file")
+	}
+	ports, err := pcapPorts(c)
+	if err != nil {
+		return err
+	}
+	return ul.PcapFile(c.String("input"), ports, c.Bool("stdout"))
+}
+
 func main() {
 	app := cli.NewApp()
 	app.Name = "Kafka Consumer CLI"
@@ -37,6 +163,119 @@ func main() {
 			},
 			Action: handleKafkaConsumer,
 		},
+		{
+			Name:  "ipfix-listener",
+			Usage: "IPFIX Collector, receives IPFIX directly from the exporters",
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: opts.MHConfigFileStr, Value: opts.MHConfigFile,
+					Usage: "The config file"},
+			},
+			Action: handleIPFIXListener,
+		},
+		{
+			Name:  "netflow9-listener",
+			Usage: "NetFlow v9 Collector, receives NetFlow v9 directly from the exporters",
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: opts.MHConfigFileStr, Value: opts.MHConfigFile,
+					Usage: "The config file"},
+			},
+			Action: handleNetflow9Listener,
+		},
+		{
+			Name:  "netflow5-listener",
+			Usage: "NetFlow v5 Collector, receives NetFlow v5 directly from the exporters",
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: opts.MHConfigFileStr, Value: opts.MHConfigFile,
+					Usage: "The config file"},
+			},
+			Action: handleNetflow5Listener,
+		},
+		{
+			Name:  "sflow-listener",
+			Usage: "sFlow Collector, receives sFlow v5 directly from the agents",
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: opts.MHConfigFileStr, Value: opts.MHConfigFile,
+					Usage: "The config file"},
+			},
+			Action: handleSFlowListener,
+		},
+		{
+			Name:  "dlq-redrive",
+			Usage: "Re-drives the messages of the dead letter topic through the message handlers",
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: opts.MHConfigFileStr, Value: opts.MHConfigFile,
+					Usage: "The config file"},
+			},
+			Action: handleDLQRedrive,
+		},
+		{
+			Name:  "replay",
+			Usage: "Replays the messages of the Kafka topics published within a time window",
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: opts.MHConfigFileStr, Value: opts.MHConfigFile,
+					Usage: "The config file"},
+				cli.StringFlag{Name: "from",
+					Usage: "Start of the window, RFC 3339 time"},
+				cli.StringFlag{Name: "to",
+					Usage: "End of the window, RFC 3339 time (Default: now)"},
+				cli.StringFlag{Name: "target",
+					Usage: "Message handler to replay to, data-manager, query-api or kafka (Default: the enabled ones)"},
+			},
+			Action: handleReplay,
+		},
+		{
+			Name:  "replay-file",
+			Usage: "Replays the vFlow JSON messages of a capture file through the message handlers",
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: opts.MHConfigFileStr, Value: opts.MHConfigFile,
+					Usage: "The config file"},
+				cli.StringFlag{Name: "input",
+					Usage: "The capture file, one message per line, gzip compressed or not"},
+				cli.StringFlag{Name: "topic", Value: opts.KafkaTopicVFlowIPFIX,
+					Usage: "The topic the messages are handled as received on"},
+				cli.Float64Flag{Name: "speed", Value: 1,
+					Usage: "Replay speed, 1 for the original speed of the messages, 0 for no delay"},
+			},
+			Action: handleReplayFile,
+		},
+		{
+			Name:  "capture",
+			Usage: "Records the messages of a Kafka topic into a capture file",
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: opts.MHConfigFileStr, Value: opts.MHConfigFile,
+					Usage: "The config file"},
+				cli.StringFlag{Name: "output",
+					Usage: "The capture file, gzip compressed if ending with .gz"},
+				cli.StringFlag{Name: "topic", Value: opts.KafkaTopicVFlowIPFIX,
+					Usage: "The topic to capture"},
+				cli.IntFlag{Name: "count",
+					Usage: "Number of messages to capture (Default: no limit)"},
+				cli.DurationFlag{Name: "duration",
+					Usage: "How long to capture (Default: no limit)"},
+			},
+			Action: handleCapture,
+		},
+		{
+			Name:  "pcap",
+			Usage: "Decodes the flow packets of a pcap/pcapng file",
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: opts.MHConfigFileStr, Value: opts.MHConfigFile,
+					Usage: "The config file"},
+				cli.StringFlag{Name: "input",
+					Usage: "The pcap or pcapng file"},
+				cli.BoolFlag{Name: "stdout",
+					Usage: "Write the decoded messages to stdout instead of the sinks"},
+				cli.StringFlag{Name: "ipfix-ports", Value: "4739",
+					Usage: "Comma separated UDP ports of the IPFIX packets"},
+				cli.StringFlag{Name: "netflow9-ports",
+					Usage: "Comma separated UDP ports of the NetFlow v9 packets"},
+				cli.StringFlag{Name: "netflow5-ports",
+					Usage: "Comma separated UDP ports of the NetFlow v5 packets"},
+				cli.StringFlag{Name: "sflow-ports", Value: "6343",
+					Usage: "Comma separated UDP ports of the sFlow packets"},
+			},
+			Action: handlePcap,
+		},
 	}
 	err := app.Run(os.Args)
 	if err != nil {
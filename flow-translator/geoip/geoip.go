@@ -0,0 +1,173 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    geoip.go
+ * details: Looks up the country, city and autonomous system of the
+ *          addresses in the GeoLite2/GeoIP2 databases, reloaded when the
+ *          files change on disk
+ *
+ */
+package geoip
+
+import (
+	"io/ioutil"
+	"net"
+	"sync"
+	"time"
+
+	filewatch "github.com/Juniper/collector/flow-translator/file-watch"
+	opts "github.com/Juniper/collector/flow-translator/options"
+)
+
+// maxCachedLocations bounds the locations cached per database, the cache
+// is emptied when full
+const maxCachedLocations = 1 << 16
+
+// Location is the country (ISO code), city (English name) and autonomous
+// system of an address, as found in the City/Country and ASN databases
+type Location struct {
+	Country string
+	City    string
+	ASN     uint32
+	ASOrg   string
+}
+
+// merge sets the fields of loc not set yet from another location
+func (loc *Location) merge(from Location) {
+	if loc.Country == "" {
+		loc.Country = from.Country
+	}
+	if loc.City == "" {
+		loc.City = from.City
+	}
+	if loc.ASN == 0 {
+		loc.ASN = from.ASN
+		loc.ASOrg = from.ASOrg
+	}
+}
+
+// loadedDatabase is a version of the database file, with the locations
+// already decoded from its data section
+type loadedDatabase struct {
+	reader *Reader
+	mu     sync.Mutex
+	cache  map[uint]Location
+}
+
+// Database is a MaxMind DB file
+type Database struct {
+	path string
+	file *filewatch.Files
+	mu   sync.RWMutex
+	db   *loadedDatabase
+}
+
+// Open reads a MaxMind DB file
+func Open(path string) (*Database, error) {
+	db := &Database{path: path, file: filewatch.New(path)}
+	if _, err := db.reload(); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// Path returns the path of the database file
+func (db *Database) Path() string {
+	return db.path
+}
+
+// Metadata returns the description of the database file
+func (db *Database) Metadata() Metadata {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return db.db.reader.Metadata()
+}
+
+// reload reads the file again if its modification time or size changed
+// since it was read, and returns whether it did
+func (db *Database) reload() (bool, error) {
+	return db.file.Reload(db.load)
+}
+
+func (db *Database) load() error {
+	b, err := ioutil.ReadFile(db.path)
+	if err != nil {
+		return err
+	}
+	r, err := NewReader(b)
+	if err != nil {
+		return err
+	}
+	db.mu.Lock()
+	db.db = &loadedDatabase{reader: r, cache: map[uint]Location{}}
+	db.mu.Unlock()
+	return nil
+}
+
+// Watch checks the file every interval and reloads it when it changed
+func (db *Database) Watch(interval time.Duration) {
+	db.file.Watch(interval, db.load, func(err error) {
+		if err != nil {
+			opts.Logger.Printf("GeoIP database %s reload error: %v", db.path, err)
+		} else {
+			opts.Logger.Printf("GeoIP database %s reloaded (%s)", db.path,
+				db.Metadata().DatabaseType)
+		}
+	})
+}
+
+// Lookup sets the fields of loc not set yet from the location of an
+// address, it returns whether the address is in the database
+func (db *Database) Lookup(ip net.IP, loc *Location) (bool, error) {
+	db.mu.RLock()
+	cur := db.db
+	db.mu.RUnlock()
+	offset, ok, err := cur.reader.lookupOffset(ip)
+	if !ok || err != nil {
+		return false, err
+	}
+	cur.mu.Lock()
+	found, cached := cur.cache[offset]
+	cur.mu.Unlock()
+	if !cached {
+		v, _, err := cur.reader.data.decode(offset, 0)
+		if err != nil {
+			return false, err
+		}
+		found = recordLocation(v)
+		cur.mu.Lock()
+		if len(cur.cache) >= maxCachedLocations {
+			cur.cache = map[uint]Location{}
+		}
+		cur.cache[offset] = found
+		cur.mu.Unlock()
+	}
+	loc.merge(found)
+	return true, nil
+}
+
+// recordLocation returns the location of a record of the City, Country or
+// ASN databases
+func recordLocation(v interface{}) Location {
+	var loc Location
+	loc.Country = stringValue(field(v, "country", "iso_code"))
+	if loc.Country == "" {
+		loc.Country = stringValue(field(v, "registered_country", "iso_code"))
+	}
+	loc.City = stringValue(field(v, "city", "names", "en"))
+	loc.ASN = uint32(uintValue(field(v, "autonomous_system_number")))
+	loc.ASOrg = stringValue(field(v, "autonomous_system_organization"))
+	return loc
+}
+
+// field returns the value at a path of nested maps, nil if not there
+func field(v interface{}, path ...string) interface{} {
+	for _, key := range path {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = m[key]
+	}
+	return v
+}
@@ -0,0 +1,303 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    geoip_test.go
+ * details: Deals with the Unit Test cases for the MaxMind DB reader and the
+ *          GeoIP databases
+ *
+ */
+package geoip
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// testPointer is a pointer to an offset of the data section
+type testPointer uint
+
+type testNetwork struct {
+	cidr string
+	data interface{}
+}
+
+// encode appends a value of the data section to b
+func encode(b []byte, v interface{}) []byte {
+	ctrl := func(typ int, size int) {
+		c := byte(size)
+		if size >= 29 {
+			c = 29
+		}
+		if typ < 8 {
+			c |= byte(typ) << 5
+		}
+		b = append(b, c)
+		if typ >= 8 {
+			b = append(b, byte(typ-7))
+		}
+		if size >= 29 {
+			b = append(b, byte(size-29))
+		}
+	}
+	switch v := v.(type) {
+	case string:
+		ctrl(typeString, len(v))
+		b = append(b, v...)
+	case uint64:
+		var u []byte
+		for ; v > 0; v >>= 8 {
+			u = append([]byte{byte(v)}, u...)
+		}
+		ctrl(typeUint64, len(u))
+		b = append(b, u...)
+	case bool:
+		c := 0
+		if v {
+			c = 1
+		}
+		ctrl(typeBool, c)
+	case []interface{}:
+		ctrl(typeArray, len(v))
+		for _, e := range v {
+			b = encode(b, e)
+		}
+	case map[string]interface{}:
+		// sorted for the pointers to the values to be known
+		var keys []string
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		ctrl(typeMap, len(v))
+		for _, k := range keys {
+			b = encode(encode(b, k), v[k])
+		}
+	case testPointer:
+		b = append(b, byte(typePointer<<5)|byte(v>>8)&0x7, byte(v))
+	}
+	return b
+}
+
+// buildDB returns a MaxMind DB file of the networks, which must not overlap
+func buildDB(ipVersion uint64, recordSize uint64, networks []testNetwork) []byte {
+	const empty = -1
+	nodes := [][2]int{{empty, empty}}
+	var data []byte
+	var offsets []int
+	for i, n := range networks {
+		offsets = append(offsets, len(data))
+		data = encode(data, n.data)
+		ip, ipnet, _ := net.ParseCIDR(n.cidr)
+		bits, _ := ipnet.Mask.Size()
+		addr := []byte(ip.To4())
+		if addr == nil {
+			addr = ip.To16()
+		} else if ipVersion == 6 {
+			addr = append(make([]byte, 12), addr...)
+			bits += 96
+		}
+		node := 0
+		for depth := 0; depth < bits; depth++ {
+			bit := int(addr[depth/8]>>(7-uint(depth%8))) & 1
+			if depth == bits-1 {
+				nodes[node][bit] = -2 - i
+				break
+			}
+			if nodes[node][bit] == empty {
+				nodes = append(nodes, [2]int{empty, empty})
+				nodes[node][bit] = len(nodes) - 1
+			}
+			node = nodes[node][bit]
+		}
+	}
+	count := len(nodes)
+	value := func(r int) uint {
+		switch {
+		case r == empty:
+			return uint(count)
+		case r < empty:
+			return uint(count + dataSectionSeparator + offsets[-2-r])
+		}
+		return uint(r)
+	}
+	var b []byte
+	for _, n := range nodes {
+		left, right := value(n[0]), value(n[1])
+		switch recordSize {
+		case 24:
+			b = append(b, byte(left>>16), byte(left>>8), byte(left),
+				byte(right>>16), byte(right>>8), byte(right))
+		case 28:
+			b = append(b, byte(left>>16), byte(left>>8), byte(left),
+				byte(left>>24)<<4|byte(right>>24)&0xf,
+				byte(right>>16), byte(right>>8), byte(right))
+		}
+	}
+	b = append(b, make([]byte, dataSectionSeparator)...)
+	b = append(b, data...)
+	b = append(b, metadataMarker...)
+	return encode(b, map[string]interface{}{
+		"binary_format_major_version": uint64(2),
+		"binary_format_minor_version": uint64(0),
+		"database_type":               "GeoLite2-Test",
+		"ip_version":                  ipVersion,
+		"node_count":                  uint64(count),
+		"record_size":                 recordSize,
+		"build_epoch":                 uint64(1522035620),
+		"languages":                   []interface{}{"en"},
+	})
+}
+
+var testCity = map[string]interface{}{
+	"city":    map[string]interface{}{"names": map[string]interface{}{"en": "Sunnyvale"}},
+	"country": map[string]interface{}{"iso_code": "US", "is_in_european_union": false},
+}
+
+// testCountryOffset is the offset of the country of testCity, the first
+// record of the data section
+var testCountryOffset = len(encode(encode(nil,
+	map[string]interface{}{"city": testCity["city"]}), "country"))
+
+var testNetworks = []testNetwork{
+	{"10.84.0.0/16", testCity},
+	{"192.0.2.0/24", map[string]interface{}{
+		"country": testPointer(testCountryOffset)}},
+	{"2001:db8::/32", map[string]interface{}{
+		"registered_country": map[string]interface{}{"iso_code": "NL"}}},
+}
+
+func TestReader(t *testing.T) {
+	for _, ipVersion := range []uint64{4, 6} {
+		for _, recordSize := range []uint64{24, 28} {
+			r, err := NewReader(buildDB(ipVersion, recordSize, testNetworks))
+			if err != nil {
+				t.Fatalf("NewReader v%d/%d failed: %v", ipVersion, recordSize, err)
+			}
+			VerifyMetadata(t, r.Metadata(), ipVersion, recordSize)
+			v, err := r.Lookup(net.ParseIP("10.84.30.160"))
+			if err != nil || !reflect.DeepEqual(v, testCity) {
+				t.Errorf("Lookup v%d/%d expected %v, got %v %v", ipVersion,
+					recordSize, testCity, v, err)
+			}
+			for _, addr := range []string{"10.85.0.1", "8.8.8.8", "2001:db9::1"} {
+				if v, err := r.Lookup(net.ParseIP(addr)); v != nil || err != nil {
+					t.Errorf("Lookup v%d/%d %s expected none, got %v %v",
+						ipVersion, recordSize, addr, v, err)
+				}
+			}
+		}
+	}
+}
+
+func VerifyMetadata(t *testing.T, meta Metadata, ipVersion uint64,
+	recordSize uint64) {
+	if meta.DatabaseType != "GeoLite2-Test" || meta.IPVersion != uint(ipVersion) ||
+		meta.RecordSize != uint(recordSize) || meta.BuildEpoch != 1522035620 {
+		t.Errorf("Unexpected metadata %+v", meta)
+	}
+}
+
+func TestInvalidReader(t *testing.T) {
+	db := buildDB(6, 24, testNetworks)
+	tests := []struct {
+		name string
+		b    []byte
+	}{
+		{"empty", nil},
+		{"no metadata", db[:len(db)-200]},
+		{"truncated metadata", db[:len(db)-10]},
+		{"truncated tree", db[len(db)-300:]},
+	}
+	for _, tt := range tests {
+		if _, err := NewReader(tt.b); err == nil {
+			t.Errorf("NewReader %s expected an error", tt.name)
+		}
+	}
+}
+
+func TestDatabase(t *testing.T) {
+	dir, err := ioutil.TempDir("", "geoip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "GeoLite2-City.mmdb")
+	if err := ioutil.WriteFile(path, buildDB(6, 28, testNetworks), 0644); err != nil {
+		t.Fatal(err)
+	}
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	tests := []struct {
+		addr     string
+		found    bool
+		expected Location
+	}{
+		{"10.84.30.160", true, Location{Country: "US", City: "Sunnyvale"}},
+		{"192.0.2.1", true, Location{Country: "US"}},
+		{"2001:db8::1", true, Location{Country: "NL"}},
+		{"8.8.8.8", false, Location{}},
+	}
+	for i := 0; i < 2; i++ { // from the cache the second time
+		for _, tt := range tests {
+			var loc Location
+			found, err := db.Lookup(net.ParseIP(tt.addr), &loc)
+			if err != nil || found != tt.found || loc != tt.expected {
+				t.Errorf("Lookup %s expected %v %+v, got %v %+v %v", tt.addr,
+					tt.found, tt.expected, found, loc, err)
+			}
+		}
+	}
+
+	// the fields already set are kept
+	loc := Location{City: "Amsterdam", ASN: 64496, ASOrg: "Example"}
+	db.Lookup(net.ParseIP("10.84.30.160"), &loc)
+	if expected := (Location{"US", "Amsterdam", 64496, "Example"}); loc != expected {
+		t.Errorf("Lookup merge expected %+v, got %+v", expected, loc)
+	}
+
+	// not reloaded while the file is unchanged or invalid
+	if reloaded, err := db.reload(); reloaded || err != nil {
+		t.Errorf("reload of unchanged file expected none, got %v %v", reloaded, err)
+	}
+	later := time.Now().Add(time.Minute)
+	asn := []testNetwork{{"10.84.0.0/16", map[string]interface{}{
+		"autonomous_system_number":       uint64(64496),
+		"autonomous_system_organization": "Example"}}}
+	if err := ioutil.WriteFile(path, []byte("partial"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(path, later, later)
+	if reloaded, err := db.reload(); reloaded || err == nil {
+		t.Errorf("reload of invalid file expected an error, got %v %v", reloaded, err)
+	}
+	loc = Location{}
+	if db.Lookup(net.ParseIP("10.84.30.160"), &loc); loc.City != "Sunnyvale" {
+		t.Errorf("Expected the previous database, got %+v", loc)
+	}
+
+	if err := ioutil.WriteFile(path, buildDB(4, 24, asn), 0644); err != nil {
+		t.Fatal(err)
+	}
+	later = later.Add(time.Minute)
+	os.Chtimes(path, later, later)
+	if reloaded, err := db.reload(); !reloaded || err != nil {
+		t.Fatalf("reload of changed file failed: %v %v", reloaded, err)
+	}
+	loc = Location{}
+	db.Lookup(net.ParseIP("10.84.30.160"), &loc)
+	if expected := (Location{ASN: 64496, ASOrg: "Example"}); loc != expected {
+		t.Errorf("Lookup after reload expected %+v, got %+v", expected, loc)
+	}
+
+	if _, err := Open(filepath.Join(dir, "missing.mmdb")); err == nil {
+		t.Errorf("Open of missing file expected an error")
+	}
+}
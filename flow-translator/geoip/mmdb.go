@@ -0,0 +1,342 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    mmdb.go
+ * details: Reader of the MaxMind DB files (GeoLite2/GeoIP2), the binary
+ *          search tree of the networks and the decoder of their records
+ *
+ */
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/big"
+	"net"
+)
+
+// metadataMarker starts the metadata section at the end of the file
+var metadataMarker = []byte("\xab\xcd\xefMaxMind.com")
+
+const (
+	// dataSectionSeparator is the size of the zeros between the search tree
+	// and the data section
+	dataSectionSeparator = 16
+	// maxDecodeDepth bounds the nesting of the decoded maps and arrays
+	maxDecodeDepth = 32
+)
+
+// data types of the data section
+const (
+	typeExtended = iota
+	typePointer
+	typeString
+	typeDouble
+	typeBytes
+	typeUint16
+	typeUint32
+	typeMap
+	typeInt32
+	typeUint64
+	typeUint128
+	typeArray
+	typeDataCache
+	typeEndMarker
+	typeBool
+	typeFloat
+)
+
+// Metadata is the description of a MaxMind DB file
+type Metadata struct {
+	DatabaseType string
+	IPVersion    uint
+	NodeCount    uint
+	RecordSize   uint
+	BuildEpoch   uint64
+}
+
+// Reader looks up the addresses of a MaxMind DB file held in memory
+type Reader struct {
+	meta      Metadata
+	tree      []byte
+	data      decoder
+	ipv4Start uint
+}
+
+// NewReader parses the metadata of a MaxMind DB file
+func NewReader(b []byte) (*Reader, error) {
+	i := bytes.LastIndex(b, metadataMarker)
+	if i < 0 {
+		return nil, fmt.Errorf("Invalid MaxMind DB, no metadata")
+	}
+	v, _, err := decoder(b[i+len(metadataMarker):]).decode(0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid MaxMind DB metadata: %v", err)
+	}
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("Invalid MaxMind DB metadata")
+	}
+	if major := uintValue(m["binary_format_major_version"]); major != 2 {
+		return nil, fmt.Errorf("Not supported MaxMind DB format version %d", major)
+	}
+	meta := Metadata{
+		DatabaseType: stringValue(m["database_type"]),
+		IPVersion:    uint(uintValue(m["ip_version"])),
+		NodeCount:    uint(uintValue(m["node_count"])),
+		RecordSize:   uint(uintValue(m["record_size"])),
+		BuildEpoch:   uintValue(m["build_epoch"]),
+	}
+	switch meta.RecordSize {
+	case 24, 28, 32:
+	default:
+		return nil, fmt.Errorf("Not supported MaxMind DB record size %d",
+			meta.RecordSize)
+	}
+	if meta.IPVersion != 4 && meta.IPVersion != 6 {
+		return nil, fmt.Errorf("Invalid MaxMind DB ip version %d", meta.IPVersion)
+	}
+	treeSize := meta.NodeCount * meta.RecordSize / 4
+	if treeSize+dataSectionSeparator > uint(i) {
+		return nil, fmt.Errorf("Invalid MaxMind DB node count %d", meta.NodeCount)
+	}
+	r := &Reader{
+		meta: meta,
+		tree: b[:treeSize],
+		data: decoder(b[treeSize+dataSectionSeparator : i]),
+	}
+	// the IPv4 addresses are at ::/96 of the IPv6 trees
+	if meta.IPVersion == 6 {
+		for n := 0; n < 96 && r.ipv4Start < meta.NodeCount; n++ {
+			r.ipv4Start = r.record(r.ipv4Start, 0)
+		}
+	}
+	return r, nil
+}
+
+// Metadata returns the description of the file
+func (r *Reader) Metadata() Metadata {
+	return r.meta
+}
+
+// record returns the left (bit 0) or right (bit 1) record of a node
+func (r *Reader) record(node uint, bit uint) uint {
+	b := r.tree
+	switch r.meta.RecordSize {
+	case 24:
+		o := node*6 + bit*3
+		return uint(b[o])<<16 | uint(b[o+1])<<8 | uint(b[o+2])
+	case 28:
+		o := node * 7
+		if bit == 0 {
+			return uint(b[o+3]&0xf0)<<20 | uint(b[o])<<16 | uint(b[o+1])<<8 |
+				uint(b[o+2])
+		}
+		return uint(b[o+3]&0x0f)<<24 | uint(b[o+4])<<16 | uint(b[o+5])<<8 |
+			uint(b[o+6])
+	}
+	o := node*8 + bit*4
+	return uint(binary.BigEndian.Uint32(b[o : o+4]))
+}
+
+// lookupOffset returns the offset of the record of an address in the data
+// section, ok is false when the address is not in the database
+func (r *Reader) lookupOffset(ip net.IP) (offset uint, ok bool, err error) {
+	bits := ip.To4()
+	node := uint(0)
+	if bits != nil {
+		if r.meta.IPVersion == 6 {
+			node = r.ipv4Start
+		}
+	} else {
+		if bits = ip.To16(); bits == nil {
+			return 0, false, fmt.Errorf("Invalid IP address %v", ip)
+		}
+		if r.meta.IPVersion == 4 {
+			return 0, false, nil
+		}
+	}
+	count := r.meta.NodeCount
+	for i := uint(0); i < uint(len(bits))*8 && node < count; i++ {
+		node = r.record(node, uint(bits[i/8]>>(7-i%8))&1)
+	}
+	switch {
+	case node == count:
+		return 0, false, nil
+	case node > count:
+		offset = node - count - dataSectionSeparator
+		if offset >= uint(len(r.data)) {
+			return 0, false, fmt.Errorf("Invalid MaxMind DB record pointer %d", node)
+		}
+		return offset, true, nil
+	}
+	return 0, false, fmt.Errorf("Invalid MaxMind DB search tree")
+}
+
+// Lookup returns the record of an address as decoded (maps, arrays,
+// strings, numbers), nil when the address is not in the database
+func (r *Reader) Lookup(ip net.IP) (interface{}, error) {
+	offset, ok, err := r.lookupOffset(ip)
+	if !ok || err != nil {
+		return nil, err
+	}
+	v, _, err := r.data.decode(offset, 0)
+	return v, err
+}
+
+// decoder decodes the values of the data section
+type decoder []byte
+
+var errTruncated = fmt.Errorf("Invalid MaxMind DB, truncated data")
+
+// decode returns the value at offset and the offset following it
+func (d decoder) decode(offset uint, depth int) (interface{}, uint, error) {
+	if depth > maxDecodeDepth {
+		return nil, 0, fmt.Errorf("Invalid MaxMind DB, data nested too deep")
+	}
+	if offset >= uint(len(d)) {
+		return nil, 0, errTruncated
+	}
+	ctrl := uint(d[offset])
+	offset++
+	typ := ctrl >> 5
+	if typ == typePointer {
+		ptr, next, err := d.pointer(ctrl, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		v, _, err := d.decode(ptr, depth+1)
+		return v, next, err
+	}
+	if typ == typeExtended {
+		if offset >= uint(len(d)) {
+			return nil, 0, errTruncated
+		}
+		typ = 7 + uint(d[offset])
+		offset++
+	}
+	size := ctrl & 0x1f
+	if size >= 29 {
+		n := size - 28
+		if offset+n > uint(len(d)) {
+			return nil, 0, errTruncated
+		}
+		v := uint(0)
+		for _, c := range d[offset : offset+n] {
+			v = v<<8 | uint(c)
+		}
+		size = []uint{29, 285, 65821}[n-1] + v
+		offset += n
+	}
+	switch typ {
+	case typeMap:
+		m := make(map[string]interface{}, size)
+		for i := uint(0); i < size; i++ {
+			k, next, err := d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				return nil, 0, fmt.Errorf("Invalid MaxMind DB map key %v", k)
+			}
+			v, next, err := d.decode(next, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			m[key] = v
+			offset = next
+		}
+		return m, offset, nil
+	case typeArray:
+		a := make([]interface{}, 0, size)
+		for i := uint(0); i < size; i++ {
+			v, next, err := d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			a = append(a, v)
+			offset = next
+		}
+		return a, offset, nil
+	case typeBool:
+		return size != 0, offset, nil
+	}
+	if offset+size > uint(len(d)) {
+		return nil, 0, errTruncated
+	}
+	b := d[offset : offset+size]
+	offset += size
+	switch typ {
+	case typeString:
+		return string(b), offset, nil
+	case typeBytes:
+		return append([]byte{}, b...), offset, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, fmt.Errorf("Invalid MaxMind DB double size %d", size)
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), offset, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, fmt.Errorf("Invalid MaxMind DB float size %d", size)
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))),
+			offset, nil
+	case typeUint16, typeUint32, typeUint64:
+		if size > 8 {
+			return nil, 0, fmt.Errorf("Invalid MaxMind DB integer size %d", size)
+		}
+		v := uint64(0)
+		for _, c := range b {
+			v = v<<8 | uint64(c)
+		}
+		return v, offset, nil
+	case typeInt32:
+		if size > 4 {
+			return nil, 0, fmt.Errorf("Invalid MaxMind DB integer size %d", size)
+		}
+		v := uint32(0)
+		for _, c := range b {
+			v = v<<8 | uint32(c)
+		}
+		return int64(int32(v)), offset, nil
+	case typeUint128:
+		return new(big.Int).SetBytes(b), offset, nil
+	}
+	return nil, 0, fmt.Errorf("Not supported MaxMind DB data type %d", typ)
+}
+
+// pointer returns the offset a pointer points to and the offset following
+// the pointer
+func (d decoder) pointer(ctrl uint, offset uint) (uint, uint, error) {
+	n := (ctrl>>3)&0x3 + 1
+	if offset+n > uint(len(d)) {
+		return 0, 0, errTruncated
+	}
+	v := uint(0)
+	for _, c := range d[offset : offset+n] {
+		v = v<<8 | uint(c)
+	}
+	switch n {
+	case 1:
+		v |= (ctrl & 0x7) << 8
+	case 2:
+		v = (ctrl&0x7)<<16 | v + 2048
+	case 3:
+		v = (ctrl&0x7)<<24 | v + 526336
+	}
+	return v, offset + n, nil
+}
+
+func uintValue(v interface{}) uint64 {
+	u, _ := v.(uint64)
+	return u
+}
+
+func stringValue(v interface{}) string {
+	s, _ := v.(string)
+	return s
+}
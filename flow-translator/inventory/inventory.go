@@ -0,0 +1,357 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    inventory.go
+ * details: Inventory of the interfaces of the exporters by ifIndex, loaded
+ *          from YAML, CSV or SNMP walk files and reloaded when they change
+ *
+ */
+package inventory
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	filewatch "github.com/Juniper/collector/flow-translator/file-watch"
+	"github.com/Juniper/collector/flow-translator/metrics"
+	opts "github.com/Juniper/collector/flow-translator/options"
+)
+
+var (
+	inventoryInterfaces = metrics.NewGauge(
+		"flow_translator_interface_inventory_interfaces",
+		"Interfaces of the interface inventory")
+	inventoryReloads = metrics.NewCounter(
+		"flow_translator_interface_inventory_reloads_total",
+		"Reloads of the interface inventory files, per result", "result")
+)
+
+// Interface is the name, description, speed in bits per second and role of
+// an interface
+type Interface struct {
+	Name        string `yaml:"name" json:"Name"`
+	Description string `yaml:"description" json:"Description"`
+	Speed       uint64 `yaml:"speed" json:"Speed"`
+	Role        string `yaml:"role" json:"Role"`
+}
+
+// merge sets the fields of the interface set in another
+func (i *Interface) merge(from Interface) {
+	if from.Name != "" {
+		i.Name = from.Name
+	}
+	if from.Description != "" {
+		i.Description = from.Description
+	}
+	if from.Speed != 0 {
+		i.Speed = from.Speed
+	}
+	if from.Role != "" {
+		i.Role = from.Role
+	}
+}
+
+// Inventory holds the interfaces by agent address and ifIndex
+type Inventory struct {
+	agents map[string]map[uint32]*Interface
+	size   int
+}
+
+// New returns an empty inventory
+func New() *Inventory {
+	return &Inventory{agents: map[string]map[uint32]*Interface{}}
+}
+
+// Add merges an interface into the inventory, the fields already set are
+// replaced by the ones set in the interface
+func (inv *Inventory) Add(agent string, ifIndex uint32, i Interface) {
+	if ip := net.ParseIP(agent); ip != nil {
+		agent = ip.String()
+	}
+	ifaces, ok := inv.agents[agent]
+	if !ok {
+		ifaces = map[uint32]*Interface{}
+		inv.agents[agent] = ifaces
+	}
+	cur, ok := ifaces[ifIndex]
+	if !ok {
+		cur = &Interface{}
+		ifaces[ifIndex] = cur
+		inv.size++
+	}
+	cur.merge(i)
+}
+
+// Lookup returns the interface of an agent
+func (inv *Inventory) Lookup(agent string, ifIndex uint32) (Interface, bool) {
+	i, ok := inv.agents[agent][ifIndex]
+	if !ok {
+		return Interface{}, false
+	}
+	return *i, true
+}
+
+// Len returns the number of interfaces
+func (inv *Inventory) Len() int {
+	return inv.size
+}
+
+// LoadFile loads a file as per its extension: .yaml or .yml, .csv, or .walk
+// or .snmpwalk for the SNMP walks of the agent the file is named after
+func (inv *Inventory) LoadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	ext := filepath.Ext(path)
+	switch strings.ToLower(ext) {
+	case ".yaml", ".yml":
+		err = inv.LoadYAML(f)
+	case ".csv":
+		err = inv.LoadCSV(f)
+	case ".walk", ".snmpwalk":
+		err = inv.LoadSNMPWalk(strings.TrimSuffix(filepath.Base(path), ext), f)
+	default:
+		return fmt.Errorf("%s: not supported inventory file extension", path)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	return nil
+}
+
+// LoadYAML loads the interfaces by agent and ifIndex of a YAML file
+func (inv *Inventory) LoadYAML(r io.Reader) error {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	var agents map[string]map[uint32]Interface
+	if err := yaml.Unmarshal(b, &agents); err != nil {
+		return err
+	}
+	for agent, ifaces := range agents {
+		for ifIndex, i := range ifaces {
+			inv.Add(agent, ifIndex, i)
+		}
+	}
+	return nil
+}
+
+// LoadCSV loads the interfaces of a CSV file with the agent, ifIndex, name,
+// description, speed and role columns, the ones after ifIndex are optional.
+// The # lines are comments and the first line may be a header
+func (inv *Inventory) LoadCSV(r io.Reader) error {
+	cr := csv.NewReader(r)
+	cr.Comment = '#'
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	for line := 1; ; line++ {
+		fields, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := inv.addCSV(line, fields); err != nil && line > 1 {
+			return err
+		}
+	}
+}
+
+func (inv *Inventory) addCSV(line int, fields []string) error {
+	if len(fields) < 2 {
+		return fmt.Errorf("Missing ifIndex on line %d", line)
+	}
+	if net.ParseIP(fields[0]) == nil {
+		return fmt.Errorf("Invalid agent '%s' on line %d", fields[0], line)
+	}
+	ifIndex, err := strconv.ParseUint(fields[1], 10, 32)
+	if err != nil {
+		return fmt.Errorf("Invalid ifIndex '%s' on line %d", fields[1], line)
+	}
+	field := func(n int) string {
+		if n < len(fields) {
+			return fields[n]
+		}
+		return ""
+	}
+	i := Interface{Name: field(2), Description: field(3), Role: field(5)}
+	if speed := field(4); speed != "" {
+		if i.Speed, err = strconv.ParseUint(speed, 10, 64); err != nil {
+			return fmt.Errorf("Invalid speed '%s' on line %d", speed, line)
+		}
+	}
+	inv.Add(fields[0], uint32(ifIndex), i)
+	return nil
+}
+
+// ifTableColumns are the columns of the ifTable and ifXTable read from the
+// SNMP walks, by name and OID
+var ifTableColumns = map[string]string{
+	"IF-MIB::ifDescr":         "ifDescr",
+	"1.3.6.1.2.1.2.2.1.2":     "ifDescr",
+	"IF-MIB::ifSpeed":         "ifSpeed",
+	"1.3.6.1.2.1.2.2.1.5":     "ifSpeed",
+	"IF-MIB::ifName":          "ifName",
+	"1.3.6.1.2.1.31.1.1.1.1":  "ifName",
+	"IF-MIB::ifHighSpeed":     "ifHighSpeed",
+	"1.3.6.1.2.1.31.1.1.1.15": "ifHighSpeed",
+	"IF-MIB::ifAlias":         "ifAlias",
+	"1.3.6.1.2.1.31.1.1.1.18": "ifAlias",
+}
+
+// snmpValueTypes are the types of the values printed by net-snmp
+var snmpValueTypes = map[string]bool{
+	"STRING": true, "Hex-STRING": true, "INTEGER": true, "Gauge32": true,
+	"Counter32": true, "Counter64": true, "Unsigned32": true, "Timeticks": true,
+	"OID": true, "IpAddress": true, "Network Address": true, "BITS": true,
+	"Opaque": true,
+}
+
+// snmpValue returns a value of a walk without its type, e.g. ge-0/0/1 of
+// STRING: "ge-0/0/1", 10000 of Gauge32: 10000 and nothing of STRING:
+func snmpValue(value string) string {
+	if colon := strings.Index(value, ":"); colon >= 0 &&
+		snmpValueTypes[strings.TrimSpace(value[:colon])] {
+		return value[colon+1:]
+	}
+	return value
+}
+
+// LoadSNMPWalk loads the interfaces of an agent from the output of the
+// net-snmp snmpwalk of its ifTable and ifXTable, the OIDs by name or
+// numeric. The name is the ifName (the ifDescr without ifName), the
+// description the ifAlias and the speed the ifHighSpeed (the ifSpeed
+// without ifHighSpeed). The other lines are ignored
+func (inv *Inventory) LoadSNMPWalk(agent string, r io.Reader) error {
+	if net.ParseIP(agent) == nil {
+		return fmt.Errorf("Invalid agent '%s', the SNMP walk files are named "+
+			"after their agent address", agent)
+	}
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	columns := map[uint32]map[string]string{}
+	for _, line := range strings.Split(string(b), "\n") {
+		parts := strings.SplitN(line, " = ", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		oid := strings.TrimPrefix(strings.TrimSpace(parts[0]), ".")
+		if strings.HasPrefix(oid, "iso.") {
+			oid = "1" + strings.TrimPrefix(oid, "iso")
+		}
+		dot := strings.LastIndex(oid, ".")
+		if dot < 0 {
+			continue
+		}
+		column, ok := ifTableColumns[oid[:dot]]
+		if !ok {
+			continue
+		}
+		ifIndex, err := strconv.ParseUint(oid[dot+1:], 10, 32)
+		if err != nil {
+			continue
+		}
+		value := strings.Trim(strings.TrimSpace(snmpValue(parts[1])), `"`)
+		if columns[uint32(ifIndex)] == nil {
+			columns[uint32(ifIndex)] = map[string]string{}
+		}
+		columns[uint32(ifIndex)][column] = value
+	}
+	for ifIndex, c := range columns {
+		i := Interface{Name: c["ifName"], Description: c["ifAlias"]}
+		if i.Name == "" {
+			i.Name = c["ifDescr"]
+		}
+		if speed, err := strconv.ParseUint(c["ifHighSpeed"], 10, 64); err == nil &&
+			speed > 0 {
+			i.Speed = speed * 1000000
+		} else if speed, err := strconv.ParseUint(c["ifSpeed"], 10, 64); err == nil {
+			i.Speed = speed
+		}
+		inv.Add(agent, ifIndex, i)
+	}
+	return nil
+}
+
+// Database is an inventory loaded from files, the files loaded later take
+// precedence for the fields they set
+type Database struct {
+	paths []string
+	files *filewatch.Files
+	mu    sync.RWMutex
+	inv   *Inventory
+}
+
+// Open loads the inventory of the files
+func Open(paths []string) (*Database, error) {
+	db := &Database{paths: paths, files: filewatch.New(paths...)}
+	if _, err := db.reload(); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// Len returns the number of interfaces
+func (db *Database) Len() int {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return db.inv.Len()
+}
+
+// Lookup returns the interface of an agent
+func (db *Database) Lookup(agent string, ifIndex uint32) (Interface, bool) {
+	db.mu.RLock()
+	inv := db.inv
+	db.mu.RUnlock()
+	return inv.Lookup(agent, ifIndex)
+}
+
+// reload loads the files again if the modification time or size of any of
+// them changed since they were loaded, and returns whether it did
+func (db *Database) reload() (bool, error) {
+	return db.files.Reload(db.load)
+}
+
+func (db *Database) load() error {
+	inv := New()
+	for _, path := range db.paths {
+		if err := inv.LoadFile(path); err != nil {
+			return err
+		}
+	}
+	db.mu.Lock()
+	db.inv = inv
+	db.mu.Unlock()
+	inventoryInterfaces.Set(float64(inv.Len()))
+	return nil
+}
+
+// Watch checks the files every interval and reloads them when they changed
+func (db *Database) Watch(interval time.Duration) {
+	db.files.Watch(interval, db.load, func(err error) {
+		if err != nil {
+			inventoryReloads.Inc("error")
+			opts.Logger.Printf("Interface inventory reload error: %v", err)
+			return
+		}
+		inventoryReloads.Inc("success")
+		opts.Logger.Printf("Interface inventory reloaded, %d interfaces", db.Len())
+	})
+}
@@ -0,0 +1,186 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    inventory_test.go
+ * details: Deals with the Unit Test cases for the interface inventory
+ *
+ */
+package inventory
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testYAML = `
+10.84.30.149:
+  556: {name: ge-0/0/1, description: uplink, speed: 10000000000, role: uplink}
+  573:
+    name: ge-0/0/2
+    role: access
+`
+
+const testCSV = `agent,ifindex,name,description,speed,role
+# roles of the sFlow agent
+10.84.30.141,505,xe-0/0/5,,,core
+10.84.30.149,573,,customer A,1000000000
+`
+
+const testWalk = `IF-MIB::ifDescr.505 = STRING: xe-0/0/5
+IF-MIB::ifDescr.506 = STRING: xe-0/0/6
+IF-MIB::ifType.505 = INTEGER: ethernetCsmacd(6)
+IF-MIB::ifSpeed.505 = Gauge32: 4294967295
+IF-MIB::ifSpeed.506 = Gauge32: 1000000000
+IF-MIB::ifName.505 = STRING: xe-0/0/5
+IF-MIB::ifHighSpeed.505 = Gauge32: 10000
+IF-MIB::ifAlias.505 = STRING: to core: router 1
+IF-MIB::ifAlias.506 = ""
+IF-MIB::ifAlias.507 = STRING: 
+IF-MIB::ifName.507 = STRING: xe-0/0/7
+`
+
+const testNumericWalk = `.1.3.6.1.2.1.31.1.1.1.1.505 = STRING: "xe-0/0/5"
+iso.3.6.1.2.1.31.1.1.1.18.505 = STRING: "to core"
+iso.3.6.1.2.1.31.1.1.1.15.505 = Gauge32: 10000
+`
+
+func TestLoad(t *testing.T) {
+	inv := New()
+	if err := inv.LoadYAML(strings.NewReader(testYAML)); err != nil {
+		t.Fatalf("LoadYAML failed: %v", err)
+	}
+	if err := inv.LoadCSV(strings.NewReader(testCSV)); err != nil {
+		t.Fatalf("LoadCSV failed: %v", err)
+	}
+	tests := []struct {
+		agent    string
+		ifIndex  uint32
+		expected Interface
+		found    bool
+	}{
+		{"10.84.30.149", 556, Interface{"ge-0/0/1", "uplink", 10000000000, "uplink"}, true},
+		// merged from both files
+		{"10.84.30.149", 573, Interface{"ge-0/0/2", "customer A", 1000000000, "access"}, true},
+		{"10.84.30.141", 505, Interface{Name: "xe-0/0/5", Role: "core"}, true},
+		{"10.84.30.141", 506, Interface{}, false},
+		{"10.84.30.150", 556, Interface{}, false},
+	}
+	for _, tt := range tests {
+		i, found := inv.Lookup(tt.agent, tt.ifIndex)
+		if i != tt.expected || found != tt.found {
+			t.Errorf("Lookup %s %d expected %+v %v, got %+v %v", tt.agent,
+				tt.ifIndex, tt.expected, tt.found, i, found)
+		}
+	}
+	if n := inv.Len(); n != 3 {
+		t.Errorf("Len expected 3 interfaces, got %d", n)
+	}
+
+	for _, invalid := range []string{
+		"10.84.30.141,505\n10.84.30.141,port5\n",
+		"10.84.30.141,505\nrouter1,505\n",
+		"10.84.30.141,505\n10.84.30.141,506,xe-0/0/6,,10G\n",
+	} {
+		if err := New().LoadCSV(strings.NewReader(invalid)); err == nil {
+			t.Errorf("LoadCSV of '%s' expected an error", invalid)
+		}
+	}
+	if err := New().LoadYAML(strings.NewReader("10.84.30.141: [505]")); err == nil {
+		t.Errorf("LoadYAML of invalid file expected an error")
+	}
+}
+
+func TestLoadSNMPWalk(t *testing.T) {
+	inv := New()
+	if err := inv.LoadSNMPWalk("10.84.30.141", strings.NewReader(testWalk)); err != nil {
+		t.Fatalf("LoadSNMPWalk failed: %v", err)
+	}
+	expected := Interface{"xe-0/0/5", "to core: router 1", 10000000000, ""}
+	if i, _ := inv.Lookup("10.84.30.141", 505); i != expected {
+		t.Errorf("Lookup 505 expected %+v, got %+v", expected, i)
+	}
+	// named after ifDescr, speed as per ifSpeed
+	expected = Interface{Name: "xe-0/0/6", Speed: 1000000000}
+	if i, _ := inv.Lookup("10.84.30.141", 506); i != expected {
+		t.Errorf("Lookup 506 expected %+v, got %+v", expected, i)
+	}
+
+	// no description for an empty ifAlias
+	expected = Interface{Name: "xe-0/0/7"}
+	if i, _ := inv.Lookup("10.84.30.141", 507); i != expected {
+		t.Errorf("Lookup 507 expected %+v, got %+v", expected, i)
+	}
+
+	inv = New()
+	if err := inv.LoadSNMPWalk("10.84.30.141", strings.NewReader(testNumericWalk)); err != nil {
+		t.Fatalf("LoadSNMPWalk of numeric OIDs failed: %v", err)
+	}
+	expected = Interface{"xe-0/0/5", "to core", 10000000000, ""}
+	if i, _ := inv.Lookup("10.84.30.141", 505); i != expected {
+		t.Errorf("Lookup numeric 505 expected %+v, got %+v", expected, i)
+	}
+
+	if err := New().LoadSNMPWalk("router1", strings.NewReader(testWalk)); err == nil {
+		t.Errorf("LoadSNMPWalk of agent without address expected an error")
+	}
+}
+
+func TestDatabase(t *testing.T) {
+	dir, err := ioutil.TempDir("", "inventory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	walk := filepath.Join(dir, "10.84.30.141.walk")
+	roles := filepath.Join(dir, "roles.csv")
+	ioutil.WriteFile(walk, []byte(testWalk), 0644)
+	ioutil.WriteFile(roles, []byte(testCSV), 0644)
+	db, err := Open([]string{walk, roles})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	expected := Interface{"xe-0/0/5", "to core: router 1", 10000000000, "core"}
+	if i, _ := db.Lookup("10.84.30.141", 505); i != expected {
+		t.Errorf("Lookup expected %+v, got %+v", expected, i)
+	}
+	if reloaded, err := db.reload(); reloaded || err != nil {
+		t.Errorf("reload of unchanged files expected none, got %v %v", reloaded, err)
+	}
+
+	// an invalid file keeps the previous inventory
+	later := time.Now().Add(time.Minute)
+	ioutil.WriteFile(roles, []byte(testCSV+"10.84.30.141,port5\n"), 0644)
+	os.Chtimes(roles, later, later)
+	if reloaded, err := db.reload(); reloaded || err == nil {
+		t.Errorf("reload of invalid file expected an error, got %v %v", reloaded, err)
+	}
+	if i, _ := db.Lookup("10.84.30.141", 505); i != expected {
+		t.Errorf("Lookup expected the previous inventory %+v, got %+v", expected, i)
+	}
+
+	ioutil.WriteFile(roles, []byte("10.84.30.141,505,,,,edge\n"), 0644)
+	later = later.Add(time.Minute)
+	os.Chtimes(roles, later, later)
+	if reloaded, err := db.reload(); !reloaded || err != nil {
+		t.Fatalf("reload of changed file failed: %v %v", reloaded, err)
+	}
+	expected.Role = "edge"
+	if i, _ := db.Lookup("10.84.30.141", 505); i != expected {
+		t.Errorf("Lookup after reload expected %+v, got %+v", expected, i)
+	}
+	if _, found := db.Lookup("10.84.30.149", 573); found {
+		t.Errorf("Expected the interfaces removed from the files to be gone")
+	}
+
+	if _, err := Open([]string{filepath.Join(dir, "inventory.json")}); err == nil {
+		t.Errorf("Open of missing file expected an error")
+	}
+	ioutil.WriteFile(filepath.Join(dir, "inventory.json"), []byte("{}"), 0644)
+	if _, err := Open([]string{filepath.Join(dir, "inventory.json")}); err == nil {
+		t.Errorf("Open of not supported file expected an error")
+	}
+}
@@ -0,0 +1,295 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    decoder.go
+ * details: IPFIX (RFC 7011) message decoder with per exporter and
+ *          observation domain template cache
+ *
+ */
+package ipfix
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// Version is the IPFIX protocol version as set in the message header
+	Version = 10
+
+	messageHeaderLen   = 16
+	setHeaderLen       = 4
+	templateSetID      = 2
+	optionsTemplateSet = 3
+	minDataSetID       = 256
+	varLength          = 65535
+	enterpriseBit      = 0x8000
+)
+
+// MessageHeader represents the IPFIX Message Header (RFC 7011 section 3.1)
+type MessageHeader struct {
+	Version    uint16 `json:"Version"`
+	Length     uint16 `json:"Length"`
+	ExportTime uint32 `json:"ExportTime"`
+	SequenceNo uint32 `json:"SequenceNo"`
+	DomainID   uint32 `json:"DomainID"`
+}
+
+// Message represents a decoded IPFIX message, the JSON encoding is the same
+// as the vFlow IPFIX messages as received on Kafka. The data records of the
+// options templates are in OptionsDataSets
+type Message struct {
+	AgentID         string                   `json:"AgentID"`
+	Header          MessageHeader            `json:"Header"`
+	DataSets        []map[string]interface{} `json:"DataSets"`
+	OptionsDataSets []map[string]interface{} `json:"OptionsDataSets,omitempty"`
+	Timestamp       int64                    `json:"Timestamp"`
+	// SkippedSets is the number of Data Sets skipped as their template was
+	// not known, their records are not counted by the sequence number
+	// accounting
+	SkippedSets int `json:"SkippedSets,omitempty"`
+}
+
+// TemplateField is a Field Specifier of a Template Record
+type TemplateField struct {
+	ElementID    uint16
+	Length       uint16
+	EnterpriseNo uint32
+}
+
+// Template is a Template or Options Template Record
+type Template struct {
+	ID         uint16
+	ScopeCount uint16
+	Fields     []TemplateField
+	IsOptions  bool
+}
+
+type templateKey struct {
+	exporter string
+	domainID uint32
+	id       uint16
+}
+
+// TemplateCache keeps the templates per (exporter, observation domain)
+type TemplateCache struct {
+	mu        sync.RWMutex
+	templates map[templateKey]*Template
+}
+
+// NewTemplateCache creates an empty TemplateCache
+func NewTemplateCache() *TemplateCache {
+	return &TemplateCache{templates: make(map[templateKey]*Template)}
+}
+
+// Get returns the template as announced by the exporter for the domain
+func (tc *TemplateCache) Get(exporter string, domainID uint32, id uint16) (*Template, bool) {
+	tc.mu.RLock()
+	defer tc.mu.RUnlock()
+	t, ok := tc.templates[templateKey{exporter, domainID, id}]
+	return t, ok
+}
+
+// Add caches the template as announced by the exporter for the domain
+func (tc *TemplateCache) Add(exporter string, domainID uint32, t *Template) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	tc.templates[templateKey{exporter, domainID, t.ID}] = t
+}
+
+// withdraw removes a template, withdrawing the template set id itself
+// removes all the templates of the domain (RFC 7011 section 8.1)
+func (tc *TemplateCache) withdraw(exporter string, domainID uint32, id uint16) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	if id != templateSetID && id != optionsTemplateSet {
+		delete(tc.templates, templateKey{exporter, domainID, id})
+		return
+	}
+	for k, t := range tc.templates {
+		if k.exporter == exporter && k.domainID == domainID &&
+			t.IsOptions == (id == optionsTemplateSet) {
+			delete(tc.templates, k)
+		}
+	}
+}
+
+// Decoder decodes IPFIX messages
+type Decoder struct {
+	cache *TemplateCache
+}
+
+// NewDecoder creates a Decoder using the given TemplateCache
+func NewDecoder(cache *TemplateCache) *Decoder {
+	return &Decoder{cache: cache}
+}
+
+// Decode decodes an IPFIX message as received from the exporter at the
+// received time. Data Sets whose template is not known yet are skipped, the
+// number of skipped sets is returned along with the message.
+func (d *Decoder) Decode(b []byte, exporter net.IP, received time.Time) (*Message, int, error) {
+	if len(b) < messageHeaderLen {
+		return nil, 0, fmt.Errorf("Invalid IPFIX message length %d", len(b))
+	}
+	msg := &Message{
+		AgentID: exporter.String(),
+		Header: MessageHeader{
+			Version:    binary.BigEndian.Uint16(b[0:2]),
+			Length:     binary.BigEndian.Uint16(b[2:4]),
+			ExportTime: binary.BigEndian.Uint32(b[4:8]),
+			SequenceNo: binary.BigEndian.Uint32(b[8:12]),
+			DomainID:   binary.BigEndian.Uint32(b[12:16]),
+		},
+		DataSets:  []map[string]interface{}{},
+		Timestamp: received.UnixNano() / 1000,
+	}
+	if msg.Header.Version != Version {
+		return nil, 0, fmt.Errorf("Invalid IPFIX version %d", msg.Header.Version)
+	}
+	if int(msg.Header.Length) > len(b) || msg.Header.Length < messageHeaderLen {
+		return nil, 0, fmt.Errorf("Invalid IPFIX message length %d",
+			msg.Header.Length)
+	}
+	skipped := 0
+	agentID := msg.AgentID
+	buf := b[messageHeaderLen:msg.Header.Length]
+	for len(buf) >= setHeaderLen {
+		setID := binary.BigEndian.Uint16(buf[0:2])
+		setLen := int(binary.BigEndian.Uint16(buf[2:4]))
+		if setLen < setHeaderLen || setLen > len(buf) {
+			return nil, skipped, fmt.Errorf("Invalid IPFIX set length %d", setLen)
+		}
+		setBody := buf[setHeaderLen:setLen]
+		buf = buf[setLen:]
+		switch {
+		case setID == templateSetID || setID == optionsTemplateSet:
+			if err := d.decodeTemplateSet(setBody, agentID,
+				msg.Header.DomainID, setID == optionsTemplateSet); err != nil {
+				return nil, skipped, err
+			}
+		case setID >= minDataSetID:
+			t, ok := d.cache.Get(agentID, msg.Header.DomainID, setID)
+			if !ok {
+				skipped++
+				continue
+			}
+			records, err := decodeDataSet(t, setBody)
+			if err != nil {
+				return nil, skipped, err
+			}
+			if t.IsOptions {
+				msg.OptionsDataSets = append(msg.OptionsDataSets, records...)
+			} else {
+				msg.DataSets = append(msg.DataSets, records...)
+			}
+		}
+	}
+	return msg, skipped, nil
+}
+
+func (d *Decoder) decodeTemplateSet(b []byte, exporter string, domainID uint32,
+	isOptions bool) error {
+	hdrLen := 4
+	if isOptions {
+		hdrLen = 6
+	}
+	for len(b) >= 4 {
+		t := &Template{
+			ID:        binary.BigEndian.Uint16(b[0:2]),
+			IsOptions: isOptions,
+		}
+		fieldCount := int(binary.BigEndian.Uint16(b[2:4]))
+		if fieldCount == 0 {
+			d.cache.withdraw(exporter, domainID, t.ID)
+			b = b[4:]
+			continue
+		}
+		if len(b) < hdrLen {
+			return fmt.Errorf("Invalid IPFIX template record length %d", len(b))
+		}
+		if isOptions {
+			t.ScopeCount = binary.BigEndian.Uint16(b[4:6])
+		}
+		b = b[hdrLen:]
+		for i := 0; i < fieldCount; i++ {
+			if len(b) < 4 {
+				return fmt.Errorf("Invalid IPFIX template %d field count %d",
+					t.ID, fieldCount)
+			}
+			f := TemplateField{
+				ElementID: binary.BigEndian.Uint16(b[0:2]),
+				Length:    binary.BigEndian.Uint16(b[2:4]),
+			}
+			b = b[4:]
+			if f.ElementID&enterpriseBit != 0 {
+				if len(b) < 4 {
+					return fmt.Errorf("Invalid IPFIX template %d enterprise field",
+						t.ID)
+				}
+				f.ElementID &^= enterpriseBit
+				f.EnterpriseNo = binary.BigEndian.Uint32(b[0:4])
+				b = b[4:]
+			}
+			t.Fields = append(t.Fields, f)
+		}
+		if t.ID < minDataSetID {
+			return fmt.Errorf("Invalid IPFIX template id %d", t.ID)
+		}
+		d.cache.Add(exporter, domainID, t)
+	}
+	return nil
+}
+
+// minRecordLen returns the smallest possible length of a record as described
+// by the template, used to detect the padding at the end of a set
+func (t *Template) minRecordLen() int {
+	n := 0
+	for _, f := range t.Fields {
+		if f.Length == varLength {
+			n++
+		} else {
+			n += int(f.Length)
+		}
+	}
+	return n
+}
+
+func decodeDataSet(t *Template, b []byte) ([]map[string]interface{}, error) {
+	var records []map[string]interface{}
+	minLen := t.minRecordLen()
+	if minLen == 0 {
+		return records, nil
+	}
+	for len(b) >= minLen {
+		rec := make(map[string]interface{}, len(t.Fields))
+		for _, f := range t.Fields {
+			length := int(f.Length)
+			if f.Length == varLength {
+				if len(b) < 1 {
+					return nil, fmt.Errorf("Invalid IPFIX data record in set %d", t.ID)
+				}
+				length = int(b[0])
+				b = b[1:]
+				if length == 255 {
+					if len(b) < 2 {
+						return nil, fmt.Errorf("Invalid IPFIX data record in set %d",
+							t.ID)
+					}
+					length = int(binary.BigEndian.Uint16(b[0:2]))
+					b = b[2:]
+				}
+			}
+			if len(b) < length {
+				return nil, fmt.Errorf("Invalid IPFIX data record in set %d", t.ID)
+			}
+			ie := LookupElement(f.EnterpriseNo, f.ElementID)
+			rec[ie.Name] = ie.Interpret(b[:length])
+			b = b[length:]
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
@@ -0,0 +1,206 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    decoder_test.go
+ * details: Deals with the Unit Test cases for the IPFIX decoder
+ *
+ */
+package ipfix
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+)
+
+var testExporter = net.ParseIP("10.84.30.149")
+
+// testReceived is the receive time of the test packets
+var testReceived = time.Unix(1522040162, 500000000)
+
+func buildMessage(domainID uint32, sets ...[]byte) []byte {
+	b := make([]byte, messageHeaderLen)
+	for _, s := range sets {
+		b = append(b, s...)
+	}
+	binary.BigEndian.PutUint16(b[0:2], Version)
+	binary.BigEndian.PutUint16(b[2:4], uint16(len(b)))
+	binary.BigEndian.PutUint32(b[4:8], 1522040162)
+	binary.BigEndian.PutUint32(b[8:12], 19127360)
+	binary.BigEndian.PutUint32(b[12:16], domainID)
+	return b
+}
+
+func buildSet(id uint16, body []byte) []byte {
+	b := make([]byte, setHeaderLen, setHeaderLen+len(body))
+	binary.BigEndian.PutUint16(b[0:2], id)
+	binary.BigEndian.PutUint16(b[2:4], uint16(setHeaderLen+len(body)))
+	return append(b, body...)
+}
+
+// testTemplate: sourceIPv4Address, destinationIPv4Address, octetDeltaCount
+// (reduced to 4 bytes), tcpControlBits, interfaceName (variable length)
+var testTemplate = []byte{
+	0x01, 0x00, 0x00, 0x05,
+	0x00, 0x08, 0x00, 0x04,
+	0x00, 0x0c, 0x00, 0x04,
+	0x00, 0x01, 0x00, 0x04,
+	0x00, 0x06, 0x00, 0x01,
+	0x00, 0x52, 0xff, 0xff,
+}
+
+var testRecord = []byte{
+	10, 84, 29, 30,
+	10, 84, 30, 218,
+	0x00, 0x00, 0x00, 0x34,
+	0x11,
+	0x04, 'g', 'e', '-', '0',
+}
+
+func TestDecode(t *testing.T) {
+	cache := NewTemplateCache()
+	d := NewDecoder(cache)
+
+	msg, skipped, err := d.Decode(buildMessage(524288,
+		buildSet(256, testRecord)), testExporter, testReceived)
+	if err != nil {
+		t.Fatalf("Decode before template failed: %v", err)
+	}
+	if skipped != 1 || len(msg.DataSets) != 0 {
+		t.Errorf("expected 1 skipped set and no records, got %d and %d",
+			skipped, len(msg.DataSets))
+	}
+
+	msg, skipped, err = d.Decode(buildMessage(524288,
+		buildSet(templateSetID, testTemplate),
+		buildSet(256, append(append([]byte{}, testRecord...), testRecord...))),
+		testExporter, testReceived)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if skipped != 0 || len(msg.DataSets) != 2 {
+		t.Fatalf("expected 2 records, got %d (skipped %d)", len(msg.DataSets), skipped)
+	}
+	if msg.AgentID != "10.84.30.149" || msg.Header.DomainID != 524288 ||
+		msg.Header.SequenceNo != 19127360 {
+		t.Errorf("unexpected message header %+v agent %s", msg.Header, msg.AgentID)
+	}
+	if msg.Timestamp != 1522040162500000 {
+		t.Errorf("expected the receive time as Timestamp, got %d", msg.Timestamp)
+	}
+	expected := map[string]interface{}{
+		"sourceIPv4Address":      "10.84.29.30",
+		"destinationIPv4Address": "10.84.30.218",
+		"octetDeltaCount":        uint64(52),
+		"tcpControlBits":         "0x11",
+		"interfaceName":          "ge-0",
+	}
+	for k, v := range expected {
+		if msg.DataSets[0][k] != v {
+			t.Errorf("field %s expected '%v', got '%v'", k, v, msg.DataSets[0][k])
+		}
+	}
+
+	// templates are kept per observation domain
+	_, skipped, _ = d.Decode(buildMessage(1, buildSet(256, testRecord)),
+		testExporter, testReceived)
+	if skipped != 1 {
+		t.Errorf("template must not be shared across domains")
+	}
+
+	// template withdrawal
+	_, _, err = d.Decode(buildMessage(524288,
+		buildSet(templateSetID, []byte{0x01, 0x00, 0x00, 0x00})),
+		testExporter, testReceived)
+	if err != nil {
+		t.Fatalf("Decode withdrawal failed: %v", err)
+	}
+	if _, ok := cache.Get(testExporter.String(), 524288, 256); ok {
+		t.Errorf("template 256 still cached after withdrawal")
+	}
+}
+
+// testOptionsTemplate: ingressInterface (scope), interfaceName
+var testOptionsTemplate = []byte{
+	0x01, 0x01, 0x00, 0x02, 0x00, 0x01,
+	0x00, 0x0a, 0x00, 0x04,
+	0x00, 0x52, 0xff, 0xff,
+}
+
+func TestDecodeOptions(t *testing.T) {
+	d := NewDecoder(NewTemplateCache())
+	msg, skipped, err := d.Decode(buildMessage(524288,
+		buildSet(optionsTemplateSet, testOptionsTemplate),
+		buildSet(257, []byte{0x00, 0x00, 0x02, 0x2c, 0x04, 'g', 'e', '-', '0'})),
+		testExporter, testReceived)
+	if err != nil || skipped != 0 {
+		t.Fatalf("Decode options failed: %v (skipped %d)", err, skipped)
+	}
+	if len(msg.DataSets) != 0 || len(msg.OptionsDataSets) != 1 {
+		t.Fatalf("expected 1 options record, got %d and %d data records",
+			len(msg.OptionsDataSets), len(msg.DataSets))
+	}
+	rec := msg.OptionsDataSets[0]
+	if rec["ingressInterface"] != uint64(556) || rec["interfaceName"] != "ge-0" {
+		t.Errorf("unexpected options record %v", rec)
+	}
+}
+
+func TestInterpretFloat(t *testing.T) {
+	tests := []struct {
+		ie       InfoElement
+		b        []byte
+		expected interface{}
+	}{
+		{InfoElement{"samplingProbability", Float64},
+			[]byte{0x3f, 0xe0, 0, 0, 0, 0, 0, 0}, float64(0.5)},
+		{InfoElement{"samplingProbability", Float64},
+			[]byte{0x3f, 0, 0, 0}, float64(0.5)},
+		{InfoElement{"float32", Float32}, []byte{0x3f, 0, 0, 0}, float32(0.5)},
+		// the NaN and the infinities can not be encoded in JSON
+		{InfoElement{"samplingProbability", Float64},
+			[]byte{0x7f, 0xf8, 0, 0, 0, 0, 0, 1}, "0x7ff8000000000001"},
+		{InfoElement{"samplingProbability", Float64},
+			[]byte{0xff, 0x80, 0, 0}, "0xff800000"},
+		{InfoElement{"float32", Float32}, []byte{0x7f, 0x80, 0, 0}, "0x7f800000"},
+	}
+	for _, tt := range tests {
+		v := tt.ie.Interpret(tt.b)
+		if v != tt.expected {
+			t.Errorf("Interpret(%x) expected %v (%T), got %v (%T)", tt.b,
+				tt.expected, tt.expected, v, v)
+		}
+		if _, err := json.Marshal(v); err != nil {
+			t.Errorf("Interpret(%x) not encoded: %v", tt.b, err)
+		}
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  []byte
+	}{
+		{name: "Short message", msg: []byte{0x00, 0x0a}},
+		{name: "Invalid version", msg: func() []byte {
+			b := buildMessage(0)
+			b[1] = 9
+			return b
+		}()},
+		{name: "Invalid set length", msg: func() []byte {
+			b := buildMessage(0, buildSet(256, testRecord))
+			binary.BigEndian.PutUint16(b[messageHeaderLen+2:], 200)
+			return b
+		}()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDecoder(NewTemplateCache())
+			if _, _, err := d.Decode(tt.msg, testExporter, testReceived); err == nil {
+				t.Errorf("%s expected error, got nil", tt.name)
+			}
+		})
+	}
+}
@@ -0,0 +1,344 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    elements.go
+ * details: IANA IPFIX Information Elements and their value interpretation
+ *
+ */
+package ipfix
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// DataType is the abstract data type of an Information Element (RFC 7012)
+type DataType int
+
+const (
+	OctetArray DataType = iota
+	Unsigned8
+	Unsigned16
+	Unsigned32
+	Unsigned64
+	Signed8
+	Signed16
+	Signed32
+	Signed64
+	Float32
+	Float64
+	Boolean
+	MacAddress
+	String
+	DateTimeSeconds
+	DateTimeMilliseconds
+	DateTimeMicroseconds
+	DateTimeNanoseconds
+	IPv4Address
+	IPv6Address
+)
+
+// InfoElement describes an Information Element
+type InfoElement struct {
+	Name string
+	Type DataType
+}
+
+// ntpEpochOffset is the number of seconds between 1900 (NTP) and 1970 (Unix)
+const ntpEpochOffset = 2208988800
+
+// ianaElements are the IANA assigned Information Elements known to the
+// translator, the names follow the ones used by vFlow
+var ianaElements = map[uint16]InfoElement{
+	1:   {"octetDeltaCount", Unsigned64},
+	2:   {"packetDeltaCount", Unsigned64},
+	3:   {"deltaFlowCount", Unsigned64},
+	4:   {"protocolIdentifier", Unsigned8},
+	5:   {"ipClassOfService", Unsigned8},
+	6:   {"tcpControlBits", Unsigned16},
+	7:   {"sourceTransportPort", Unsigned16},
+	8:   {"sourceIPv4Address", IPv4Address},
+	9:   {"sourceIPv4PrefixLength", Unsigned8},
+	10:  {"ingressInterface", Unsigned32},
+	11:  {"destinationTransportPort", Unsigned16},
+	12:  {"destinationIPv4Address", IPv4Address},
+	13:  {"destinationIPv4PrefixLength", Unsigned8},
+	14:  {"egressInterface", Unsigned32},
+	15:  {"ipNextHopIPv4Address", IPv4Address},
+	16:  {"bgpSourceAsNumber", Unsigned32},
+	17:  {"bgpDestinationAsNumber", Unsigned32},
+	18:  {"bgpNextHopIPv4Address", IPv4Address},
+	19:  {"postMCastPacketDeltaCount", Unsigned64},
+	20:  {"postMCastOctetDeltaCount", Unsigned64},
+	21:  {"flowEndSysUpTime", Unsigned32},
+	22:  {"flowStartSysUpTime", Unsigned32},
+	23:  {"postOctetDeltaCount", Unsigned64},
+	24:  {"postPacketDeltaCount", Unsigned64},
+	25:  {"minimumIpTotalLength", Unsigned64},
+	26:  {"maximumIpTotalLength", Unsigned64},
+	27:  {"sourceIPv6Address", IPv6Address},
+	28:  {"destinationIPv6Address", IPv6Address},
+	29:  {"sourceIPv6PrefixLength", Unsigned8},
+	30:  {"destinationIPv6PrefixLength", Unsigned8},
+	31:  {"flowLabelIPv6", Unsigned32},
+	32:  {"icmpTypeCodeIPv4", Unsigned16},
+	33:  {"igmpType", Unsigned8},
+	34:  {"samplingInterval", Unsigned32},
+	35:  {"samplingAlgorithm", Unsigned8},
+	36:  {"flowActiveTimeout", Unsigned16},
+	37:  {"flowIdleTimeout", Unsigned16},
+	38:  {"engineType", Unsigned8},
+	39:  {"engineId", Unsigned8},
+	40:  {"exportedOctetTotalCount", Unsigned64},
+	41:  {"exportedMessageTotalCount", Unsigned64},
+	42:  {"exportedFlowRecordTotalCount", Unsigned64},
+	44:  {"sourceIPv4Prefix", IPv4Address},
+	45:  {"destinationIPv4Prefix", IPv4Address},
+	46:  {"mplsTopLabelType", Unsigned8},
+	47:  {"mplsTopLabelIPv4Address", IPv4Address},
+	48:  {"samplerId", Unsigned8},
+	49:  {"samplerMode", Unsigned8},
+	50:  {"samplerRandomInterval", Unsigned32},
+	52:  {"minimumTTL", Unsigned8},
+	53:  {"maximumTTL", Unsigned8},
+	54:  {"fragmentIdentification", Unsigned32},
+	55:  {"postIpClassOfService", Unsigned8},
+	56:  {"sourceMacAddress", MacAddress},
+	57:  {"postDestinationMacAddress", MacAddress},
+	58:  {"vlanId", Unsigned16},
+	59:  {"postVlanId", Unsigned16},
+	60:  {"ipVersion", Unsigned8},
+	61:  {"flowDirection", Unsigned8},
+	62:  {"ipNextHopIPv6Address", IPv6Address},
+	63:  {"bgpNextHopIPv6Address", IPv6Address},
+	64:  {"ipv6ExtensionHeaders", Unsigned32},
+	70:  {"mplsTopLabelStackSection", OctetArray},
+	80:  {"destinationMacAddress", MacAddress},
+	81:  {"postSourceMacAddress", MacAddress},
+	82:  {"interfaceName", String},
+	83:  {"interfaceDescription", String},
+	85:  {"octetTotalCount", Unsigned64},
+	86:  {"packetTotalCount", Unsigned64},
+	88:  {"fragmentOffset", Unsigned16},
+	89:  {"forwardingStatus", Unsigned32},
+	128: {"bgpNextAdjacentAsNumber", Unsigned32},
+	129: {"bgpPrevAdjacentAsNumber", Unsigned32},
+	130: {"exporterIPv4Address", IPv4Address},
+	131: {"exporterIPv6Address", IPv6Address},
+	136: {"flowEndReason", Unsigned8},
+	139: {"icmpTypeCodeIPv6", Unsigned16},
+	148: {"flowId", Unsigned64},
+	149: {"observationDomainId", Unsigned32},
+	150: {"flowStartSeconds", DateTimeSeconds},
+	151: {"flowEndSeconds", DateTimeSeconds},
+	152: {"flowStartMilliseconds", DateTimeMilliseconds},
+	153: {"flowEndMilliseconds", DateTimeMilliseconds},
+	154: {"flowStartMicroseconds", DateTimeMicroseconds},
+	155: {"flowEndMicroseconds", DateTimeMicroseconds},
+	156: {"flowStartNanoseconds", DateTimeNanoseconds},
+	157: {"flowEndNanoseconds", DateTimeNanoseconds},
+	160: {"systemInitTimeMilliseconds", DateTimeMilliseconds},
+	161: {"flowDurationMilliseconds", Unsigned32},
+	162: {"flowDurationMicroseconds", Unsigned32},
+	176: {"icmpTypeIPv4", Unsigned8},
+	177: {"icmpCodeIPv4", Unsigned8},
+	178: {"icmpTypeIPv6", Unsigned8},
+	179: {"icmpCodeIPv6", Unsigned8},
+	180: {"udpSourcePort", Unsigned16},
+	181: {"udpDestinationPort", Unsigned16},
+	182: {"tcpSourcePort", Unsigned16},
+	183: {"tcpDestinationPort", Unsigned16},
+	192: {"ipTTL", Unsigned8},
+	193: {"nextHeaderIPv6", Unsigned8},
+	195: {"ipDiffServCodePoint", Unsigned8},
+	196: {"ipPrecedence", Unsigned8},
+	197: {"fragmentFlags", Unsigned8},
+	224: {"ipTotalLength", Unsigned64},
+	225: {"postNATSourceIPv4Address", IPv4Address},
+	226: {"postNATDestinationIPv4Address", IPv4Address},
+	227: {"postNAPTSourceTransportPort", Unsigned16},
+	228: {"postNAPTDestinationTransportPort", Unsigned16},
+	234: {"ingressVRFID", Unsigned32},
+	235: {"egressVRFID", Unsigned32},
+	236: {"VRFname", String},
+	243: {"dot1qVlanId", Unsigned16},
+	244: {"dot1qPriority", Unsigned8},
+	245: {"dot1qCustomerVlanId", Unsigned16},
+	246: {"dot1qCustomerPriority", Unsigned8},
+	252: {"ingressPhysicalInterface", Unsigned32},
+	253: {"egressPhysicalInterface", Unsigned32},
+	256: {"ethernetType", Unsigned16},
+	302: {"selectorId", Unsigned64},
+	304: {"selectorAlgorithm", Unsigned16},
+	305: {"samplingPacketInterval", Unsigned32},
+	306: {"samplingPacketSpace", Unsigned32},
+	309: {"samplingSize", Unsigned32},
+	310: {"samplingPopulation", Unsigned32},
+	311: {"samplingProbability", Float64},
+	312: {"dataLinkFrameSize", Unsigned16},
+	313: {"ipHeaderPacketSection", OctetArray},
+	315: {"dataLinkFrameSection", OctetArray},
+	323: {"observationTimeMilliseconds", DateTimeMilliseconds},
+	324: {"observationTimeMicroseconds", DateTimeMicroseconds},
+	325: {"observationTimeNanoseconds", DateTimeNanoseconds},
+}
+
+// hexElements are rendered as hex strings, the same way vFlow stores them
+var hexElements = map[string]bool{
+	"tcpControlBits":        true,
+	"reverseTcpControlBits": true,
+}
+
+// ReversePEN is the enterprise number of the reverse Information Elements
+// of the biflows (RFC 5103), a reverse element has the id of its forward
+// element
+const ReversePEN = 29305
+
+// LookupElement returns the Information Element for the given id, elements
+// which are not known are named by their id, prefixed with the enterprise
+// number for enterprise specific elements
+func LookupElement(enterpriseNo uint32, id uint16) InfoElement {
+	return lookupElement(elementKey{enterpriseNo, id})
+}
+
+func lookupElement(key elementKey) InfoElement {
+	enterpriseNo, id := key.enterpriseNo, key.id
+	if ie, ok := elements.lookup(key); ok {
+		return ie
+	}
+	if enterpriseNo == 0 {
+		if ie, ok := ianaElements[id]; ok {
+			return ie
+		}
+		return InfoElement{Name: strconv.Itoa(int(id)), Type: OctetArray}
+	}
+	if enterpriseNo == ReversePEN {
+		if ie, ok := ianaElements[id]; ok {
+			return InfoElement{Name: reverseName(ie.Name), Type: ie.Type}
+		}
+	}
+	return InfoElement{Name: strconv.FormatUint(uint64(enterpriseNo), 10) + ":" +
+		strconv.Itoa(int(id)), Type: OctetArray}
+}
+
+// reverseName returns the name of the reverse element of a forward element,
+// e.g. reverseOctetDeltaCount for octetDeltaCount
+func reverseName(name string) string {
+	return "reverse" + strings.ToUpper(name[:1]) + name[1:]
+}
+
+// finite tells whether the float can be encoded in JSON, the NaN and the
+// infinities are kept as hex strings
+func finite(f float64) bool {
+	return !math.IsNaN(f) && !math.IsInf(f, 0)
+}
+
+func decodeUnsigned(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
+
+func decodeSigned(b []byte) int64 {
+	if len(b) == 0 {
+		return 0
+	}
+	v := int64(decodeUnsigned(b))
+	shift := uint(64 - 8*len(b))
+	return v << shift >> shift
+}
+
+func hexString(b []byte) string {
+	return "0x" + hex.EncodeToString(b)
+}
+
+// ntpToUnix converts the NTP timestamp format used by dateTimeMicroseconds and
+// dateTimeNanoseconds into Unix nanoseconds
+func ntpToUnix(b []byte) int64 {
+	secs := int64(binary.BigEndian.Uint32(b[:4])) - ntpEpochOffset
+	frac := int64(binary.BigEndian.Uint32(b[4:8]))
+	return secs*1e9 + (frac*1e9)>>32
+}
+
+// Interpret converts the raw value of an Information Element into a value
+// which can be marshalled to JSON. Values whose length does not fit their
+// abstract data type are returned as hex strings.
+func (ie InfoElement) Interpret(b []byte) interface{} {
+	if hexElements[ie.Name] {
+		return hexString(b)
+	}
+	switch ie.Type {
+	case Unsigned8, Unsigned16, Unsigned32, Unsigned64, DateTimeSeconds,
+		DateTimeMilliseconds:
+		if len(b) > 8 {
+			break
+		}
+		return decodeUnsigned(b)
+	case Signed8, Signed16, Signed32, Signed64:
+		if len(b) > 8 {
+			break
+		}
+		return decodeSigned(b)
+	case Float32:
+		if len(b) != 4 {
+			break
+		}
+		f := math.Float32frombits(binary.BigEndian.Uint32(b))
+		if !finite(float64(f)) {
+			break
+		}
+		return f
+	case Float64:
+		var f float64
+		if len(b) == 4 {
+			f = float64(math.Float32frombits(binary.BigEndian.Uint32(b)))
+		} else if len(b) == 8 {
+			f = math.Float64frombits(binary.BigEndian.Uint64(b))
+		} else {
+			break
+		}
+		if !finite(f) {
+			break
+		}
+		return f
+	case Boolean:
+		if len(b) != 1 {
+			break
+		}
+		return b[0] == 1
+	case MacAddress:
+		if len(b) != 6 {
+			break
+		}
+		return net.HardwareAddr(b).String()
+	case String:
+		return string(b)
+	case DateTimeMicroseconds:
+		if len(b) != 8 {
+			break
+		}
+		return ntpToUnix(b) / 1e3
+	case DateTimeNanoseconds:
+		if len(b) != 8 {
+			break
+		}
+		return ntpToUnix(b)
+	case IPv4Address:
+		if len(b) != net.IPv4len {
+			break
+		}
+		return net.IP(b).String()
+	case IPv6Address:
+		if len(b) != net.IPv6len {
+			break
+		}
+		return net.IP(b).String()
+	}
+	return hexString(b)
+}
@@ -0,0 +1,384 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    registry.go
+ * details: Registry of the Information Elements as loaded from the IANA
+ *          ipfix-information-elements CSV and the enterprise definitions,
+ *          used to name, convert and validate the DataSet fields
+ *
+ */
+package ipfix
+
+import (
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CSV columns of the element definitions, the enterprise number column is
+// only found in the enterprise definitions
+const (
+	columnEnterpriseNo = "EnterpriseNo"
+	columnElementID    = "ElementID"
+	columnName         = "Name"
+	columnDataType     = "Abstract Data Type"
+)
+
+// dataTypes are the abstract data types by their IANA name, the list types
+// are kept as octet arrays
+var dataTypes = map[string]DataType{
+	"octetArray":           OctetArray,
+	"unsigned8":            Unsigned8,
+	"unsigned16":           Unsigned16,
+	"unsigned32":           Unsigned32,
+	"unsigned64":           Unsigned64,
+	"signed8":              Signed8,
+	"signed16":             Signed16,
+	"signed32":             Signed32,
+	"signed64":             Signed64,
+	"float32":              Float32,
+	"float64":              Float64,
+	"boolean":              Boolean,
+	"macAddress":           MacAddress,
+	"string":               String,
+	"dateTimeSeconds":      DateTimeSeconds,
+	"dateTimeMilliseconds": DateTimeMilliseconds,
+	"dateTimeMicroseconds": DateTimeMicroseconds,
+	"dateTimeNanoseconds":  DateTimeNanoseconds,
+	"ipv4Address":          IPv4Address,
+	"ipv6Address":          IPv6Address,
+	"basicList":            OctetArray,
+	"subTemplateList":      OctetArray,
+	"subTemplateMultiList": OctetArray,
+}
+
+type elementKey struct {
+	enterpriseNo uint32
+	id           uint16
+}
+
+// registry keeps the loaded elements by id and by name, on top of the
+// built-in IANA elements
+type registry struct {
+	mu     sync.RWMutex
+	byID   map[elementKey]InfoElement
+	byName map[string]elementKey
+}
+
+var elements = newRegistry()
+
+func newRegistry() *registry {
+	r := &registry{
+		byID:   map[elementKey]InfoElement{},
+		byName: map[string]elementKey{},
+	}
+	for id, ie := range ianaElements {
+		r.byName[ie.Name] = elementKey{id: id}
+	}
+	return r
+}
+
+func (r *registry) lookup(key elementKey) (InfoElement, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	ie, ok := r.byID[key]
+	return ie, ok
+}
+
+func (r *registry) lookupName(name string) (InfoElement, bool) {
+	r.mu.RLock()
+	key, ok := r.byName[name]
+	if !ok && strings.HasPrefix(name, "reverse") && len(name) > len("reverse") {
+		// the reverse elements are named after their forward element
+		forward := name[len("reverse"):]
+		forward = strings.ToLower(forward[:1]) + forward[1:]
+		if key, ok = r.byName[forward]; ok && key.enterpriseNo == 0 {
+			key.enterpriseNo = ReversePEN
+		} else {
+			ok = false
+		}
+	}
+	r.mu.RUnlock()
+	if !ok {
+		return InfoElement{}, false
+	}
+	ie := lookupElement(key)
+	return ie, ie.Name == name
+}
+
+// add registers an element, the built-in IANA elements keep their name so
+// that the stored field names do not change
+func (r *registry) add(key elementKey, ie InfoElement) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if builtin, ok := ianaElements[key.id]; ok && key.enterpriseNo == 0 {
+		ie.Name = builtin.Name
+	}
+	if old, ok := r.byID[key]; ok && old.Name != ie.Name {
+		delete(r.byName, old.Name)
+	}
+	r.byID[key] = ie
+	r.byName[ie.Name] = key
+}
+
+// LoadElements loads the element definitions of a CSV file with the columns
+// of the IANA ipfix-information-elements CSV, and an EnterpriseNo column for
+// the enterprise specific elements. The rows without a single element id or
+// without a known data type, e.g. the reserved ranges, are skipped. It
+// returns the number of loaded elements
+func LoadElements(in io.Reader) (int, error) {
+	r := csv.NewReader(in)
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err != nil {
+		return 0, fmt.Errorf("Invalid elements header: %v", err)
+	}
+	cols := map[string]int{}
+	for i, name := range header {
+		cols[strings.TrimSpace(name)] = i
+	}
+	for _, name := range []string{columnElementID, columnName, columnDataType} {
+		if _, ok := cols[name]; !ok {
+			return 0, fmt.Errorf("No %s column in elements header", name)
+		}
+	}
+	field := func(row []string, name string) string {
+		if i, ok := cols[name]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+	loaded := 0
+	for line := 2; ; line++ {
+		row, err := r.Read()
+		if err == io.EOF {
+			return loaded, nil
+		}
+		if err != nil {
+			return loaded, fmt.Errorf("Invalid elements line %d: %v", line, err)
+		}
+		id, err := strconv.ParseUint(field(row, columnElementID), 10, 15)
+		if err != nil {
+			continue
+		}
+		dataType, ok := dataTypes[field(row, columnDataType)]
+		name := field(row, columnName)
+		if !ok || name == "" {
+			continue
+		}
+		key := elementKey{id: uint16(id)}
+		if pen := field(row, columnEnterpriseNo); pen != "" {
+			enterpriseNo, err := strconv.ParseUint(pen, 10, 32)
+			if err != nil {
+				return loaded, fmt.Errorf("Invalid enterprise number %s line %d",
+					pen, line)
+			}
+			key.enterpriseNo = uint32(enterpriseNo)
+		}
+		elements.add(key, InfoElement{Name: name, Type: dataType})
+		loaded++
+	}
+}
+
+// LoadElementsFile loads the element definitions of a CSV file
+func LoadElementsFile(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return LoadElements(f)
+}
+
+// LookupElementByName returns the Information Element of a DataSet field
+func LookupElementByName(name string) (InfoElement, bool) {
+	return elements.lookupName(name)
+}
+
+// NormalizeField names, converts and validates a DataSet field as decoded
+// from JSON. The fields named by the id of an element unknown at decoding
+// time ("id" or "enterprise:id") are renamed once the element is known, and
+// their hex value is interpreted. The values of the known elements are
+// converted into the same representation as the decoder uses, an error is
+// returned when the value does not fit the data type. Unknown fields are
+// returned as they are
+func NormalizeField(name string, v interface{}) (string, interface{}, error) {
+	if key, ok := parseElementName(name); ok {
+		name = lookupElement(key).Name
+	}
+	ie, ok := elements.lookupName(name)
+	if !ok {
+		return name, v, nil
+	}
+	if s, ok := v.(string); ok && strings.HasPrefix(s, "0x") &&
+		ie.Type != String && !hexElements[ie.Name] {
+		b, err := hex.DecodeString(s[2:])
+		if err != nil {
+			return name, nil, fmt.Errorf("Invalid %s value %s", name, s)
+		}
+		if ie.Type == OctetArray {
+			return name, s, nil
+		}
+		v = ie.Interpret(b)
+		// the values not fitting their data type are left as hex strings
+		if s, ok := v.(string); ok && strings.HasPrefix(s, "0x") {
+			return name, nil, fmt.Errorf("Invalid %s length %d", name, len(b))
+		}
+		return name, v, nil
+	}
+	if hexElements[ie.Name] {
+		return name, v, nil
+	}
+	v, err := convertValue(ie, v)
+	if err != nil {
+		return name, nil, fmt.Errorf("Invalid %s value %v: %v", name, v, err)
+	}
+	return name, v, nil
+}
+
+// parseElementName parses the names given to the unknown elements by
+// LookupElement
+func parseElementName(name string) (elementKey, bool) {
+	var key elementKey
+	if i := strings.IndexByte(name, ':'); i >= 0 {
+		pen, err := strconv.ParseUint(name[:i], 10, 32)
+		if err != nil {
+			return key, false
+		}
+		key.enterpriseNo = uint32(pen)
+		name = name[i+1:]
+	}
+	id, err := strconv.ParseUint(name, 10, 16)
+	if err != nil {
+		return key, false
+	}
+	key.id = uint16(id)
+	return key, true
+}
+
+// bitSize returns the size in bits of the integer data types
+func bitSize(t DataType) int {
+	switch t {
+	case Unsigned8, Signed8:
+		return 8
+	case Unsigned16, Signed16:
+		return 16
+	case Unsigned32, Signed32, DateTimeSeconds:
+		return 32
+	}
+	return 64
+}
+
+// convertValue checks a JSON decoded value against the data type of the
+// element
+func convertValue(ie InfoElement, v interface{}) (interface{}, error) {
+	switch ie.Type {
+	case Unsigned8, Unsigned16, Unsigned32, Unsigned64, DateTimeSeconds,
+		DateTimeMilliseconds:
+		n, err := jsonNumber(v)
+		if err != nil {
+			return v, err
+		}
+		if _, err := strconv.ParseUint(n.String(), 10, bitSize(ie.Type)); err != nil {
+			return v, fmt.Errorf("not an unsigned%d integer", bitSize(ie.Type))
+		}
+		return n, nil
+	case Signed8, Signed16, Signed32, Signed64, DateTimeMicroseconds,
+		DateTimeNanoseconds:
+		if s, ok := v.(string); ok && (ie.Type == DateTimeMicroseconds ||
+			ie.Type == DateTimeNanoseconds) {
+			t, err := time.Parse(time.RFC3339Nano, s)
+			if err != nil {
+				return v, err
+			}
+			if ie.Type == DateTimeMicroseconds {
+				return t.UnixNano() / 1e3, nil
+			}
+			return t.UnixNano(), nil
+		}
+		n, err := jsonNumber(v)
+		if err != nil {
+			return v, err
+		}
+		if _, err := strconv.ParseInt(n.String(), 10, bitSize(ie.Type)); err != nil {
+			return v, fmt.Errorf("not a signed%d integer", bitSize(ie.Type))
+		}
+		return n, nil
+	case Float32, Float64:
+		n, err := jsonNumber(v)
+		if err != nil {
+			return v, err
+		}
+		f, err := n.Float64()
+		if err != nil || math.IsNaN(f) {
+			return v, fmt.Errorf("not a number")
+		}
+		return n, nil
+	case Boolean:
+		switch b := v.(type) {
+		case bool:
+			return b, nil
+		case string:
+			return strconv.ParseBool(b)
+		}
+		// RFC 7011 encodes true as 1 and false as 2
+		n, err := jsonNumber(v)
+		if err != nil {
+			return v, err
+		}
+		switch n.String() {
+		case "1":
+			return true, nil
+		case "2":
+			return false, nil
+		}
+		return v, fmt.Errorf("not a boolean")
+	case MacAddress:
+		s, _ := v.(string)
+		mac, err := net.ParseMAC(s)
+		if err != nil {
+			return v, err
+		}
+		return mac.String(), nil
+	case IPv4Address, IPv6Address:
+		s, _ := v.(string)
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return v, fmt.Errorf("not an IP address")
+		}
+		// the IPv4-mapped IPv6 addresses are valid ipv6Address values, they
+		// are written as IPv4 addresses as by the decoder
+		if ie.Type == IPv4Address && ip.To4() == nil {
+			return v, fmt.Errorf("wrong IP address family")
+		}
+		return ip.String(), nil
+	case String:
+		if _, ok := v.(string); !ok {
+			return v, fmt.Errorf("not a string")
+		}
+	}
+	return v, nil
+}
+
+func jsonNumber(v interface{}) (json.Number, error) {
+	switch n := v.(type) {
+	case json.Number:
+		return n, nil
+	case float64:
+		return json.Number(strconv.FormatFloat(n, 'f', -1, 64)), nil
+	case uint64:
+		return json.Number(strconv.FormatUint(n, 10)), nil
+	case int64:
+		return json.Number(strconv.FormatInt(n, 10)), nil
+	}
+	return "", fmt.Errorf("not a number")
+}
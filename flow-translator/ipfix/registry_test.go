@@ -0,0 +1,121 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    registry_test.go
+ * details: Deals with the Unit Test cases for the element registry
+ *
+ */
+package ipfix
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testIANAElements = `ElementID,Name,Abstract Data Type,Data Type Semantics,Status,Description,Units,Range,Additional Information,Reference,Revision,Date
+1,octetDeltaCount,unsigned64,deltaCounter,current,"The number of octets, since the previous report",octets,,,[RFC5102],0,2013-02-18
+8,sourceIPv4Address,ipv4Address,default,current,The IPv4 source address,,,,[RFC5102],0,2013-02-18
+105-127,Assigned for NetFlow v9 compatibility,,,,,,,,[RFC3954],,
+176,icmpTypeIPv4,unsigned8,identifier,current,Type of the IPv4 ICMP message,,,,[RFC5102],0,2013-02-18
+433,Unassigned,,,,,,,,,,
+`
+
+const testEnterpriseElements = `EnterpriseNo,ElementID,Name,Abstract Data Type
+2636,137,juniperCommonProperties,unsigned64
+2636,138,juniperIngressMAC,macAddress
+2636,139,juniperFlowStart,dateTimeMilliseconds
+2636,141,juniperSampled,boolean
+`
+
+func TestLoadElements(t *testing.T) {
+	n, err := LoadElements(strings.NewReader(testIANAElements))
+	if err != nil || n != 3 {
+		t.Fatalf("LoadElements expected 3 elements, got %d %v", n, err)
+	}
+	n, err = LoadElements(strings.NewReader(testEnterpriseElements))
+	if err != nil || n != 4 {
+		t.Fatalf("LoadElements expected 4 enterprise elements, got %d %v", n, err)
+	}
+	tests := []struct {
+		enterpriseNo uint32
+		id           uint16
+		expected     InfoElement
+	}{
+		{0, 8, InfoElement{"sourceIPv4Address", IPv4Address}},
+		{0, 176, InfoElement{"icmpTypeIPv4", Unsigned8}},
+		{0, 433, InfoElement{"433", OctetArray}},
+		{2636, 137, InfoElement{"juniperCommonProperties", Unsigned64}},
+		{2636, 140, InfoElement{"2636:140", OctetArray}},
+		{ReversePEN, 1, InfoElement{"reverseOctetDeltaCount", Unsigned64}},
+		{ReversePEN, 6, InfoElement{"reverseTcpControlBits", Unsigned16}},
+		{ReversePEN, 433, InfoElement{"29305:433", OctetArray}},
+	}
+	for _, tt := range tests {
+		if ie := LookupElement(tt.enterpriseNo, tt.id); ie != tt.expected {
+			t.Errorf("LookupElement(%d, %d) expected %v, got %v",
+				tt.enterpriseNo, tt.id, tt.expected, ie)
+		}
+	}
+	if _, err := LoadElements(strings.NewReader("ElementID,Name\n1,x\n")); err == nil {
+		t.Errorf("LoadElements expected an error without the data type column")
+	}
+}
+
+func TestNormalizeField(t *testing.T) {
+	if _, err := LoadElements(strings.NewReader(testEnterpriseElements)); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		value     interface{}
+		field     string
+		expected  interface{}
+		wantError bool
+	}{
+		{"sourceIPv4Address", "10.1.1.1", "sourceIPv4Address", "10.1.1.1", false},
+		{"sourceIPv4Address", "2001:db8::1", "", nil, true},
+		{"sourceIPv6Address", "2001:0db8:0:0::1", "sourceIPv6Address", "2001:db8::1", false},
+		{"sourceIPv6Address", "::ffff:10.1.1.1", "sourceIPv6Address", "10.1.1.1", false},
+		{"sourceIPv6Address", "10.1.1.1", "sourceIPv6Address", "10.1.1.1", false},
+		{"sourceIPv6Address", "10.1.1", "", nil, true},
+		{"sourceMacAddress", "00-25-90-94-B4-E6", "sourceMacAddress", "00:25:90:94:b4:e6", false},
+		{"octetDeltaCount", json.Number("52"), "octetDeltaCount", json.Number("52"), false},
+		{"octetDeltaCount", json.Number("-1"), "", nil, true},
+		{"octetDeltaCount", "abc", "", nil, true},
+		{"protocolIdentifier", json.Number("255"), "protocolIdentifier", json.Number("255"), false},
+		{"protocolIdentifier", json.Number("300"), "", nil, true},
+		{"sourceTransportPort", json.Number("70000"), "", nil, true},
+		{"tcpControlBits", "0x11", "tcpControlBits", "0x11", false},
+		{"2636:137", "0x0000000000000010", "juniperCommonProperties", uint64(16), false},
+		{"2636:138", "0x00259094b4e6", "juniperIngressMAC", "00:25:90:94:b4:e6", false},
+		{"2636:138", "0x0025", "", nil, true},
+		{"2636:139", json.Number("1522040157115"), "juniperFlowStart", json.Number("1522040157115"), false},
+		{"flowStartMicroseconds", "2018-03-26T05:15:57.115Z", "flowStartMicroseconds", int64(1522041357115000), false},
+		{"2636:141", json.Number("2"), "juniperSampled", false, false},
+		{"juniperSampled", "0x01", "juniperSampled", true, false},
+		{"juniperSampled", json.Number("3"), "", nil, true},
+		{"29305:2", "0x0000000000000003", "reversePacketDeltaCount", uint64(3), false},
+		{"reverseOctetDeltaCount", json.Number("52"), "reverseOctetDeltaCount", json.Number("52"), false},
+		{"reverseOctetDeltaCount", json.Number("-1"), "", nil, true},
+		{"reverseTcpControlBits", "0x12", "reverseTcpControlBits", "0x12", false},
+		{"reverse", "x", "reverse", "x", false},
+		{"2636:999", "0x01", "2636:999", "0x01", false},
+		{"vendorField", true, "vendorField", true, false},
+	}
+	for _, tt := range tests {
+		field, v, err := NormalizeField(tt.name, tt.value)
+		if (err != nil) != tt.wantError {
+			t.Errorf("NormalizeField(%s, %v) error %v", tt.name, tt.value, err)
+			continue
+		}
+		if tt.wantError {
+			continue
+		}
+		if field != tt.field || !reflect.DeepEqual(v, tt.expected) {
+			t.Errorf("NormalizeField(%s, %v) expected %s %v (%T), got %s %v (%T)",
+				tt.name, tt.value, tt.field, tt.expected, tt.expected, field, v, v)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    backpressure.go
+ * details: Pauses the assigned partitions while the message handler queues
+ *          are full, with the block queue policy
+ *
+ */
+package kafkaconsumer
+
+import (
+	"github.com/Juniper/collector/flow-translator/metrics"
+	msghandler "github.com/Juniper/collector/flow-translator/msg-handler"
+	opts "github.com/Juniper/collector/flow-translator/options"
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+var consumerPaused = metrics.NewGauge("flow_translator_kafka_consumer_paused",
+	"1 while the partitions are paused as the message handler queues are full")
+
+type partitionPauser struct {
+	k *kafka.Consumer
+	// backlog returns the number of consumed messages not queued yet
+	backlog  func() int
+	assigned []kafka.TopicPartition
+	paused   bool
+}
+
+func (p *partitionPauser) assign(tps []kafka.TopicPartition) {
+	p.assigned = tps
+	if p.paused {
+		p.k.Pause(tps)
+	}
+}
+
+func (p *partitionPauser) revoke() {
+	p.assigned = nil
+}
+
+// release leaves the partition out of the resumed ones, e.g. once a bounded
+// consumption is done with it
+func (p *partitionPauser) release(tp kafka.TopicPartition) {
+	var kept []kafka.TopicPartition
+	for _, assigned := range p.assigned {
+		if *assigned.Topic != *tp.Topic || assigned.Partition != tp.Partition {
+			kept = append(kept, assigned)
+		}
+	}
+	p.assigned = kept
+}
+
+// check pauses the partitions once a queue is full and resumes them once
+// the queues are drained and the backlog is queued, the messages already
+// fetched are still queued
+func (p *partitionPauser) check() {
+	if opts.SinkQueuePolicy != opts.QueuePolicyBlock {
+		return
+	}
+	if !p.paused && msghandler.QueuesFull() {
+		if opts.Verbose {
+			opts.Logger.Println("Queues full, pausing", p.assigned)
+		}
+		if err := p.k.Pause(p.assigned); err != nil {
+			opts.Logger.Println("Failed to pause partitions ", err)
+			return
+		}
+		p.paused = true
+		consumerPaused.Set(1)
+	} else if p.paused && msghandler.QueuesDrained() && p.backlog() == 0 {
+		if opts.Verbose {
+			opts.Logger.Println("Queues drained, resuming", p.assigned)
+		}
+		if err := p.k.Resume(p.assigned); err != nil {
+			opts.Logger.Println("Failed to resume partitions ", err)
+			return
+		}
+		p.paused = false
+		consumerPaused.Set(0)
+	}
+}
@@ -0,0 +1,242 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    bounded.go
+ * details: Consumes the assigned partitions up to a point in time and stops
+ *          once the consumed messages are acknowledged, used to re-drive the
+ *          dead letter topic and to replay a time window
+ *
+ */
+package kafkaconsumer
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+
+	msghandler "github.com/Juniper/collector/flow-translator/msg-handler"
+	opts "github.com/Juniper/collector/flow-translator/options"
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+type boundedConsumer struct {
+	k *kafka.Consumer
+	// until is the time of the first message not to consume
+	until time.Time
+	// seek, if set, returns the offsets to start the assigned partitions at
+	seek func(tps []kafka.TopicPartition) ([]kafka.TopicPartition, error)
+	// toMessage converts the consumed message for the message handlers
+	toMessage func(e *kafka.Message) (*msghandler.Message, error)
+	// commit is set to commit the offsets of the acknowledged messages
+	commit bool
+
+	offsets *offsetTracker
+	done    map[partitionKey]bool
+	fwd     *forwarder
+	pauser  *partitionPauser
+	// stopped is set once the partitions are done after err
+	stopped bool
+
+	// mu guards the fields set by the forwarder
+	mu sync.Mutex
+	// err is the error of the message none of the message handlers accepts,
+	// the consumption stops there and its offset is not committed
+	err error
+	// handled counts the messages handed to the message handlers, unsent the
+	// ones never handed to them from the one of err on
+	handled int
+	unsent  int
+}
+
+// run consumes until all the assigned partitions are done, or an interrupt is
+// received, and returns the number of messages handed to the message handlers.
+// It stops at the first message none of the message handlers accepts, once
+// the messages before it are acknowledged. As for the Kafka consumer, the
+// messages are handed over by a forwarder and the partitions paused while the
+// queues are full, so that the Kafka events are still handled
+func (b *boundedConsumer) run() (int, error) {
+	signalCh := make(chan os.Signal, 1)
+	signal.Notify(signalCh, os.Interrupt)
+
+	b.offsets = newOffsetTracker()
+	b.done = make(map[partitionKey]bool)
+	b.fwd = newForwarder(b.send)
+	b.pauser = &partitionPauser{k: b.k, backlog: b.fwd.len}
+	ticker := time.NewTicker(opts.KafkaCommitInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case sig := <-signalCh:
+			opts.Logger.Println("Interrupt is detected", sig)
+			b.commitOffsets()
+			return b.result()
+		case <-ticker.C:
+			b.commitOffsets()
+			b.pauser.check()
+			b.checkStopped()
+			if b.isDone() && b.offsets.inFlight() <= b.unhandled() {
+				b.commitOffsets()
+				return b.result()
+			}
+		case ev := <-b.k.Events():
+			switch e := ev.(type) {
+			case kafka.AssignedPartitions:
+				opts.Logger.Println(e)
+				b.assign(e.Partitions)
+			case kafka.RevokedPartitions:
+				opts.Logger.Println(e)
+				b.commitOffsets()
+				for _, tp := range e.Partitions {
+					delete(b.done, partitionKey{*tp.Topic, tp.Partition})
+					b.fwd.forget(*tp.Topic, tp.Partition)
+					b.offsets.forget(*tp.Topic, tp.Partition)
+				}
+				b.k.Unassign()
+				b.pauser.revoke()
+			case kafka.PartitionEOF:
+				b.partitionDone(kafka.TopicPartition(e))
+			case *kafka.Message:
+				tp := e.TopicPartition
+				key := partitionKey{*tp.Topic, tp.Partition}
+				if b.done[key] {
+					continue
+				}
+				if !e.Timestamp.Before(b.until) {
+					b.partitionDone(tp)
+					continue
+				}
+				ack := b.offsets.track(key.topic, key.partition, int64(tp.Offset))
+				msg, err := b.toMessage(e)
+				if err != nil {
+					opts.Logger.Println(err)
+					ack()
+					continue
+				}
+				msg.OnAck = ack
+				b.fwd.push(key, msg)
+				b.pauser.check()
+				b.checkStopped()
+			case kafka.Error:
+				opts.Logger.Println(e)
+			}
+		}
+	}
+}
+
+func (b *boundedConsumer) assign(tps []kafka.TopicPartition) {
+	if b.seek != nil {
+		seeked, err := b.seek(tps)
+		if err != nil {
+			opts.Logger.Fatalln("Failed to find the start offsets ", err)
+		}
+		tps = seeked
+	}
+	for _, tp := range tps {
+		b.done[partitionKey{*tp.Topic, tp.Partition}] = false
+	}
+	b.k.Assign(tps)
+	b.pauser.assign(tps)
+	for _, tp := range tps {
+		// no message at or after the start time
+		if tp.Offset == kafka.OffsetEnd {
+			b.partitionDone(tp)
+		}
+	}
+}
+
+// partitionDone stops the consumption of the partition
+func (b *boundedConsumer) partitionDone(tp kafka.TopicPartition) {
+	key := partitionKey{*tp.Topic, tp.Partition}
+	if done, ok := b.done[key]; !ok || done {
+		return
+	}
+	b.done[key] = true
+	b.k.Pause([]kafka.TopicPartition{tp})
+	b.pauser.release(tp)
+	if opts.Verbose {
+		opts.Logger.Println("Done with partition", tp)
+	}
+}
+
+// send hands the message to the message handlers, it is called by the
+// forwarder. The messages from the first one none of the message handlers
+// accepts on are not handed over
+func (b *boundedConsumer) send(msg *msghandler.Message) error {
+	b.mu.Lock()
+	stopped := b.err != nil
+	b.mu.Unlock()
+	var err error
+	if !stopped {
+		err = msghandler.SendToInChannels(msg)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch {
+	case stopped:
+		b.unsent++
+	case err != nil:
+		opts.Logger.Printf("Stopping at offset %d of %s: %v", msg.Offset,
+			msg.Topic, err)
+		b.err = err
+		b.unsent++
+	default:
+		b.handled++
+	}
+	return nil
+}
+
+// checkStopped is done with all the partitions once a message was not
+// accepted by the message handlers
+func (b *boundedConsumer) checkStopped() {
+	if b.stopped {
+		return
+	}
+	b.mu.Lock()
+	b.stopped = b.err != nil
+	b.mu.Unlock()
+	if !b.stopped {
+		return
+	}
+	for pk := range b.done {
+		topic := pk.topic
+		b.partitionDone(kafka.TopicPartition{Topic: &topic,
+			Partition: pk.partition})
+	}
+}
+
+// unhandled returns the number of in-flight messages which are never
+// acknowledged
+func (b *boundedConsumer) unhandled() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.unsent
+}
+
+// result returns the number of messages handed to the message handlers and
+// the error of the one none of them accepts
+func (b *boundedConsumer) result() (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.handled, b.err
+}
+
+func (b *boundedConsumer) isDone() bool {
+	if len(b.done) == 0 {
+		return false
+	}
+	for _, done := range b.done {
+		if !done {
+			return false
+		}
+	}
+	return true
+}
+
+func (b *boundedConsumer) commitOffsets() {
+	if b.commit {
+		commitOffsets(b.k, b.offsets)
+	}
+}
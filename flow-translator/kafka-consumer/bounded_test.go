@@ -0,0 +1,48 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    bounded_test.go
+ * details: Deals with the Unit Test cases for the bounded consumption
+ *
+ */
+package kafkaconsumer
+
+import (
+	"io/ioutil"
+	"log"
+	"testing"
+
+	msghandler "github.com/Juniper/collector/flow-translator/msg-handler"
+	opts "github.com/Juniper/collector/flow-translator/options"
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+func TestBoundedSendStops(t *testing.T) {
+	defer func(logger *log.Logger) { opts.Logger = logger }(opts.Logger)
+	opts.Logger = log.New(ioutil.Discard, "", 0)
+
+	// none of the message handlers is started, no message is accepted
+	b := &boundedConsumer{}
+	for offset := int64(0); offset < 3; offset++ {
+		b.send(&msghandler.Message{Topic: "vflow.ipfix", Offset: offset})
+	}
+	handled, err := b.result()
+	if handled != 0 || err == nil {
+		t.Errorf("expected no message handled and an error, got %d %v",
+			handled, err)
+	}
+	if n := b.unhandled(); n != 3 {
+		t.Errorf("expected 3 messages never acknowledged, got %d", n)
+	}
+}
+
+func TestPauserRelease(t *testing.T) {
+	topic := "vflow.ipfix"
+	p := &partitionPauser{}
+	p.assign([]kafka.TopicPartition{{Topic: &topic, Partition: 0},
+		{Topic: &topic, Partition: 1}})
+	p.release(kafka.TopicPartition{Topic: &topic, Partition: 0})
+	if len(p.assigned) != 1 || p.assigned[0].Partition != 1 {
+		t.Errorf("expected partition 1 to be resumed only, got %v", p.assigned)
+	}
+}
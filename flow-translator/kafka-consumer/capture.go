@@ -0,0 +1,81 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    capture.go
+ * details: Records the messages of a Kafka topic into a capture file, to be
+ *          replayed later with replay-file
+ *
+ */
+package kafkaconsumer
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/Juniper/collector/flow-translator/capture"
+	opts "github.com/Juniper/collector/flow-translator/options"
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// Capture writes the messages received on the topic from now on into the
+// capture file, until count messages are written or the duration elapsed.
+// Both are unlimited when 0
+func Capture(path string, topic string, count int, duration time.Duration) error {
+	w, err := capture.NewWriter(path)
+	if err != nil {
+		return fmt.Errorf("Failed to create %s: %v", path, err)
+	}
+
+	signalCh := make(chan os.Signal, 1)
+	signal.Notify(signalCh, os.Interrupt)
+	var timeoutCh <-chan time.Time
+	if duration > 0 {
+		timeoutCh = time.After(duration)
+	}
+
+	opts.Logger.Printf("Capturing %s into %s", topic, path)
+	k, err := kafka.NewConsumer(newConfigMap(kafka.ConfigMap{
+		"group.id":                 opts.KafkaGroupID + "-capture",
+		"go.events.channel.enable": true,
+		"enable.auto.commit":       false,
+		"default.topic.config": kafka.ConfigMap{
+			"auto.offset.reset": "latest",
+		},
+	}))
+	if err != nil {
+		opts.Logger.Fatalln("Failed to create kafka-consumer ", err)
+	}
+	defer k.Close()
+	k.Subscribe(topic, nil)
+
+	captured := 0
+loop:
+	for count == 0 || captured < count {
+		select {
+		case sig := <-signalCh:
+			opts.Logger.Println("Interrupt is detected", sig)
+			break loop
+		case <-timeoutCh:
+			break loop
+		case ev := <-k.Events():
+			switch e := ev.(type) {
+			case *kafka.Message:
+				if err := w.Write(e.Value); err != nil {
+					w.Close()
+					return fmt.Errorf("Failed to write %s: %v", path, err)
+				}
+				captured++
+			case kafka.Error:
+				opts.Logger.Println(e)
+			}
+		}
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("Failed to write %s: %v", path, err)
+	}
+	opts.Logger.Printf("Captured %d messages of %s into %s", captured, topic,
+		path)
+	return nil
+}
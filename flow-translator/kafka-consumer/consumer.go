@@ -10,59 +10,49 @@ package kafkaconsumer
 import (
 	"os"
 	"os/signal"
+	"time"
 
+	"github.com/Juniper/collector/flow-translator/metrics"
 	msghandler "github.com/Juniper/collector/flow-translator/msg-handler"
 	opts "github.com/Juniper/collector/flow-translator/options"
 	"github.com/confluentinc/confluent-kafka-go/kafka"
 )
 
-type consChannel struct {
-	chConsName string
-	inCh       chan []byte
-	outCh      chan []byte
-}
-
-var (
-	chanDM consChannel
-	chanQA consChannel
-)
-
-func initConsChannels() {
-	/* Init DM */
-	chanDM.chConsName = opts.StrDataManager
-	chanDM.inCh = make(chan []byte)
-	chanDM.outCh = make(chan []byte)
-
-	/* Init QA */
-	chanQA.chConsName = opts.StrQueryAPI
-	chanQA.inCh = make(chan []byte)
-	chanQA.outCh = make(chan []byte)
-}
-
 //KafkaConsumer constructs Kafka-Consumer based on confluent-kafka-go library
+// The offsets are committed by the consumer once the messages have been
+// acknowledged by all the enabled message handlers (at-least-once delivery),
+// the partitions are paused while the message handler queues are full. The
+// messages are handed to the message handlers by a forwarder, the events are
+// still handled while it waits for room in the queues
 func KafkaConsumer() error {
-	brokers := opts.KafkaBrokerList
-	topic := opts.KafkaTopic
+	topics := opts.KafkaTopicList()
 
 	signalCh := make(chan os.Signal, 1)
 	signal.Notify(signalCh, os.Interrupt)
 
-	opts.Logger.Println("Starting Kafka Consumer for topic", opts.KafkaTopic)
-	k, err := kafka.NewConsumer(&kafka.ConfigMap{
-		"bootstrap.servers":               brokers,
-		"group.id":                        opts.StrKafkaConGroupID,
+	opts.Logger.Println("Starting Kafka Consumer for topics", topics)
+	k, err := kafka.NewConsumer(newConfigMap(kafka.ConfigMap{
+		"group.id":                        opts.KafkaGroupID,
 		"go.events.channel.enable":        true,
 		"go.application.rebalance.enable": true,
+		"enable.auto.commit":              false,
 		"default.topic.config": kafka.ConfigMap{
-			"auto.offset.reset": "earliest",
+			"auto.offset.reset": opts.KafkaAutoOffsetReset,
 		},
-	})
+	}))
 	if err != nil {
 		opts.Logger.Fatalln("Failed to create kafka-consumer ", err)
 	}
-	k.Subscribe(topic, nil)
-	manageChannels()
-	registerMsgHandlers()
+	k.SubscribeTopics(topics, nil)
+	StartProducers()
+	metrics.StartServer()
+	msghandler.StartMsgHandlers()
+
+	offsets := newOffsetTracker()
+	fwd := newForwarder(msghandler.SendToInChannels)
+	pauser := &partitionPauser{k: k, backlog: fwd.len}
+	commitTicker := time.NewTicker(opts.KafkaCommitInterval)
+	defer commitTicker.Stop()
 
 	doneCh := make(chan struct{})
 	go func() {
@@ -70,15 +60,27 @@ func KafkaConsumer() error {
 			select {
 			case sig := <-signalCh:
 				opts.Logger.Println("Interrupt is detected", sig)
+				commitOffsets(k, offsets)
 				doneCh <- struct{}{}
+				return
+			case <-commitTicker.C:
+				commitOffsets(k, offsets)
+				pauser.check()
 			case ev := <-k.Events():
 				switch e := ev.(type) {
 				case kafka.AssignedPartitions:
 					opts.Logger.Println(e)
 					k.Assign(e.Partitions)
+					pauser.assign(e.Partitions)
 				case kafka.RevokedPartitions:
 					opts.Logger.Println(e)
+					commitOffsets(k, offsets)
+					for _, tp := range e.Partitions {
+						fwd.forget(*tp.Topic, tp.Partition)
+						offsets.forget(*tp.Topic, tp.Partition)
+					}
 					k.Unassign()
+					pauser.revoke()
 				case *kafka.Message:
 					if opts.Verbose {
 						opts.Logger.Printf("Received on [%s] messages %s\n", e.TopicPartition, string(e.Value))
@@ -86,7 +88,17 @@ func KafkaConsumer() error {
 					if opts.Verbose {
 						opts.Logger.Println("Txing inCh")
 					}
-					sendToInChannels(e.Value)
+					tp := e.TopicPartition
+					key := partitionKey{*tp.Topic, tp.Partition}
+					fwd.push(key, &msghandler.Message{
+						Topic:     *tp.Topic,
+						Value:     e.Value,
+						Partition: tp.Partition,
+						Offset:    int64(tp.Offset),
+						Key:       string(e.Key),
+						OnAck:     offsets.track(*tp.Topic, tp.Partition, int64(tp.Offset)),
+					})
+					pauser.check()
 				case kafka.Error:
 					opts.Logger.Println(e)
 				}
@@ -95,81 +107,42 @@ func KafkaConsumer() error {
 	}()
 
 	<-doneCh
+	k.Close()
 	opts.Logger.Println("Kafka-Consumer Closed")
 	return nil
 }
 
-func manageChannels() {
-	initConsChannels()
-	manageOutChannels(chanDM.inCh, chanDM.outCh)
-	manageOutChannels(chanQA.inCh, chanQA.outCh)
-}
-
-func sendToInChannels(msg []byte) {
-	if opts.SendToDM {
-		chanDM.inCh <- msg
+// newConfigMap returns the client properties, the common ones from the
+// config file along with the given ones
+func newConfigMap(props kafka.ConfigMap) *kafka.ConfigMap {
+	conf := kafka.ConfigMap{}
+	for key, value := range opts.KafkaClientConfig() {
+		conf[key] = value
 	}
-	if opts.SendToQA {
-		chanQA.inCh <- msg
+	for key, value := range props {
+		conf[key] = value
 	}
+	return &conf
 }
 
-func manageOutChannels(inCh chan []byte, outCh chan []byte) {
-	go func() {
-		var inQueue [][]byte
-		outChannel := func() chan []byte {
-			if len(inQueue) == 0 {
-				return nil
-			}
-			return outCh
-		}
-		enQueue := func(val []byte) {
-			inQueue = append(inQueue, val)
-			if opts.Verbose {
-				opts.Logger.Println("Data Queued:", string(val))
-			}
-		}
-		deQueue := func() []byte {
-			if len(inQueue) == 0 {
-				return nil
-			}
-			curVal := inQueue[0]
-			inQueue = inQueue[1:]
-			if opts.Verbose {
-				opts.Logger.Println("Data served from Queue:", string(curVal))
-			}
-			return curVal
-		}
-		for len(inQueue) > 0 || inCh != nil {
-			select {
-			case v, ok := <-inCh:
-				if opts.Verbose {
-					opts.Logger.Println("Rxing in inCh")
-				}
-				if !ok {
-					inCh = nil
-				} else {
-					enQueue(v)
-				}
-			case outChannel() <- deQueue():
-			}
-		}
-		//opts.Logger.Println("Closing outCh as:", outCh)
-		//close(outCh)
-	}()
-}
-
-func registerMsgHandlers() {
-	// Register all the message handlers here
-	conChannelList := []consChannel{chanDM, chanQA}
-	conChannelLen := len(conChannelList)
-	for i := 0; i < conChannelLen; i++ {
-		go func(i int) {
-			mh := msghandler.NewMsgHandler(conChannelList[i].chConsName)
-			mh.MHChan = conChannelList[i].outCh
-			if err := mh.Run(); err != nil {
-				opts.Logger.Fatalf("msgHandler run error %v ", err)
-			}
-		}(i)
+// commitOffsets commits the offsets of the acknowledged messages
+func commitOffsets(k *kafka.Consumer, offsets *offsetTracker) {
+	var tps []kafka.TopicPartition
+	for key, offset := range offsets.committable() {
+		topic := key.topic
+		tps = append(tps, kafka.TopicPartition{
+			Topic:     &topic,
+			Partition: key.partition,
+			Offset:    kafka.Offset(offset),
+		})
+	}
+	if len(tps) == 0 {
+		return
+	}
+	if opts.Verbose {
+		opts.Logger.Println("Committing offsets", tps)
+	}
+	if _, err := k.CommitOffsets(tps); err != nil {
+		opts.Logger.Println("Failed to commit offsets ", err)
 	}
 }
@@ -0,0 +1,106 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    deadletter.go
+ * details: Publishes the messages the message handlers failed on to the
+ *          dead letter topic, the failure is described in the headers
+ *
+ */
+package kafkaconsumer
+
+import (
+	"fmt"
+	"strconv"
+
+	msghandler "github.com/Juniper/collector/flow-translator/msg-handler"
+	opts "github.com/Juniper/collector/flow-translator/options"
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// The headers of the messages on the dead letter topic
+const (
+	HeaderError           = "error"
+	HeaderSourceTopic     = "source-topic"
+	HeaderSourcePartition = "source-partition"
+	HeaderSourceOffset    = "source-offset"
+	HeaderSink            = "sink"
+	HeaderStage           = "stage"
+)
+
+// startDeadLetterProducer hands the producer of the dead letter topic to the
+// message handlers
+func startDeadLetterProducer(p *kafka.Producer) {
+	opts.Logger.Println("Failed messages are published on", opts.KafkaDLQTopic)
+	msghandler.DeadLetter = func(msg *msghandler.Message, sink string,
+		stage string, err error) error {
+		return publishDeadLetter(p, msg, sink, stage, err)
+	}
+}
+
+// publishDeadLetter publishes the message and waits for its delivery, so
+// that the source offset is only committed once the message is safe
+func publishDeadLetter(p *kafka.Producer, msg *msghandler.Message,
+	sink string, stage string, err error) error {
+	topic := opts.KafkaDLQTopic
+	deliveryCh := make(chan kafka.Event, 1)
+	perr := p.Produce(&kafka.Message{
+		TopicPartition: kafka.TopicPartition{
+			Topic:     &topic,
+			Partition: kafka.PartitionAny,
+		},
+		Value:   msg.Value,
+		Headers: deadLetterHeaders(msg, sink, stage, err),
+	}, deliveryCh)
+	if perr != nil {
+		return perr
+	}
+	if m, ok := (<-deliveryCh).(*kafka.Message); ok {
+		return m.TopicPartition.Error
+	}
+	return nil
+}
+
+func deadLetterHeaders(msg *msghandler.Message, sink string, stage string,
+	err error) []kafka.Header {
+	header := func(key string, value string) kafka.Header {
+		return kafka.Header{Key: key, Value: []byte(value)}
+	}
+	return []kafka.Header{
+		header(HeaderError, err.Error()),
+		header(HeaderSourceTopic, msg.Topic),
+		header(HeaderSourcePartition, strconv.FormatInt(int64(msg.Partition), 10)),
+		header(HeaderSourceOffset, strconv.FormatInt(msg.Offset, 10)),
+		header(HeaderSink, sink),
+		header(HeaderStage, stage),
+	}
+}
+
+// messageFromDeadLetter rebuilds the message as it was received on the
+// source topic, restricted to the message handler which failed on it
+func messageFromDeadLetter(e *kafka.Message) (*msghandler.Message, error) {
+	headers := make(map[string]string)
+	for _, h := range e.Headers {
+		headers[h.Key] = string(h.Value)
+	}
+	msg := &msghandler.Message{
+		Topic: headers[HeaderSourceTopic],
+		Value: e.Value,
+		Sink:  headers[HeaderSink],
+	}
+	if msg.Topic == "" {
+		return nil, fmt.Errorf("Invalid dead letter message, no %s header",
+			HeaderSourceTopic)
+	}
+	partition, err := strconv.ParseInt(headers[HeaderSourcePartition], 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid dead letter %s header: %v",
+			HeaderSourcePartition, err)
+	}
+	msg.Partition = int32(partition)
+	msg.Offset, err = strconv.ParseInt(headers[HeaderSourceOffset], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid dead letter %s header: %v",
+			HeaderSourceOffset, err)
+	}
+	return msg, nil
+}
@@ -0,0 +1,78 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    deadletter_test.go
+ * details: Deals with the Unit Test cases for the dead letter messages
+ *
+ */
+package kafkaconsumer
+
+import (
+	"fmt"
+	"testing"
+
+	msghandler "github.com/Juniper/collector/flow-translator/msg-handler"
+	opts "github.com/Juniper/collector/flow-translator/options"
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+func TestDeadLetterRoundTrip(t *testing.T) {
+	msg := &msghandler.Message{
+		Topic:     "vflow.ipfix",
+		Value:     []byte(`{"AgentID":"10.1.1.1"}`),
+		Partition: 3,
+		Offset:    4242,
+	}
+	headers := deadLetterHeaders(msg, opts.StrQueryAPI, msghandler.StageDecode,
+		fmt.Errorf("Invalid timeStamp in ipfix msg"))
+	got := make(map[string]string)
+	for _, h := range headers {
+		got[h.Key] = string(h.Value)
+	}
+	expected := map[string]string{
+		HeaderError:           "Invalid timeStamp in ipfix msg",
+		HeaderSourceTopic:     "vflow.ipfix",
+		HeaderSourcePartition: "3",
+		HeaderSourceOffset:    "4242",
+		HeaderSink:            opts.StrQueryAPI,
+		HeaderStage:           msghandler.StageDecode,
+	}
+	for key, value := range expected {
+		if got[key] != value {
+			t.Errorf("header %s: expected '%s', got '%s'", key, value, got[key])
+		}
+	}
+
+	redriven, err := messageFromDeadLetter(&kafka.Message{Value: msg.Value,
+		Headers: headers})
+	if err != nil {
+		t.Fatalf("messageFromDeadLetter failed: %v", err)
+	}
+	if redriven.Topic != msg.Topic || redriven.Partition != msg.Partition ||
+		redriven.Offset != msg.Offset || redriven.Sink != opts.StrQueryAPI ||
+		string(redriven.Value) != string(msg.Value) {
+		t.Errorf("expected %+v, got %+v", msg, redriven)
+	}
+}
+
+func TestMessageFromDeadLetterInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers []kafka.Header
+	}{
+		{name: "No headers"},
+		{name: "Invalid offset", headers: []kafka.Header{
+			{Key: HeaderSourceTopic, Value: []byte("vflow.ipfix")},
+			{Key: HeaderSourcePartition, Value: []byte("0")},
+			{Key: HeaderSourceOffset, Value: []byte("x")},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := messageFromDeadLetter(&kafka.Message{Headers: tt.headers})
+			if err == nil {
+				t.Errorf("%s expected error, got nil", tt.name)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    forwarder.go
+ * details: Hands the consumed messages to the message handlers from its own
+ *          goroutine, so that the Kafka events (rebalances, commits) are
+ *          still handled while the block queue policy waits for room
+ *
+ */
+package kafkaconsumer
+
+import (
+	"sync"
+
+	msghandler "github.com/Juniper/collector/flow-translator/msg-handler"
+	opts "github.com/Juniper/collector/flow-translator/options"
+)
+
+type forwarder struct {
+	send func(msg *msghandler.Message) error
+
+	mu       sync.Mutex
+	notEmpty *sync.Cond
+	pending  []forwarded
+	// sending is set while a message is handed to the message handlers
+	sending bool
+}
+
+// forwarded is a pending message along with the partition it was consumed
+// from, the source of the re-driven messages is not the consumed partition
+type forwarded struct {
+	key partitionKey
+	msg *msghandler.Message
+}
+
+func newForwarder(send func(msg *msghandler.Message) error) *forwarder {
+	f := &forwarder{send: send}
+	f.notEmpty = sync.NewCond(&f.mu)
+	go f.run()
+	return f
+}
+
+// push queues the message for the message handlers, it never blocks. The
+// backlog is bounded as the partitions are paused while the queues are full
+func (f *forwarder) push(key partitionKey, msg *msghandler.Message) {
+	f.mu.Lock()
+	f.pending = append(f.pending, forwarded{key, msg})
+	f.notEmpty.Signal()
+	f.mu.Unlock()
+}
+
+func (f *forwarder) run() {
+	for {
+		f.mu.Lock()
+		for len(f.pending) == 0 {
+			f.notEmpty.Wait()
+		}
+		msg := f.pending[0].msg
+		f.pending[0] = forwarded{}
+		f.pending = f.pending[1:]
+		f.sending = true
+		f.mu.Unlock()
+
+		if err := f.send(msg); err != nil {
+			opts.Logger.Println(err)
+		}
+		f.mu.Lock()
+		f.sending = false
+		f.mu.Unlock()
+	}
+}
+
+// len returns the number of messages not handed to the message handlers yet
+func (f *forwarder) len() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := len(f.pending)
+	if f.sending {
+		n++
+	}
+	return n
+}
+
+// forget drops the pending messages of a revoked partition, they are
+// consumed again by the new owner of the partition
+func (f *forwarder) forget(topic string, partition int32) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	kept := f.pending[:0]
+	for _, p := range f.pending {
+		if p.key != (partitionKey{topic, partition}) {
+			kept = append(kept, p)
+		}
+	}
+	for i := len(kept); i < len(f.pending); i++ {
+		f.pending[i] = forwarded{}
+	}
+	f.pending = kept
+}
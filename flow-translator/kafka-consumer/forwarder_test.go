@@ -0,0 +1,62 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    forwarder_test.go
+ * details: Deals with the Unit Test cases for the forwarding of the consumed
+ *          messages to the message handlers
+ *
+ */
+package kafkaconsumer
+
+import (
+	"testing"
+	"time"
+
+	msghandler "github.com/Juniper/collector/flow-translator/msg-handler"
+)
+
+func TestForwarder(t *testing.T) {
+	// the message handlers are blocked until unblock is closed
+	started, unblock := make(chan struct{}, 10), make(chan struct{})
+	sent := make(chan int64, 10)
+	f := newForwarder(func(msg *msghandler.Message) error {
+		started <- struct{}{}
+		<-unblock
+		sent <- msg.Offset
+		return nil
+	})
+	for offset := int64(0); offset < 4; offset++ {
+		partition := int32(offset % 2)
+		f.push(partitionKey{"vflow.ipfix", partition},
+			&msghandler.Message{Topic: "vflow.ipfix", Partition: partition,
+				Offset: offset})
+	}
+	if n := f.len(); n != 4 {
+		t.Errorf("expected a backlog of 4 messages, got %d", n)
+	}
+
+	// the message of partition 0 being sent is kept
+	<-started
+	f.forget("vflow.ipfix", 0)
+	if n := f.len(); n != 3 {
+		t.Errorf("expected a backlog of 3 messages once forgotten, got %d", n)
+	}
+	close(unblock)
+	for _, expected := range []int64{0, 1, 3} {
+		select {
+		case offset := <-sent:
+			if offset != expected {
+				t.Errorf("expected offset %d, got %d", expected, offset)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("expected offset %d to be sent", expected)
+		}
+	}
+	for deadline := time.Now().Add(time.Second); f.len() != 0 &&
+		time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	if n := f.len(); n != 0 {
+		t.Errorf("expected no backlog, got %d", n)
+	}
+}
@@ -0,0 +1,102 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    offsets.go
+ * details: Tracks the in-flight messages of every partition, the offset of a
+ *          partition only moves past a message once it and all the messages
+ *          before it have been acknowledged by the message handlers
+ *
+ */
+package kafkaconsumer
+
+import (
+	"sync"
+)
+
+type partitionKey struct {
+	topic     string
+	partition int32
+}
+
+// partitionOffsets holds the in-flight offsets of one partition in the order
+// they were received
+type partitionOffsets struct {
+	inFlight []int64
+	acked    map[int64]bool
+	next     int64
+	dirty    bool
+}
+
+type offsetTracker struct {
+	sync.Mutex
+	partitions map[partitionKey]*partitionOffsets
+}
+
+func newOffsetTracker() *offsetTracker {
+	return &offsetTracker{
+		partitions: make(map[partitionKey]*partitionOffsets),
+	}
+}
+
+// track registers a received message, the returned function acknowledges it
+func (t *offsetTracker) track(topic string, partition int32,
+	offset int64) func() {
+	t.Lock()
+	defer t.Unlock()
+	key := partitionKey{topic, partition}
+	po, ok := t.partitions[key]
+	if !ok {
+		po = &partitionOffsets{acked: make(map[int64]bool)}
+		t.partitions[key] = po
+	}
+	po.inFlight = append(po.inFlight, offset)
+	return func() {
+		t.ack(po, offset)
+	}
+}
+
+func (t *offsetTracker) ack(po *partitionOffsets, offset int64) {
+	t.Lock()
+	defer t.Unlock()
+	po.acked[offset] = true
+	for len(po.inFlight) > 0 && po.acked[po.inFlight[0]] {
+		delete(po.acked, po.inFlight[0])
+		po.next = po.inFlight[0] + 1
+		po.inFlight = po.inFlight[1:]
+		po.dirty = true
+	}
+}
+
+// committable returns the offsets to commit for the partitions that moved
+// since the last call, the offset is the one of the next message to consume
+func (t *offsetTracker) committable() map[partitionKey]int64 {
+	t.Lock()
+	defer t.Unlock()
+	offsets := make(map[partitionKey]int64)
+	for key, po := range t.partitions {
+		if po.dirty {
+			offsets[key] = po.next
+			po.dirty = false
+		}
+	}
+	return offsets
+}
+
+// inFlight returns the number of messages not acknowledged yet
+func (t *offsetTracker) inFlight() int {
+	t.Lock()
+	defer t.Unlock()
+	n := 0
+	for _, po := range t.partitions {
+		n += len(po.inFlight)
+	}
+	return n
+}
+
+// forget drops a partition, the late acknowledgements of its messages are
+// ignored as they may be consumed again by the new owner of the partition
+func (t *offsetTracker) forget(topic string, partition int32) {
+	t.Lock()
+	defer t.Unlock()
+	delete(t.partitions, partitionKey{topic, partition})
+}
@@ -0,0 +1,56 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    offsets_test.go
+ * details: Deals with the Unit Test cases for the partition offset tracking
+ *
+ */
+package kafkaconsumer
+
+import (
+	"testing"
+)
+
+func TestOffsetTrackerInOrder(t *testing.T) {
+	tr := newOffsetTracker()
+	key := partitionKey{"vflow.ipfix", 0}
+	ack10 := tr.track(key.topic, key.partition, 10)
+	ack11 := tr.track(key.topic, key.partition, 11)
+	ack12 := tr.track(key.topic, key.partition, 12)
+	other := tr.track("vflow.sflow", 3, 7)
+
+	// the last message is acknowledged first, nothing can be committed
+	ack12()
+	if offsets := tr.committable(); len(offsets) != 0 {
+		t.Errorf("expected no offsets, got %v", offsets)
+	}
+	ack10()
+	if offsets := tr.committable(); len(offsets) != 1 || offsets[key] != 11 {
+		t.Errorf("expected offset 11, got %v", offsets)
+	}
+	if offsets := tr.committable(); len(offsets) != 0 {
+		t.Errorf("expected no offsets once committed, got %v", offsets)
+	}
+	ack11()
+	other()
+	offsets := tr.committable()
+	if offsets[key] != 13 || offsets[partitionKey{"vflow.sflow", 3}] != 8 {
+		t.Errorf("expected offsets 13 and 8, got %v", offsets)
+	}
+}
+
+func TestOffsetTrackerForget(t *testing.T) {
+	tr := newOffsetTracker()
+	ack := tr.track("vflow.ipfix", 1, 100)
+	tr.forget("vflow.ipfix", 1)
+	ack()
+	if offsets := tr.committable(); len(offsets) != 0 {
+		t.Errorf("expected no offsets for a revoked partition, got %v", offsets)
+	}
+	// the partition is assigned again, it is tracked from scratch
+	tr.track("vflow.ipfix", 1, 100)()
+	offsets := tr.committable()
+	if offsets[partitionKey{"vflow.ipfix", 1}] != 101 {
+		t.Errorf("expected offset 101, got %v", offsets)
+	}
+}
@@ -0,0 +1,80 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    producer.go
+ * details: Kafka producer shared by the dead letter topic and the kafka
+ *          message handler
+ *
+ */
+package kafkaconsumer
+
+import (
+	msghandler "github.com/Juniper/collector/flow-translator/msg-handler"
+	opts "github.com/Juniper/collector/flow-translator/options"
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// StartProducers creates the producer when the dead letter topic or the
+// kafka message handler are enabled, and hands it to the message handlers
+func StartProducers() {
+	if opts.KafkaDLQTopic == "" && !opts.SendToKafka {
+		return
+	}
+	p, err := kafka.NewProducer(newConfigMap(nil))
+	if err != nil {
+		opts.Logger.Fatalln("Failed to create kafka-producer ", err)
+	}
+	if opts.KafkaDLQTopic != "" {
+		startDeadLetterProducer(p)
+	}
+	if opts.SendToKafka {
+		opts.Logger.Println("Normalized records are published on",
+			opts.KafkaOutputTopic)
+		msghandler.KafkaProducer = func(records []msghandler.KafkaRecord) error {
+			return publishRecords(p, records)
+		}
+	}
+}
+
+// publishRecords publishes the records and waits for all of them to be
+// delivered, the first delivery error is returned
+func publishRecords(p *kafka.Producer, records []msghandler.KafkaRecord) error {
+	deliveryCh := make(chan kafka.Event, len(records))
+	produced := 0
+	var err error
+	for _, r := range records {
+		topic := r.Topic
+		msg := &kafka.Message{
+			TopicPartition: kafka.TopicPartition{
+				Topic:     &topic,
+				Partition: kafka.PartitionAny,
+			},
+			Key:   r.Key,
+			Value: r.Value,
+		}
+		for key, value := range r.Headers {
+			msg.Headers = append(msg.Headers,
+				kafka.Header{Key: key, Value: []byte(value)})
+		}
+		if err = p.Produce(msg, deliveryCh); err != nil {
+			break
+		}
+		produced++
+	}
+	for i := 0; i < produced; i++ {
+		if m, ok := (<-deliveryCh).(*kafka.Message); ok &&
+			m.TopicPartition.Error != nil && err == nil {
+			err = m.TopicPartition.Error
+		}
+	}
+	if e, ok := err.(kafka.Error); ok && permanentErrors[e.Code()] {
+		return msghandler.Permanent(err)
+	}
+	return err
+}
+
+// permanentErrors are the errors of the records the brokers never accept
+var permanentErrors = map[kafka.ErrorCode]bool{
+	kafka.ErrInvalidMsgSize:  true,
+	kafka.ErrMsgSizeTooLarge: true,
+}
@@ -0,0 +1,59 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    redrive.go
+ * details: Re-drives the messages of the dead letter topic through the
+ *          message handlers they failed on
+ *
+ */
+package kafkaconsumer
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Juniper/collector/flow-translator/metrics"
+	msghandler "github.com/Juniper/collector/flow-translator/msg-handler"
+	opts "github.com/Juniper/collector/flow-translator/options"
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// DLQRedrive consumes the dead letter topic up to the messages published
+// after it started, the messages failing again are published again on the
+// dead letter topic. The offsets are committed as by KafkaConsumer
+func DLQRedrive() error {
+	if opts.KafkaDLQTopic == "" {
+		return fmt.Errorf("No kafka-dlq-topic configured")
+	}
+	startTime := time.Now()
+
+	opts.Logger.Println("Re-driving the messages of", opts.KafkaDLQTopic)
+	k, err := kafka.NewConsumer(newConfigMap(kafka.ConfigMap{
+		"group.id":                        opts.StrKafkaDLQGroupID,
+		"go.events.channel.enable":        true,
+		"go.application.rebalance.enable": true,
+		"enable.auto.commit":              false,
+		"enable.partition.eof":            true,
+		"default.topic.config": kafka.ConfigMap{
+			"auto.offset.reset": "earliest",
+		},
+	}))
+	if err != nil {
+		opts.Logger.Fatalln("Failed to create kafka-consumer ", err)
+	}
+	defer k.Close()
+	k.SubscribeTopics([]string{opts.KafkaDLQTopic}, nil)
+	StartProducers()
+	metrics.StartServer()
+	msghandler.StartMsgHandlers()
+
+	b := &boundedConsumer{
+		k:         k,
+		until:     startTime,
+		toMessage: messageFromDeadLetter,
+		commit:    true,
+	}
+	redriven, err := b.run()
+	opts.Logger.Printf("Re-driven %d messages of %s", redriven, opts.KafkaDLQTopic)
+	return err
+}
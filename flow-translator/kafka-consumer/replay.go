@@ -0,0 +1,92 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    replay.go
+ * details: Replays the messages of the consumed topics published within a
+ *          time window, without committing any offset
+ *
+ */
+package kafkaconsumer
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Juniper/collector/flow-translator/metrics"
+	msghandler "github.com/Juniper/collector/flow-translator/msg-handler"
+	opts "github.com/Juniper/collector/flow-translator/options"
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+const offsetsForTimesTimeoutMs = 10000
+
+// Replay hands the messages published from the from time up to the to time
+// to the message handlers of the target, all the enabled ones if not set.
+// The messages are handed over by the forwarder of the bounded consumer, the
+// partitions are paused rather than the Kafka events blocked on full queues
+func Replay(from time.Time, to time.Time, target string) error {
+	if !from.Before(to) {
+		return fmt.Errorf("Invalid replay window, %v is not before %v", from, to)
+	}
+	switch target {
+	case "":
+	case opts.StrQueryAPI, opts.StrDataManager, opts.StrKafka:
+		opts.SendToQA = target == opts.StrQueryAPI
+		opts.SendToDM = target == opts.StrDataManager
+		opts.SendToKafka = target == opts.StrKafka
+	default:
+		return fmt.Errorf("Invalid replay target '%s'", target)
+	}
+	topics := opts.KafkaTopicList()
+
+	opts.Logger.Printf("Replaying %v from %v to %v", topics, from, to)
+	k, err := kafka.NewConsumer(newConfigMap(kafka.ConfigMap{
+		"group.id":                        opts.KafkaGroupID + "-replay",
+		"go.events.channel.enable":        true,
+		"go.application.rebalance.enable": true,
+		"enable.auto.commit":              false,
+		"enable.partition.eof":            true,
+	}))
+	if err != nil {
+		opts.Logger.Fatalln("Failed to create kafka-consumer ", err)
+	}
+	defer k.Close()
+	k.SubscribeTopics(topics, nil)
+	StartProducers()
+	metrics.StartServer()
+	msghandler.StartMsgHandlers()
+
+	b := &boundedConsumer{
+		k:     k,
+		until: to,
+		seek: func(tps []kafka.TopicPartition) ([]kafka.TopicPartition, error) {
+			return k.OffsetsForTimes(timesOf(tps, from), offsetsForTimesTimeoutMs)
+		},
+		toMessage: func(e *kafka.Message) (*msghandler.Message, error) {
+			tp := e.TopicPartition
+			return &msghandler.Message{
+				Topic:     *tp.Topic,
+				Value:     e.Value,
+				Partition: tp.Partition,
+				Offset:    int64(tp.Offset),
+			}, nil
+		},
+	}
+	replayed, err := b.run()
+	opts.Logger.Printf("Replayed %d messages of %v", replayed, topics)
+	return err
+}
+
+// timesOf returns the partitions with the time in milliseconds as offset, as
+// expected by OffsetsForTimes
+func timesOf(tps []kafka.TopicPartition, t time.Time) []kafka.TopicPartition {
+	times := make([]kafka.TopicPartition, len(tps))
+	for i, tp := range tps {
+		times[i] = kafka.TopicPartition{
+			Topic:     tp.Topic,
+			Partition: tp.Partition,
+			Offset:    kafka.Offset(t.UnixNano() / int64(time.Millisecond)),
+		}
+	}
+	return times
+}
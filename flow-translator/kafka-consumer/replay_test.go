@@ -0,0 +1,35 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    replay_test.go
+ * details: Deals with the Unit Test cases for the replay of a time window
+ *
+ */
+package kafkaconsumer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+func TestTimesOf(t *testing.T) {
+	topic := "vflow.ipfix"
+	from := time.Date(2018, 5, 1, 10, 0, 0, 5e6, time.UTC)
+	tps := []kafka.TopicPartition{
+		{Topic: &topic, Partition: 0, Offset: kafka.OffsetStored},
+		{Topic: &topic, Partition: 1, Offset: 1234},
+	}
+	times := timesOf(tps, from)
+	for i, tp := range times {
+		if *tp.Topic != topic || tp.Partition != int32(i) ||
+			int64(tp.Offset) != 1525168800005 {
+			t.Errorf("unexpected partition time %v %d %d", *tp.Topic,
+				tp.Partition, tp.Offset)
+		}
+	}
+	if tps[1].Offset != 1234 {
+		t.Errorf("the assigned partitions must not be modified")
+	}
+}
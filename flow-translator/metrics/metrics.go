@@ -0,0 +1,254 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    metrics.go
+ * details: Counters and gauges of the translator, exposed over HTTP in the
+ *          Prometheus text format
+ *
+ */
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	opts "github.com/Juniper/collector/flow-translator/options"
+)
+
+const (
+	kindCounter   = "counter"
+	kindGauge     = "gauge"
+	kindHistogram = "histogram"
+)
+
+// Vec is a metric with a value per set of label values
+type Vec struct {
+	name    string
+	help    string
+	kind    string
+	labels  []string
+	buckets []float64
+
+	mu     sync.Mutex
+	values map[string]float64
+	hists  map[string]*histogram
+}
+
+// histogram holds the observations of a set of label values, counts are
+// per bucket (not cumulative) and the values of values are their count
+type histogram struct {
+	counts []uint64
+	sum    float64
+}
+
+var (
+	registryMu sync.Mutex
+	registry   = map[string]*Vec{}
+	handlers   = map[string]http.HandlerFunc{}
+)
+
+func register(name string, help string, kind string, labels []string) *Vec {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if v, ok := registry[name]; ok {
+		return v
+	}
+	v := &Vec{
+		name:   name,
+		help:   help,
+		kind:   kind,
+		labels: labels,
+		values: make(map[string]float64),
+		hists:  make(map[string]*histogram),
+	}
+	registry[name] = v
+	return v
+}
+
+// NewCounter registers a counter with the given label names
+func NewCounter(name string, help string, labels ...string) *Vec {
+	return register(name, help, kindCounter, labels)
+}
+
+// NewGauge registers a gauge with the given label names
+func NewGauge(name string, help string, labels ...string) *Vec {
+	return register(name, help, kindGauge, labels)
+}
+
+// NewHistogram registers a histogram with the given upper bounds of its
+// buckets, in increasing order, and label names
+func NewHistogram(name string, help string, buckets []float64,
+	labels ...string) *Vec {
+	v := register(name, help, kindHistogram, labels)
+	v.buckets = buckets
+	return v
+}
+
+// key formats the label values as in the exposition format
+func (v *Vec) key(labelValues []string) string {
+	if len(labelValues) != len(v.labels) {
+		panic(fmt.Sprintf("metric %s expects %d label values, got %d",
+			v.name, len(v.labels), len(labelValues)))
+	}
+	pairs := make([]string, len(v.labels))
+	for i, l := range v.labels {
+		value := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).
+			Replace(labelValues[i])
+		pairs[i] = fmt.Sprintf(`%s="%s"`, l, value)
+	}
+	return strings.Join(pairs, ",")
+}
+
+// Add adds delta to the value of the given label values
+func (v *Vec) Add(delta float64, labelValues ...string) {
+	key := v.key(labelValues)
+	v.mu.Lock()
+	v.values[key] += delta
+	v.mu.Unlock()
+}
+
+// Inc adds one to the value of the given label values
+func (v *Vec) Inc(labelValues ...string) {
+	v.Add(1, labelValues...)
+}
+
+// Set sets the value of the given label values
+func (v *Vec) Set(value float64, labelValues ...string) {
+	key := v.key(labelValues)
+	v.mu.Lock()
+	v.values[key] = value
+	v.mu.Unlock()
+}
+
+// Observe adds a value to the histogram of the given label values
+func (v *Vec) Observe(value float64, labelValues ...string) {
+	key := v.key(labelValues)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	h, ok := v.hists[key]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(v.buckets))}
+		v.hists[key] = h
+	}
+	for i, le := range v.buckets {
+		if value <= le {
+			h.counts[i]++
+			break
+		}
+	}
+	h.sum += value
+	v.values[key]++
+}
+
+// Value returns the value of the given label values, the count of the
+// observations for the histograms
+func (v *Vec) Value(labelValues ...string) float64 {
+	key := v.key(labelValues)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.values[key]
+}
+
+func (v *Vec) write(w io.Writer) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n", v.name, v.help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", v.name, v.kind)
+	keys := make([]string, 0, len(v.values))
+	for key := range v.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if v.kind == kindHistogram {
+			v.writeHistogram(w, key)
+			continue
+		}
+		if key == "" {
+			fmt.Fprintf(w, "%s %v\n", v.name, v.values[key])
+		} else {
+			fmt.Fprintf(w, "%s{%s} %v\n", v.name, key, v.values[key])
+		}
+	}
+}
+
+// writeHistogram writes the cumulative buckets, sum and count of the
+// histogram of the given label values
+func (v *Vec) writeHistogram(w io.Writer, key string) {
+	h := v.hists[key]
+	labels := func(le string) string {
+		if key == "" {
+			return fmt.Sprintf(`{le="%s"}`, le)
+		}
+		return fmt.Sprintf(`{%s,le="%s"}`, key, le)
+	}
+	cumulative := uint64(0)
+	for i, le := range v.buckets {
+		cumulative += h.counts[i]
+		fmt.Fprintf(w, "%s_bucket%s %v\n", v.name,
+			labels(strconv.FormatFloat(le, 'g', -1, 64)), cumulative)
+	}
+	fmt.Fprintf(w, "%s_bucket%s %v\n", v.name, labels("+Inf"), v.values[key])
+	if key == "" {
+		fmt.Fprintf(w, "%s_sum %v\n%s_count %v\n", v.name, h.sum, v.name,
+			v.values[key])
+	} else {
+		fmt.Fprintf(w, "%s_sum{%s} %v\n%s_count{%s} %v\n", v.name, key, h.sum,
+			v.name, key, v.values[key])
+	}
+}
+
+// Write writes all the registered metrics in the Prometheus text format
+func Write(w io.Writer) {
+	registryMu.Lock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	registryMu.Unlock()
+	sort.Strings(names)
+	for _, name := range names {
+		registryMu.Lock()
+		v := registry[name]
+		registryMu.Unlock()
+		v.write(w)
+	}
+}
+
+// HandleFunc registers a handler served along with the metrics, e.g. the
+// debug endpoints, it has to be registered before the server is started
+func HandleFunc(pattern string, handler http.HandlerFunc) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	handlers[pattern] = handler
+}
+
+// StartServer serves the metrics on /metrics of opts.MetricsListenAddr,
+// along with the registered handlers. Nothing is done if no address is set
+func StartServer() {
+	if opts.MetricsListenAddr == "" {
+		return
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		Write(w)
+	})
+	registryMu.Lock()
+	for pattern, handler := range handlers {
+		mux.HandleFunc(pattern, handler)
+	}
+	registryMu.Unlock()
+	opts.Logger.Println("Serving metrics on", opts.MetricsListenAddr)
+	go func() {
+		err := http.ListenAndServe(opts.MetricsListenAddr, mux)
+		if err != nil {
+			opts.Logger.Fatalln("Metrics server error ", err)
+		}
+	}()
+}
@@ -0,0 +1,76 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    metrics_test.go
+ * details: Deals with the Unit Test cases for the metrics exposition
+ *
+ */
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// unregister drops the metrics registered by a previous run of the tests
+func unregister(names ...string) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	for _, name := range names {
+		delete(registry, name)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	unregister("test_dropped_total", "test_queue_depth")
+	dropped := NewCounter("test_dropped_total", "Dropped messages", "sink")
+	depth := NewGauge("test_queue_depth", "Queue depth", "sink")
+	dropped.Inc("query-api")
+	dropped.Add(2, "query-api")
+	dropped.Inc(`data"manager`)
+	depth.Set(42, "query-api")
+	if NewCounter("test_dropped_total", "Dropped messages", "sink") != dropped {
+		t.Errorf("expected the registered counter to be returned")
+	}
+
+	var b bytes.Buffer
+	Write(&b)
+	expected := []string{
+		"# HELP test_dropped_total Dropped messages\n" +
+			"# TYPE test_dropped_total counter\n" +
+			"test_dropped_total{sink=\"data\\\"manager\"} 1\n" +
+			"test_dropped_total{sink=\"query-api\"} 3\n",
+		"# TYPE test_queue_depth gauge\n" +
+			"test_queue_depth{sink=\"query-api\"} 42\n",
+	}
+	for _, e := range expected {
+		if !strings.Contains(b.String(), e) {
+			t.Errorf("expected '%s' in '%s'", e, b.String())
+		}
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	unregister("test_lookup_seconds")
+	latency := NewHistogram("test_lookup_seconds", "Lookup latency",
+		[]float64{0.001, 0.01}, "table")
+	for _, value := range []float64{0.0005, 0.001, 0.005, 0.5} {
+		latency.Observe(value, "asn")
+	}
+	if count := latency.Value("asn"); count != 4 {
+		t.Errorf("expected a count of 4, got %v", count)
+	}
+
+	var b bytes.Buffer
+	Write(&b)
+	expected := "# TYPE test_lookup_seconds histogram\n" +
+		"test_lookup_seconds_bucket{table=\"asn\",le=\"0.001\"} 2\n" +
+		"test_lookup_seconds_bucket{table=\"asn\",le=\"0.01\"} 3\n" +
+		"test_lookup_seconds_bucket{table=\"asn\",le=\"+Inf\"} 4\n" +
+		"test_lookup_seconds_sum{table=\"asn\"} 0.5065\n" +
+		"test_lookup_seconds_count{table=\"asn\"} 4\n"
+	if !strings.Contains(b.String(), expected) {
+		t.Errorf("expected '%s' in '%s'", expected, b.String())
+	}
+}
@@ -0,0 +1,194 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    channels.go
+ * details: Manages the channels between the message sources (Kafka, UDP
+ *          listeners) and the message handlers
+ *
+ */
+package msghandler
+
+import (
+	"fmt"
+	"hash/fnv"
+
+	opts "github.com/Juniper/collector/flow-translator/options"
+)
+
+// consChannel holds the queues of a message handler, one per worker. The
+// messages of an exporter always go to the same worker to keep their order
+type consChannel struct {
+	chConsName string
+	enabled    *bool
+	queues     []*sinkQueue
+	outChs     []chan *Message
+}
+
+var consChannels []consChannel
+
+func newConsChannel(name string, enabled *bool, workers int) consChannel {
+	ch := consChannel{chConsName: name, enabled: enabled}
+	// the capacity of the message handler is shared among its workers
+	capacity := (opts.SinkQueueSize + workers - 1) / workers
+	for i := 0; i < workers; i++ {
+		ch.queues = append(ch.queues, newSinkQueue(name, capacity,
+			opts.SinkQueuePolicy))
+		ch.outChs = append(ch.outChs, make(chan *Message))
+	}
+	return ch
+}
+
+func initConsChannels() {
+	consChannels = []consChannel{
+		newConsChannel(opts.StrDataManager, &opts.SendToDM, opts.SinkWorkers),
+		newConsChannel(opts.StrQueryAPI, &opts.SendToQA, opts.SinkWorkers),
+		newConsChannel(opts.StrKafka, &opts.SendToKafka, opts.SinkWorkers),
+	}
+}
+
+// StartMsgHandlers creates the channels and starts all the message handlers,
+// messages can be sent to them using SendToInChannels afterwards
+func StartMsgHandlers() {
+	if !opts.SendToDM && !opts.SendToQA && !opts.SendToKafka {
+		opts.Logger.Fatalf("No message handler enabled, the messages would " +
+			"never be acknowledged")
+	}
+	if err := openGeoIPDatabases(); err != nil {
+		opts.Logger.Fatalf("GeoIP database open error: %v", err)
+	}
+	if err := openPrefixASNTable(); err != nil {
+		opts.Logger.Fatalf("Prefix to AS table open error: %v", err)
+	}
+	if err := openInterfaceInventory(); err != nil {
+		opts.Logger.Fatalf("Interface inventory open error: %v", err)
+	}
+	manageChannels()
+	registerMsgHandlers()
+}
+
+func manageChannels() {
+	initConsChannels()
+	for _, ch := range consChannels {
+		for i := range ch.queues {
+			manageOutChannels(ch.queues[i], ch.outChs[i])
+		}
+	}
+}
+
+// accepts returns true if the message is to be sent to the message handler
+func (ch consChannel) accepts(msg *Message) bool {
+	return *ch.enabled && (msg.Sink == "" || msg.Sink == ch.chConsName)
+}
+
+// push queues the message for the worker of its exporter
+func (ch consChannel) push(msg *Message) {
+	if len(ch.queues) == 1 {
+		ch.queues[0].push(msg)
+		return
+	}
+	h := fnv.New32a()
+	h.Write([]byte(msg.Key))
+	ch.queues[h.Sum32()%uint32(len(ch.queues))].push(msg)
+}
+
+func (ch consChannel) isFull() bool {
+	for _, q := range ch.queues {
+		if q.len() >= q.capacity {
+			return true
+		}
+	}
+	return false
+}
+
+func (ch consChannel) isDrained() bool {
+	for _, q := range ch.queues {
+		if q.len() > q.capacity/2 {
+			return false
+		}
+	}
+	return true
+}
+
+// SendToInChannels sends the message to all the enabled message handlers,
+// msg.OnAck is called once all of them have acknowledged it. A message no
+// enabled message handler accepts is never acknowledged and an error is
+// returned. With the block queue policy it blocks while the queue of a
+// message handler is full
+func SendToInChannels(msg *Message) error {
+	var targets []consChannel
+	for _, ch := range consChannels {
+		if ch.accepts(msg) {
+			targets = append(targets, ch)
+		}
+	}
+	if len(targets) == 0 {
+		if msg.Sink != "" {
+			return fmt.Errorf("Message handler %s of the message is not enabled",
+				msg.Sink)
+		}
+		return fmt.Errorf("No message handler enabled")
+	}
+	if msg.Key == "" && opts.SinkWorkers > 1 {
+		msg.Key = agentID(msg.Value)
+	}
+	msg.expectAcks(len(targets))
+	if opts.Verbose {
+		opts.Logger.Println("Data Queued:", string(msg.Value))
+	}
+	for _, ch := range targets {
+		ch.push(msg)
+	}
+	return nil
+}
+
+// QueuesFull returns true when a queue of an enabled message handler is
+// full, the sources are expected to stop reading until QueuesDrained
+func QueuesFull() bool {
+	for _, ch := range consChannels {
+		if *ch.enabled && ch.isFull() {
+			return true
+		}
+	}
+	return false
+}
+
+// QueuesDrained returns true when the queues of the enabled message handlers
+// are back under half of their capacity
+func QueuesDrained() bool {
+	for _, ch := range consChannels {
+		if *ch.enabled && !ch.isDrained() {
+			return false
+		}
+	}
+	return true
+}
+
+func manageOutChannels(queue *sinkQueue, outCh chan *Message) {
+	go func() {
+		for {
+			msg := queue.pop()
+			if opts.Verbose {
+				opts.Logger.Println("Data served from Queue:", string(msg.Value))
+			}
+			outCh <- msg
+		}
+	}()
+}
+
+func registerMsgHandlers() {
+	// Register all the message handlers here
+	conChannelList := consChannels
+	conChannelLen := len(conChannelList)
+	for i := 0; i < conChannelLen; i++ {
+		if !*conChannelList[i].enabled {
+			continue
+		}
+		go func(i int) {
+			mh := NewMsgHandler(conChannelList[i].chConsName)
+			mh.MHChans = conChannelList[i].outChs
+			if err := mh.Run(); err != nil {
+				opts.Logger.Fatalf("msgHandler run error %v ", err)
+			}
+		}(i)
+	}
+}
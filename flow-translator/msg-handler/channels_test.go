@@ -0,0 +1,89 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    channels_test.go
+ * details: Deals with the Unit Test cases for the sharding of the messages
+ *          among the workers of a message handler
+ *
+ */
+package msghandler
+
+import (
+	"testing"
+
+	opts "github.com/Juniper/collector/flow-translator/options"
+)
+
+func TestAgentID(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    []byte
+		expected string
+	}{
+		{
+			name:     "IPFIX message",
+			value:    MockData[StrTestValidIPFIXMessage],
+			expected: "10.84.30.149",
+		},
+		{
+			name:     "sFlow message",
+			value:    []byte(`{"Header":{"IPAddress":"10.1.1.1"}}`),
+			expected: "10.1.1.1",
+		},
+		{
+			name:     "Spaces and escapes",
+			value:    []byte(`{"AgentID" : "router\u002d1", "DataSets": []}`),
+			expected: "router-1",
+		},
+		{
+			name:     "Not a string",
+			value:    []byte(`{"AgentID":1,"Header":{"IPAddress":"10.1.1.1"}}`),
+			expected: "10.1.1.1",
+		},
+		{
+			name:     "Invalid message",
+			value:    []byte(`{"AgentID":`),
+			expected: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			VerifyError(tt.name, t, tt.expected, agentID(tt.value))
+		})
+	}
+}
+
+func TestConsChannelSharding(t *testing.T) {
+	defer func(size int, policy string) {
+		opts.SinkQueueSize, opts.SinkQueuePolicy = size, policy
+	}(opts.SinkQueueSize, opts.SinkQueuePolicy)
+	opts.SinkQueueSize, opts.SinkQueuePolicy = 100, opts.QueuePolicyBlock
+
+	enabled := true
+	ch := newConsChannel("test-sharding", &enabled, 4)
+	VerifyError("Workers", t, 4, len(ch.queues))
+	VerifyError("Capacity", t, 25, ch.queues[0].capacity)
+
+	keys := []string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.1",
+		"10.0.0.2", "10.0.0.1"}
+	for i, key := range keys {
+		ch.push(&Message{Key: key, Value: []byte{byte(i)}})
+	}
+	// the messages of an exporter are all in the same queue, in order
+	shard := map[string]int{}
+	last := map[string]int{}
+	for i, q := range ch.queues {
+		for q.len() > 0 {
+			msg := q.pop()
+			if s, ok := shard[msg.Key]; ok && s != i {
+				t.Errorf("exporter %s in queues %d and %d", msg.Key, s, i)
+			}
+			if l, ok := last[msg.Key]; ok && l > int(msg.Value[0]) {
+				t.Errorf("exporter %s messages out of order", msg.Key)
+			}
+			shard[msg.Key] = i
+			last[msg.Key] = int(msg.Value[0])
+		}
+	}
+	VerifyError("Exporters", t, 3, len(shard))
+}
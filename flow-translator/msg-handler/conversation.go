@@ -0,0 +1,204 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    conversation.go
+ * details: Stitches the flows of both directions of a 5-tuple into
+ *          conversation records, the biflows are conversations as they are
+ *
+ */
+package msghandler
+
+import (
+	"sync"
+	"time"
+
+	opts "github.com/Juniper/collector/flow-translator/options"
+)
+
+// stitchMaxFlows bounds the flows waiting for their reverse flow, the oldest
+// are dropped beyond it
+const stitchMaxFlows = 1 << 20
+
+// ConversationRecord is the record of both directions of a flow. The source
+// is the initiator, i.e. the source of the earlier flow or of the forward
+// direction of a biflow. Exporter is the exporter of the forward flow and
+// ReverseExporter the one of the reverse flow, if stitched
+type ConversationRecord struct {
+	Collection string `json:"-"`
+
+	Exporter        string `json:"Exporter"`
+	ReverseExporter string `json:"ReverseExporter,omitempty"`
+	SrcAddr         string `json:"SrcAddr"`
+	DstAddr         string `json:"DstAddr"`
+	SrcPort         uint16 `json:"SrcPort"`
+	DstPort         uint16 `json:"DstPort"`
+	Protocol        uint8  `json:"Protocol"`
+	Bytes           uint64 `json:"Bytes"`
+	Packets         uint64 `json:"Packets"`
+	ReverseBytes    uint64 `json:"ReverseBytes"`
+	ReversePackets  uint64 `json:"ReversePackets"`
+	Start           int64  `json:"Start"`
+	End             int64  `json:"End"`
+	Timestamp       int64  `json:"Timestamp"`
+	Biflow          bool   `json:"Biflow"`
+}
+
+func (rec *ConversationRecord) collection() string {
+	return rec.Collection
+}
+
+type endpoint struct {
+	addr string
+	port uint16
+}
+
+// conversationKey is the 5-tuple of a flow with its endpoints in order, the
+// same for both directions
+type conversationKey struct {
+	low, high endpoint
+	protocol  uint8
+}
+
+func newConversationKey(rec *FlowRecord) (conversationKey, bool) {
+	src := endpoint{rec.SrcAddr, rec.SrcPort}
+	dst := endpoint{rec.DstAddr, rec.DstPort}
+	if dst.addr < src.addr || dst.addr == src.addr && dst.port < src.port {
+		return conversationKey{dst, src, rec.Protocol}, false
+	}
+	return conversationKey{src, dst, rec.Protocol}, true
+}
+
+type pendingFlow struct {
+	rec     *FlowRecord
+	forward bool
+}
+
+type queuedFlow struct {
+	key conversationKey
+	rec *FlowRecord
+}
+
+// stitcher pairs the flows of both directions seen within the window. The
+// latest flow of every direction waits for its reverse flow, the flows which
+// are not paired within the window are dropped from the stitcher
+type stitcher struct {
+	window  int64
+	mu      sync.Mutex
+	pending map[conversationKey]pendingFlow
+	queue   []queuedFlow
+	latest  int64
+}
+
+func newStitcher(window time.Duration) *stitcher {
+	return &stitcher{
+		window:  int64(window / time.Millisecond),
+		pending: map[conversationKey]pendingFlow{},
+	}
+}
+
+// flowTime is the start of the flow, or the time it was exported at
+func flowTime(rec *FlowRecord) int64 {
+	if rec.Start > 0 {
+		return rec.Start
+	}
+	return rec.Timestamp
+}
+
+// add returns the conversation of a biflow, or of a flow and its reverse
+// flow if seen within the window, nil otherwise
+func (st *stitcher) add(rec *FlowRecord) *ConversationRecord {
+	if rec.SrcAddr == "" || rec.DstAddr == "" {
+		return nil
+	}
+	if rec.biflow() {
+		conv := newConversation(rec)
+		conv.ReverseBytes = rec.ReverseBytes
+		conv.ReversePackets = rec.ReversePackets
+		conv.Biflow = true
+		return conv
+	}
+	key, forward := newConversationKey(rec)
+	t := flowTime(rec)
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if t > st.latest {
+		st.latest = t
+	}
+	st.expire()
+	if p, ok := st.pending[key]; ok && p.forward != forward &&
+		abs(t-flowTime(p.rec)) <= st.window {
+		delete(st.pending, key)
+		if flowTime(p.rec) <= t {
+			return stitch(p.rec, rec)
+		}
+		return stitch(rec, p.rec)
+	}
+	// the raw fields of the waiting flows are not needed
+	flow := *rec
+	flow.Header, flow.DataSets, flow.ExtSWData = nil, nil, nil
+	flow.Packet, flow.Sample = nil, nil
+	st.pending[key] = pendingFlow{&flow, forward}
+	st.queue = append(st.queue, queuedFlow{key, &flow})
+	return nil
+}
+
+// expire drops the flows older than the window, as per the latest flow, and
+// the oldest flows beyond stitchMaxFlows
+func (st *stitcher) expire() {
+	n := 0
+	for ; n < len(st.queue); n++ {
+		q := st.queue[n]
+		if flowTime(q.rec) >= st.latest-st.window &&
+			len(st.queue)-n <= stitchMaxFlows {
+			break
+		}
+		// the flow may have been paired or replaced since it was queued
+		if p, ok := st.pending[q.key]; ok && p.rec == q.rec {
+			delete(st.pending, q.key)
+		}
+		st.queue[n] = queuedFlow{}
+	}
+	st.queue = st.queue[n:]
+}
+
+func newConversation(rec *FlowRecord) *ConversationRecord {
+	return &ConversationRecord{
+		Collection: opts.ConversationColl,
+		Exporter:   rec.Exporter,
+		SrcAddr:    rec.SrcAddr,
+		DstAddr:    rec.DstAddr,
+		SrcPort:    rec.SrcPort,
+		DstPort:    rec.DstPort,
+		Protocol:   rec.Protocol,
+		Bytes:      rec.Bytes,
+		Packets:    rec.Packets,
+		Start:      rec.Start,
+		End:        rec.End,
+		Timestamp:  rec.Timestamp,
+	}
+}
+
+// stitch returns the conversation of a flow and its reverse flow
+func stitch(fwd, rev *FlowRecord) *ConversationRecord {
+	conv := newConversation(fwd)
+	conv.ReverseExporter = rev.Exporter
+	conv.ReverseBytes = rev.Bytes
+	conv.ReversePackets = rev.Packets
+	if rev.Start > 0 && (conv.Start == 0 || rev.Start < conv.Start) {
+		conv.Start = rev.Start
+	}
+	if rev.End > conv.End {
+		conv.End = rev.End
+	}
+	if rev.Timestamp > conv.Timestamp {
+		conv.Timestamp = rev.Timestamp
+	}
+	return conv
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
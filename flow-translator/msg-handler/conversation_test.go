@@ -0,0 +1,91 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    conversation_test.go
+ * details: Deals with the Unit Test cases for the biflows and the stitching
+ *          of the flows into conversations
+ *
+ */
+package msghandler
+
+import (
+	"testing"
+	"time"
+
+	opts "github.com/Juniper/collector/flow-translator/options"
+)
+
+const testBiflowMessage = `{"AgentID":"10.84.30.150","Header":{"Version":10},
+"DataSets":[{"sourceIPv4Address":"10.1.1.1","destinationIPv4Address":"10.2.2.2",
+"sourceTransportPort":40000,"destinationTransportPort":443,
+"protocolIdentifier":6,"octetDeltaCount":1200,"packetDeltaCount":10,
+"29305:1":"0x0000000000004e20","reversePacketDeltaCount":16}],
+"Timestamp":1522035620663000}`
+
+func TestDecodeIPFIXBiflow(t *testing.T) {
+	got, err := DecodeIPFIXRecords([]byte(testBiflowMessage))
+	if err != nil || len(got) != 1 {
+		t.Fatalf("DecodeIPFIXRecords failed: %v %v", got, err)
+	}
+	rec := got[0]
+	VerifyError("Bytes", t, uint64(1200), rec.Bytes)
+	VerifyError("Packets", t, uint64(10), rec.Packets)
+	VerifyError("ReverseBytes", t, uint64(20000), rec.ReverseBytes)
+	VerifyError("ReversePackets", t, uint64(16), rec.ReversePackets)
+	if _, ok := rec.DataSets["reverseOctetDeltaCount"]; !ok {
+		t.Errorf("DataSets expected reverseOctetDeltaCount, got %v", rec.DataSets)
+	}
+
+	conv := newStitcher(time.Second).add(&rec)
+	if conv == nil {
+		t.Fatalf("Biflow expected a conversation")
+	}
+	expected := ConversationRecord{Collection: opts.ConversationColl,
+		Exporter: "10.84.30.150", SrcAddr: "10.1.1.1", DstAddr: "10.2.2.2",
+		SrcPort: 40000, DstPort: 443, Protocol: 6, Bytes: 1200, Packets: 10,
+		ReverseBytes: 20000, ReversePackets: 16, Timestamp: 1522035620663,
+		Biflow: true}
+	VerifyError("Biflow conversation", t, expected, *conv)
+}
+
+func TestStitcher(t *testing.T) {
+	flow := func(exporter, src, dst string, srcPort, dstPort uint16, start int64, bytes uint64) *FlowRecord {
+		return &FlowRecord{Exporter: exporter, SrcAddr: src, DstAddr: dst,
+			SrcPort: srcPort, DstPort: dstPort, Protocol: 17, Bytes: bytes,
+			Packets: 1, Start: start, End: start + 100, Timestamp: start + 500}
+	}
+	st := newStitcher(time.Second)
+	tests := []struct {
+		name     string
+		rec      *FlowRecord
+		expected *ConversationRecord
+	}{
+		{"first direction", flow("r1", "10.1.1.1", "10.2.2.2", 5000, 53, 10000, 80), nil},
+		{"other flow", flow("r1", "10.1.1.1", "10.3.3.3", 5000, 53, 10100, 80), nil},
+		{"reverse direction", flow("r2", "10.2.2.2", "10.1.1.1", 53, 5000, 10200, 300),
+			&ConversationRecord{Collection: opts.ConversationColl,
+				Exporter: "r1", ReverseExporter: "r2", SrcAddr: "10.1.1.1",
+				DstAddr: "10.2.2.2", SrcPort: 5000, DstPort: 53, Protocol: 17,
+				Bytes: 80, Packets: 1, ReverseBytes: 300, ReversePackets: 1,
+				Start: 10000, End: 10300, Timestamp: 10700}},
+		{"paired once", flow("r2", "10.2.2.2", "10.1.1.1", 53, 5000, 10300, 300), nil},
+		{"same direction", flow("r2", "10.2.2.2", "10.1.1.1", 53, 5000, 10400, 300), nil},
+		{"out of window", flow("r1", "10.3.3.3", "10.1.1.1", 53, 5000, 11200, 90), nil},
+		{"reverse first", flow("r1", "10.1.1.1", "10.2.2.2", 5000, 53, 10350, 60),
+			&ConversationRecord{Collection: opts.ConversationColl,
+				Exporter: "r1", ReverseExporter: "r2", SrcAddr: "10.1.1.1",
+				DstAddr: "10.2.2.2", SrcPort: 5000, DstPort: 53, Protocol: 17,
+				Bytes: 60, Packets: 1, ReverseBytes: 300, ReversePackets: 1,
+				Start: 10350, End: 10500, Timestamp: 10900}},
+		{"expired", flow("r1", "10.3.3.3", "10.1.1.1", 53, 5000, 12300, 90), nil},
+	}
+	for _, tt := range tests {
+		conv := st.add(tt.rec)
+		if (conv == nil) != (tt.expected == nil) ||
+			conv != nil && *conv != *tt.expected {
+			t.Errorf("%s: expected %+v, got %+v", tt.name, tt.expected, conv)
+		}
+	}
+	// the first flow of 10.3.3.3 expired, the second one is waiting
+	VerifyError("pending", t, 1, len(st.pending))
+}
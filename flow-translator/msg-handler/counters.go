@@ -0,0 +1,133 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    counters.go
+ * details: The interface counters records of the sFlow counter samples,
+ *          along with their deltas and rates since the previous sample
+ *
+ */
+package msghandler
+
+import (
+	"sync"
+)
+
+// counterWidths are the counters of the generic and ethernet interface
+// counters records and their width in bits, for the wrap of the deltas
+var counterWidths = map[string]uint{
+	"InOctets":                  64,
+	"InUcastPkts":               32,
+	"InMulticastPkts":           32,
+	"InBroadcastPkts":           32,
+	"InDiscards":                32,
+	"InErrors":                  32,
+	"InUnknownProtos":           32,
+	"OutOctets":                 64,
+	"OutUcastPkts":              32,
+	"OutMulticastPkts":          32,
+	"OutBroadcastPkts":          32,
+	"OutDiscards":               32,
+	"OutErrors":                 32,
+	"AlignmentErrors":           32,
+	"FCSErrors":                 32,
+	"SingleCollisionFrames":     32,
+	"MultipleCollisionFrames":   32,
+	"SQETestErrors":             32,
+	"DeferredTransmissions":     32,
+	"LateCollisions":            32,
+	"ExcessiveCollisions":       32,
+	"InternalMacTransmitErrors": 32,
+	"CarrierSenseErrors":        32,
+	"FrameTooLongs":             32,
+	"InternalMacReceiveErrors":  32,
+	"SymbolErrors":              32,
+}
+
+// CounterRecord is the record of the interface counters of an sFlow counter
+// sample, keyed by the agent and the ifIndex. Deltas and Rates (per second)
+// are set from the second sample of an interface on, Interval is the time
+// since the previous sample in milliseconds as per the agent uptime
+type CounterRecord struct {
+	Collection string `json:"-"`
+
+	Exporter    string             `json:"Exporter"`
+	IfIndex     uint32             `json:"IfIndex"`
+	IfType      uint32             `json:"IfType"`
+	IfSpeed     uint64             `json:"IfSpeed"`
+	IfDirection uint32             `json:"IfDirection"`
+	IfStatus    uint32             `json:"IfStatus"`
+	SequenceNo  uint32             `json:"SequenceNo"`
+	SysUpTime   uint32             `json:"SysUpTime"`
+	Timestamp   int64              `json:"Timestamp"`
+	Counters    map[string]uint64  `json:"Counters"`
+	Interval    int64              `json:"Interval,omitempty"`
+	Deltas      map[string]uint64  `json:"Deltas,omitempty"`
+	Rates       map[string]float64 `json:"Rates,omitempty"`
+
+	// The name, description and role of the interface as per the interface
+	// inventory
+	IfName        string `json:"IfName"`
+	IfDescription string `json:"IfDescription"`
+	IfRole        string `json:"IfRole"`
+}
+
+func (rec *CounterRecord) collection() string {
+	return rec.Collection
+}
+
+type counterKey struct {
+	exporter string
+	ifIndex  uint32
+}
+
+type counterState struct {
+	sequenceNo uint32
+	sysUpTime  uint32
+	counters   map[string]uint64
+}
+
+// counterTracker keeps the last counters of every interface, every message
+// handler has its own as they all see every sample
+type counterTracker struct {
+	mu   sync.Mutex
+	last map[counterKey]counterState
+}
+
+func newCounterTracker() *counterTracker {
+	return &counterTracker{last: map[counterKey]counterState{}}
+}
+
+// update sets the deltas and the rates of the record since the previous
+// sample of the interface. There are none for the first sample, nor after
+// the agent restarted, i.e. when the sequence number or the uptime went
+// backwards
+func (ct *counterTracker) update(rec *CounterRecord) {
+	key := counterKey{rec.Exporter, rec.IfIndex}
+	ct.mu.Lock()
+	prev, ok := ct.last[key]
+	ct.last[key] = counterState{rec.SequenceNo, rec.SysUpTime, rec.Counters}
+	ct.mu.Unlock()
+	if !ok {
+		return
+	}
+	seqDiff := rec.SequenceNo - prev.sequenceNo
+	interval := rec.SysUpTime - prev.sysUpTime
+	if seqDiff == 0 || seqDiff >= 1<<31 || interval == 0 || interval >= 1<<31 {
+		return
+	}
+	rec.Interval = int64(interval)
+	rec.Deltas = make(map[string]uint64, len(rec.Counters))
+	rec.Rates = make(map[string]float64, len(rec.Counters))
+	for name, value := range rec.Counters {
+		last, ok := prev.counters[name]
+		if !ok {
+			continue
+		}
+		delta := value - last
+		if counterWidths[name] == 32 {
+			delta = uint64(uint32(value) - uint32(last))
+		}
+		rec.Deltas[name] = delta
+		rec.Rates[name] = float64(delta) * 1000 / float64(interval)
+	}
+}
@@ -0,0 +1,88 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    counters_test.go
+ * details: Deals with the Unit Test cases for the sFlow interface counters
+ *
+ */
+package msghandler
+
+import (
+	"testing"
+
+	opts "github.com/Juniper/collector/flow-translator/options"
+)
+
+func TestDecodeSFlowCounters(t *testing.T) {
+	got, err := DecodeSFlowCounters(MockData[StrTestSFlowCountersMessage])
+	if err != nil || len(got) != 1 {
+		t.Fatalf("DecodeSFlowCounters failed: %v %v", got, err)
+	}
+	rec := got[0]
+	VerifyError("Collection", t, opts.SFLOWCounters, rec.Collection)
+	VerifyError("Exporter", t, "10.84.30.141", rec.Exporter)
+	VerifyError("IfIndex", t, uint32(505), rec.IfIndex)
+	VerifyError("IfSpeed", t, uint64(1000000000), rec.IfSpeed)
+	VerifyError("SequenceNo", t, uint32(42), rec.SequenceNo)
+	VerifyError("Timestamp", t, int64(1522108407531), rec.Timestamp)
+	VerifyError("InOctets", t, uint64(4000000000), rec.Counters["InOctets"])
+	VerifyError("OutErrors", t, uint64(10), rec.Counters["OutErrors"])
+	VerifyError("SymbolErrors", t, uint64(13), rec.Counters["SymbolErrors"])
+	VerifyError("Counters", t, len(counterWidths), len(rec.Counters))
+
+	// the counters messages have no flow record and vice versa
+	flows, err := DecodeSFlowRecords(MockData[StrTestSFlowCountersMessage])
+	VerifyError("flows", t, 0, len(flows))
+	counters, err := DecodeSFlowCounters(MockData[StrTestValidSFlowMessage])
+	VerifyError("counters", t, 0, len(counters))
+}
+
+func TestCounterTracker(t *testing.T) {
+	sample := func(seq uint32, upTime uint32, inOctets uint64, inErrors uint64) *CounterRecord {
+		return &CounterRecord{Exporter: "10.84.30.141", IfIndex: 505,
+			SequenceNo: seq, SysUpTime: upTime,
+			Counters: map[string]uint64{"InOctets": inOctets, "InErrors": inErrors}}
+	}
+	tests := []struct {
+		name     string
+		rec      *CounterRecord
+		deltas   bool
+		interval int64
+		octets   uint64
+		errors   uint64
+		rate     float64
+	}{
+		{"first sample", sample(1, 4294920000, 1000, 10), false, 0, 0, 0, 0},
+		{"second sample", sample(2, 4294940000, 5000, 15), true, 20000, 4000, 5, 200},
+		{"32-bit wrap", sample(3, 4294960000, 9000, 3), true, 20000, 4000, 1<<32 - 12, 200},
+		{"uptime wrap", sample(4, 12704, 19000, 3), true, 20000, 10000, 0, 500},
+		{"duplicate", sample(4, 12704, 19000, 3), false, 0, 0, 0, 0},
+		{"agent restart", sample(1, 5000, 100, 0), false, 0, 0, 0, 0},
+		{"after restart", sample(2, 25000, 200, 1), true, 20000, 100, 1, 5},
+	}
+	ct := newCounterTracker()
+	for _, tt := range tests {
+		ct.update(tt.rec)
+		if (tt.rec.Deltas != nil) != tt.deltas {
+			t.Errorf("%s expected deltas %v, got %v", tt.name, tt.deltas, tt.rec.Deltas)
+			continue
+		}
+		if !tt.deltas {
+			continue
+		}
+		VerifyError(tt.name+" interval", t, tt.interval, tt.rec.Interval)
+		VerifyError(tt.name+" octets", t, tt.octets, tt.rec.Deltas["InOctets"])
+		VerifyError(tt.name+" errors", t, tt.errors, tt.rec.Deltas["InErrors"])
+		if tt.rate != 0 {
+			VerifyError(tt.name+" rate", t, tt.rate, tt.rec.Rates["InOctets"])
+		}
+	}
+
+	// interfaces are tracked independently
+	other := sample(9, 90000, 1, 1)
+	other.IfIndex = 506
+	ct.update(other)
+	if other.Deltas != nil {
+		t.Errorf("expected no deltas for the first sample of another interface")
+	}
+}
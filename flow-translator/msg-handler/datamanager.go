@@ -8,11 +8,11 @@
 package msghandler
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
-	"strings"
 	"time"
 
 	opts "github.com/Juniper/collector/flow-translator/options"
@@ -21,6 +21,7 @@ import (
 // DataManager structure
 type DataManager struct {
 	netClient *http.Client
+	stages    *recordStages
 }
 
 // DMMessage structure as the data needs to be pushed to DM
@@ -30,48 +31,97 @@ type DMMessage struct {
 	TailwindManager interface{} `json:"tailwind_manager"`
 }
 
+// dmRecord is a flow record along with the room of the exporter
+type dmRecord struct {
+	*FlowRecord
+	RoomKey string `json:"roomKey"`
+}
+
+// dmCounterRecord is a counters record along with the room of the exporter
+type dmCounterRecord struct {
+	*CounterRecord
+	RoomKey string `json:"roomKey"`
+}
+
+// dmConversationRecord is a conversation record along with the room of the
+// exporter
+type dmConversationRecord struct {
+	*ConversationRecord
+	RoomKey string `json:"roomKey"`
+}
+
+// dmExporterStatsRecord is an exporter stats record along with the room of
+// the exporter
+type dmExporterStatsRecord struct {
+	*ExporterStatsRecord
+	RoomKey string `json:"roomKey"`
+}
+
 func (dm *DataManager) setup() error {
 	dm.netClient = &http.Client{
 		Timeout: time.Second * 10,
 	}
+	dm.stages = newRecordStages(opts.StrDataManager)
 	return nil
 }
 
-func (dm *DataManager) handleMessages(mhChan chan []byte) {
+func (dm *DataManager) handleMessages(mhChan chan *Message) {
 	var (
-		msg []byte
+		msg *Message
 	)
 	for {
 		select {
 		case msg = <-mhChan:
 			if opts.Verbose {
-				opts.Logger.Println("Received Message on DM Handler ", string(msg))
+				opts.Logger.Println("Received Message on DM Handler ",
+					msg.Topic, string(msg.Value))
 			}
 			dm.pushDataToDataManagerByTopic(msg)
+			msg.ack()
 		}
 	}
 }
 
-func (dm *DataManager) serializeDataByTopic(msg []byte) ([]DMMessage, error) {
-	if opts.KafkaTopic == opts.KafkaTopicVFlowIPFIX {
-		return SerializeIPFIXMsgInDM(msg)
-	} else if opts.KafkaTopic == opts.KafkaTopicVFlowSFlow {
-		return SerializeSFlowMsgInDM(msg)
-	} else {
-		opts.Logger.Println("Not supported Topic:", opts.KafkaTopic)
+func (dm *DataManager) serializeDataByTopic(msg *Message) ([]DMMessage, error) {
+	records, err := recordsByTopic(msg, dm.stages)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]DMMessage, len(records))
+	for i, rec := range records {
+		var data interface{}
+		switch r := rec.(type) {
+		case *FlowRecord:
+			data = dmRecord{r, r.Exporter}
+		case *CounterRecord:
+			data = dmCounterRecord{r, r.Exporter}
+		case *ConversationRecord:
+			data = dmConversationRecord{r, r.Exporter}
+		case *ExporterStatsRecord:
+			data = dmExporterStatsRecord{r, r.Exporter}
+		}
+		res[i] = DMMessage{CollectionName: rec.collection(), Data: data,
+			TailwindManager: &struct{}{}}
 	}
-	return nil, nil
+	return res, nil
 }
 
-func (dm *DataManager) pushDataToDataManagerByTopic(msg []byte) {
+func (dm *DataManager) pushDataToDataManagerByTopic(msg *Message) {
 	augMsgs, err := dm.serializeDataByTopic(msg)
 	if err != nil {
 		opts.Logger.Println("DM -> data serialize error ", err)
+		deadLetter(msg, opts.StrDataManager, StageDecode, err)
 		return
 	}
-	dm.pushDataToDataManager(augMsgs)
+	if err := dm.pushDataToDataManager(augMsgs); err != nil {
+		opts.Logger.Println("DM -> giving up on message ", err)
+		deadLetter(msg, opts.StrDataManager, StageDelivery, err)
+	}
 }
 
+// pushDataToDataManager posts the records one by one, a record is retried
+// until it is accepted so that none of the records of the message is lost.
+// The message is given up on when a record is rejected
 func (dm *DataManager) pushDataToDataManager(dmMsgs []DMMessage) error {
 	var (
 		reqUrl      string
@@ -84,23 +134,33 @@ func (dm *DataManager) pushDataToDataManager(dmMsgs []DMMessage) error {
 		dmMsg, err := json.Marshal(dmMsgs[idx])
 		if err != nil {
 			opts.Logger.Println("data json.Marshal() error ", err)
+			continue
 		}
 		if opts.Verbose {
 			opts.Logger.Println("Sending POST data to DM ", reqUrl, string(dmMsg))
 		}
-		response, err := dm.netClient.Post(reqUrl, contentType, strings.NewReader(string(dmMsg)))
+		err = withRetry("DataManager", func() error {
+			return dm.post(reqUrl, contentType, dmMsg)
+		})
 		if err != nil {
-			opts.Logger.Println("DataManager POST error ", err)
-		} else {
-			defer response.Body.Close()
-			body, err := ioutil.ReadAll(response.Body)
-			if err != nil {
-				opts.Logger.Fatalln("Parse error response body ", err)
-			}
-			if opts.Verbose {
-				opts.Logger.Println("Getting response from DM ", string(body))
-			}
+			return err
 		}
 	}
 	return nil
 }
+
+func (dm *DataManager) post(reqUrl string, contentType string, dmMsg []byte) error {
+	response, err := dm.netClient.Post(reqUrl, contentType, bytes.NewReader(dmMsg))
+	if err != nil {
+		return fmt.Errorf("DataManager POST error %v", err)
+	}
+	defer response.Body.Close()
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return fmt.Errorf("Parse error response body %v", err)
+	}
+	if opts.Verbose {
+		opts.Logger.Println("Getting response from DM ", string(body))
+	}
+	return statusError("DataManager", response, body)
+}
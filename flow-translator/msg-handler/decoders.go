@@ -0,0 +1,149 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    decoders.go
+ * details: Registry of the message decoders, the messages are routed to
+ *          the decoder by the topic they are received on
+ *
+ */
+package msghandler
+
+import (
+	"fmt"
+
+	opts "github.com/Juniper/collector/flow-translator/options"
+)
+
+// msgDecoder maps the messages of one format into records
+type msgDecoder func([]byte) ([]Record, error)
+
+var msgDecoders = map[string]msgDecoder{
+	opts.DecoderIPFIX:    decodeIPFIX,
+	opts.DecoderSFlow:    decodeSFlow,
+	opts.DecoderNetflow9: decodeNetflow,
+	opts.DecoderNetflow5: decodeNetflow,
+}
+
+// flowRecords returns the flow records among the records
+func flowRecords(records []Record) []FlowRecord {
+	var res []FlowRecord
+	for _, rec := range records {
+		if f, ok := rec.(*FlowRecord); ok {
+			res = append(res, *f)
+		}
+	}
+	return res
+}
+
+// recordStages are the stateful stages the decoded records go through, every
+// message handler has its own as they all handle every message
+type recordStages struct {
+	counters  *counterTracker
+	sequences *sequenceTracker
+	// conversations is nil when the stitching is disabled
+	conversations *stitcher
+}
+
+func newRecordStages(sink string) *recordStages {
+	st := &recordStages{
+		counters:  newCounterTracker(),
+		sequences: newSequenceTracker(sink, opts.ExporterStatsInterval),
+	}
+	if opts.StitchWindow > 0 {
+		st.conversations = newStitcher(opts.StitchWindow)
+	}
+	return st
+}
+
+// decode decodes the message into records once for all the message
+// handlers, with the decoder of its topic. The exporter clocks and options
+// are updated and the records are enriched along the decoding, the records
+// are shared by the message handlers which must not modify them
+func (msg *Message) decode() ([]Record, error) {
+	msg.decodeOnce.Do(func() {
+		msg.records, msg.decodeErr = decodeRecords(msg)
+	})
+	return msg.records, msg.decodeErr
+}
+
+func decodeRecords(msg *Message) ([]Record, error) {
+	dec, err := decoderByTopic(msg.Topic)
+	if err != nil {
+		return nil, err
+	}
+	records, err := dec(msg.Value)
+	if err != nil {
+		return nil, err
+	}
+	for _, rec := range records {
+		switch r := rec.(type) {
+		case *CounterRecord:
+			annotateCounterInterface(r)
+		case *FlowRecord:
+			geoLocate(r)
+			routeLocate(r)
+			annotateInterfaces(r)
+		}
+	}
+	return records, nil
+}
+
+// recordsByTopic returns the records of a message for a message handler.
+// The deltas of the counters records are computed, the sequence numbers are
+// accounted for and the flows are stitched into conversations by the stages
+// of the message handler, if any
+func recordsByTopic(msg *Message, stages *recordStages) ([]Record, error) {
+	decoded, err := msg.decode()
+	if err != nil {
+		return nil, err
+	}
+	records := make([]Record, 0, len(decoded))
+	var extra []Record
+	for _, rec := range decoded {
+		switch r := rec.(type) {
+		case *sequenceSample:
+			// the sequence numbers are not stored
+			if stages == nil {
+				continue
+			}
+			if stats := stages.sequences.update(r); stats != nil {
+				extra = append(extra, stats)
+			}
+			continue
+		case *CounterRecord:
+			if stages != nil {
+				// the deltas are as per the messages of the message handler
+				counters := *r
+				stages.counters.update(&counters)
+				rec = &counters
+			}
+		case *FlowRecord:
+			if stages == nil || stages.conversations == nil {
+				break
+			}
+			if conv := stages.conversations.add(r); conv != nil {
+				extra = append(extra, conv)
+			}
+		}
+		records = append(records, rec)
+	}
+	return append(records, extra...), nil
+}
+
+// decoderByTopic returns the decoder as configured for the topic, the vFlow
+// topics are decoded by their default decoder if not configured otherwise
+func decoderByTopic(topic string) (msgDecoder, error) {
+	name, ok := opts.KafkaTopics[topic]
+	if !ok {
+		name, ok = opts.DefaultTopicDecoders[topic]
+	}
+	if !ok {
+		return nil, fmt.Errorf("Not supported Topic: %s", topic)
+	}
+	dec, ok := msgDecoders[name]
+	if !ok {
+		return nil, fmt.Errorf("Not supported decoder %s for Topic: %s",
+			name, topic)
+	}
+	return dec, nil
+}
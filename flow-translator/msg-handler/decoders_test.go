@@ -0,0 +1,133 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    decoders_test.go
+ * details: Deals with the Unit Test cases for the topic to decoder routing
+ *
+ */
+package msghandler
+
+import (
+	"encoding/json"
+	"testing"
+
+	opts "github.com/Juniper/collector/flow-translator/options"
+)
+
+func TestSerializeDataByTopic(t *testing.T) {
+	opts.KafkaTopics = map[string]string{
+		"site1.ipfix": opts.DecoderIPFIX,
+		"site1.sflow": opts.DecoderSFlow,
+	}
+	defer func() { opts.KafkaTopics = map[string]string{} }()
+
+	tests := []struct {
+		name      string
+		msg       *Message
+		tableName string
+		wantErr   bool
+	}{
+		{
+			name:      "Configured ipfix topic",
+			msg:       &Message{Topic: "site1.ipfix", Value: MockData[StrTestValidIPFIXMessage]},
+			tableName: opts.IPFIXCollection,
+		},
+		{
+			name:      "Configured sflow topic",
+			msg:       &Message{Topic: "site1.sflow", Value: MockData[StrTestValidSFlowMessage]},
+			tableName: opts.SFLOWCollection,
+		},
+		{
+			name:      "Default netflow9 topic",
+			msg:       &Message{Topic: opts.KafkaTopicVFlowNetflow9, Value: MockData[StrTestValidNetflow9Message]},
+			tableName: opts.NetflowCollection,
+		},
+		{
+			name:    "Unknown topic",
+			msg:     &Message{Topic: "site1.unknown", Value: MockData[StrTestValidIPFIXMessage]},
+			wantErr: true,
+		},
+	}
+	qm := new(QueryAPI)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := qm.serializeDataByTopic(tt.msg)
+			if (err != nil) != tt.wantErr {
+				VerifyError(tt.name, t, tt.wantErr, err)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(got) == 0 || got[0].TableName != tt.tableName {
+				VerifyError(tt.name, t, tt.tableName, got)
+			}
+		})
+	}
+}
+
+func TestDMSerializeRoomKey(t *testing.T) {
+	dm := new(DataManager)
+	msg := &Message{Topic: opts.KafkaTopicVFlowSFlow, Value: MockData[StrTestValidSFlowMessage]}
+	got, err := dm.serializeDataByTopic(msg)
+	if err != nil || len(got) != 1 {
+		t.Fatalf("serializeDataByTopic failed: %v %v", got, err)
+	}
+	b, err := json.Marshal(got[0])
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	var res struct {
+		CollectionName string `json:"collection_name"`
+		Data           struct {
+			RoomKey  string                 `json:"roomKey"`
+			Exporter string                 `json:"Exporter"`
+			Packet   map[string]interface{} `json:"Packet"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(b, &res); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	VerifyError("collection", t, opts.SFLOWCollection, res.CollectionName)
+	VerifyError("roomKey", t, "10.84.30.141", res.Data.RoomKey)
+	VerifyError("Exporter", t, "10.84.30.141", res.Data.Exporter)
+	if res.Data.Packet == nil {
+		t.Errorf("expected the vFlow Packet field in %s", b)
+	}
+}
+
+func TestDecodeOnce(t *testing.T) {
+	decodes := 0
+	msgDecoders["test"] = func(b []byte) ([]Record, error) {
+		decodes++
+		return decodeIPFIX(b)
+	}
+	opts.KafkaTopics = map[string]string{"site1.test": "test"}
+	defer func() {
+		delete(msgDecoders, "test")
+		opts.KafkaTopics = map[string]string{}
+	}()
+
+	// every message handler gets the records decoded once
+	msg := &Message{Topic: "site1.test", Value: MockData[StrTestValidIPFIXMessage]}
+	first, err := recordsByTopic(msg, newRecordStages(opts.StrQueryAPI))
+	if err != nil || len(first) == 0 {
+		t.Fatalf("recordsByTopic failed: %v %v", first, err)
+	}
+	second, err := recordsByTopic(msg, newRecordStages(opts.StrKafka))
+	if err != nil || len(second) != len(first) {
+		t.Fatalf("recordsByTopic failed: %v %v", second, err)
+	}
+	VerifyError("decodes", t, 1, decodes)
+	VerifyError("shared record", t, first[0], second[0])
+
+	// the counters records are copied for their deltas
+	msg = &Message{Topic: opts.KafkaTopicVFlowSFlow,
+		Value: MockData[StrTestSFlowCountersMessage]}
+	first, _ = recordsByTopic(msg, newRecordStages(opts.StrQueryAPI))
+	second, _ = recordsByTopic(msg, newRecordStages(opts.StrKafka))
+	if len(first) == 0 || len(second) == 0 || first[0] == second[0] {
+		t.Errorf("expected a counters record per message handler, got %v %v",
+			first, second)
+	}
+}
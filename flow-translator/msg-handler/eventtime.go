@@ -0,0 +1,93 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    eventtime.go
+ * details: Selects the time of the flow records among the flow, export and
+ *          receive times, and detects the exporters with a skewed clock
+ *
+ */
+package msghandler
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Juniper/collector/flow-translator/metrics"
+	opts "github.com/Juniper/collector/flow-translator/options"
+)
+
+var (
+	clockSkew = metrics.NewGauge("flow_translator_exporter_clock_skew_seconds",
+		"Receive time minus export time of the last message of the exporter",
+		"exporter")
+	clockSkewed = metrics.NewGauge("flow_translator_exporter_clock_skewed",
+		"Whether the clock skew of the exporter is beyond clock-skew-threshold",
+		"exporter")
+)
+
+// setEventTime sets the Timestamp of a flow record from the first of the
+// time sources of its decoder the record has, or from its receive time
+func (rec *FlowRecord) setEventTime(decoder string) {
+	if rec.Start > 0 && rec.End >= rec.Start {
+		rec.Duration = rec.End - rec.Start
+	}
+	for _, source := range opts.EventTimeSources[decoder] {
+		var t int64
+		switch source {
+		case opts.TimeSourceStart:
+			t = rec.Start
+		case opts.TimeSourceEnd:
+			t = rec.End
+		case opts.TimeSourceExport:
+			t = rec.ExportTime
+		case opts.TimeSourceReceive:
+			t = rec.ReceiveTime
+		}
+		if t > 0 {
+			rec.Timestamp = t
+			rec.TimeSource = source
+			return
+		}
+	}
+	rec.Timestamp = rec.ReceiveTime
+	rec.TimeSource = opts.TimeSourceReceive
+}
+
+// clockTracker keeps whether the clock of every exporter is skewed, it is
+// shared by the message handlers
+type clockTracker struct {
+	mu     sync.Mutex
+	skewed map[string]bool
+}
+
+var exporterClocks = &clockTracker{skewed: map[string]bool{}}
+
+// check returns whether the clock of an exporter is skewed beyond the
+// threshold, as per the export time of a message and the time it was
+// received at (in milliseconds). The exporters getting skewed or back in
+// time are logged
+func (ct *clockTracker) check(exporter string, exportTime int64,
+	receiveTime int64) bool {
+	if exportTime <= 0 || opts.ClockSkewThreshold <= 0 {
+		return false
+	}
+	skew := receiveTime - exportTime
+	skewed := abs(skew) > int64(opts.ClockSkewThreshold/time.Millisecond)
+	clockSkew.Set(float64(skew)/1000, exporter)
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	if ct.skewed[exporter] == skewed {
+		return skewed
+	}
+	ct.skewed[exporter] = skewed
+	if skewed {
+		clockSkewed.Set(1, exporter)
+		opts.Logger.Printf("Exporter %s clock skewed by %v", exporter,
+			time.Duration(skew)*time.Millisecond)
+	} else {
+		clockSkewed.Set(0, exporter)
+		opts.Logger.Printf("Exporter %s clock back within %v", exporter,
+			opts.ClockSkewThreshold)
+	}
+	return skewed
+}
@@ -0,0 +1,79 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    eventtime_test.go
+ * details: Deals with the Unit Test cases for the event time selection and
+ *          the clock skew detection
+ *
+ */
+package msghandler
+
+import (
+	"testing"
+	"time"
+
+	opts "github.com/Juniper/collector/flow-translator/options"
+)
+
+func TestSetEventTime(t *testing.T) {
+	defer func(sources map[string][]string) {
+		opts.EventTimeSources = sources
+	}(opts.EventTimeSources)
+	opts.EventTimeSources = map[string][]string{
+		opts.DecoderIPFIX: {opts.TimeSourceEnd, opts.TimeSourceExport},
+		opts.DecoderSFlow: {opts.TimeSourceStart},
+	}
+	tests := []struct {
+		name      string
+		decoder   string
+		start     int64
+		end       int64
+		export    int64
+		timestamp int64
+		source    string
+		duration  int64
+	}{
+		{"flow end", opts.DecoderIPFIX, 1000, 3000, 5000, 3000, opts.TimeSourceEnd, 2000},
+		{"export fallback", opts.DecoderIPFIX, 0, 0, 5000, 5000, opts.TimeSourceExport, 0},
+		{"receive fallback", opts.DecoderIPFIX, 0, 0, 0, 9000, opts.TimeSourceReceive, 0},
+		{"start", opts.DecoderSFlow, 1000, 0, 0, 1000, opts.TimeSourceStart, 0},
+		{"not configured", opts.DecoderNetflow9, 1000, 3000, 5000, 9000, opts.TimeSourceReceive, 2000},
+	}
+	for _, tt := range tests {
+		rec := FlowRecord{Start: tt.start, End: tt.end, ExportTime: tt.export,
+			ReceiveTime: 9000}
+		rec.setEventTime(tt.decoder)
+		VerifyError(tt.name, t, tt.timestamp, rec.Timestamp)
+		VerifyError(tt.name, t, tt.source, rec.TimeSource)
+		VerifyError(tt.name, t, tt.duration, rec.Duration)
+	}
+}
+
+func TestClockTracker(t *testing.T) {
+	defer func(threshold time.Duration) {
+		opts.ClockSkewThreshold = threshold
+	}(opts.ClockSkewThreshold)
+	opts.ClockSkewThreshold = time.Minute
+	ct := &clockTracker{skewed: map[string]bool{}}
+	exporter := "10.84.30.170"
+	tests := []struct {
+		name    string
+		export  int64
+		receive int64
+		skewed  bool
+	}{
+		{"in time", 1522040162000, 1522040162500, false},
+		{"no export time", 0, 1522040162500, false},
+		{"ahead", 1522040262000, 1522040162500, true},
+		{"behind", 1522040062000, 1522040162500, true},
+		{"back in time", 1522040162000, 1522040163000, false},
+	}
+	for _, tt := range tests {
+		VerifyError(tt.name, t, tt.skewed, ct.check(exporter, tt.export, tt.receive))
+	}
+	VerifyError("skew", t, 1.0, clockSkew.Value(exporter))
+	VerifyError("skewed", t, 0.0, clockSkewed.Value(exporter))
+
+	opts.ClockSkewThreshold = 0
+	VerifyError("disabled", t, false, ct.check(exporter, 1522040262000, 1522040162500))
+}
@@ -0,0 +1,85 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    geoip.go
+ * details: Enriches the flow records with the country, city and autonomous
+ *          system of their source and destination addresses
+ *
+ */
+package msghandler
+
+import (
+	"net"
+
+	"github.com/Juniper/collector/flow-translator/geoip"
+	"github.com/Juniper/collector/flow-translator/metrics"
+	opts "github.com/Juniper/collector/flow-translator/options"
+)
+
+var geoLookupErrors = metrics.NewCounter(
+	"flow_translator_geoip_lookup_errors_total",
+	"GeoIP lookups failed, per database", "database")
+
+// geoLocator is a GeoIP database
+type geoLocator interface {
+	Path() string
+	Lookup(ip net.IP, loc *geoip.Location) (bool, error)
+}
+
+// geoDatabases are the configured GeoIP databases, looked up in order
+var geoDatabases []geoLocator
+
+// openGeoIPDatabases opens the configured GeoIP databases and watches
+// their files for changes
+func openGeoIPDatabases() error {
+	geoDatabases = nil
+	for _, path := range []string{opts.GeoIPCityDatabase, opts.GeoIPASNDatabase} {
+		if path == "" {
+			continue
+		}
+		db, err := geoip.Open(path)
+		if err != nil {
+			return err
+		}
+		opts.Logger.Printf("GeoIP database %s loaded (%s)", path,
+			db.Metadata().DatabaseType)
+		if opts.GeoIPReloadInterval > 0 {
+			db.Watch(opts.GeoIPReloadInterval)
+		}
+		geoDatabases = append(geoDatabases, db)
+	}
+	return nil
+}
+
+// geoLocate sets the locations of the source and destination addresses of
+// a flow record
+func geoLocate(rec *FlowRecord) {
+	if len(geoDatabases) == 0 {
+		return
+	}
+	src := geoLocation(rec.SrcAddr)
+	rec.SrcCountry, rec.SrcCity = src.Country, src.City
+	rec.SrcASN, rec.SrcASOrg = src.ASN, src.ASOrg
+	dst := geoLocation(rec.DstAddr)
+	rec.DstCountry, rec.DstCity = dst.Country, dst.City
+	rec.DstASN, rec.DstASOrg = dst.ASN, dst.ASOrg
+}
+
+// geoLocation returns the location of an address from all the databases
+func geoLocation(addr string) geoip.Location {
+	var loc geoip.Location
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return loc
+	}
+	for _, db := range geoDatabases {
+		if _, err := db.Lookup(ip, &loc); err != nil {
+			geoLookupErrors.Inc(db.Path())
+			if opts.Verbose {
+				opts.Logger.Printf("GeoIP lookup of %s in %s error: %v", addr,
+					db.Path(), err)
+			}
+		}
+	}
+	return loc
+}
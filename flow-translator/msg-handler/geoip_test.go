@@ -0,0 +1,82 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    geoip_test.go
+ * details: Deals with the Unit Test cases for the GeoIP enrichment
+ *
+ */
+package msghandler
+
+import (
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/Juniper/collector/flow-translator/geoip"
+	opts "github.com/Juniper/collector/flow-translator/options"
+)
+
+// testGeoDatabase locates the addresses of its map, and fails on the others
+type testGeoDatabase map[string]geoip.Location
+
+func (db testGeoDatabase) Path() string {
+	return "test.mmdb"
+}
+
+func (db testGeoDatabase) Lookup(ip net.IP, loc *geoip.Location) (bool, error) {
+	found, ok := db[ip.String()]
+	if !ok {
+		return false, fmt.Errorf("Address %v not found", ip)
+	}
+	if loc.Country == "" {
+		loc.Country, loc.City = found.Country, found.City
+	}
+	if loc.ASN == 0 {
+		loc.ASN, loc.ASOrg = found.ASN, found.ASOrg
+	}
+	return true, nil
+}
+
+func TestGeoLocate(t *testing.T) {
+	defer func() { geoDatabases = nil }()
+	geoDatabases = []geoLocator{
+		testGeoDatabase{
+			"10.84.30.201":  {Country: "US", City: "Sunnyvale"},
+			"172.29.111.95": {Country: "NL", City: "Amsterdam"},
+		},
+		testGeoDatabase{
+			"10.84.30.201":  {ASN: 64496, ASOrg: "Example"},
+			"172.29.111.95": {ASN: 64497},
+		},
+	}
+	records, err := recordsByTopic(&Message{Topic: opts.KafkaTopicVFlowSFlow,
+		Value: MockData[StrTestValidSFlowMessage]}, nil)
+	if err != nil || len(records) == 0 {
+		t.Fatalf("recordsByTopic failed: %v %v", records, err)
+	}
+	rec, ok := records[0].(*FlowRecord)
+	if !ok {
+		t.Fatalf("Expected a flow record, got %+v", records[0])
+	}
+	VerifyError("SrcCountry", t, "US", rec.SrcCountry)
+	VerifyError("SrcCity", t, "Sunnyvale", rec.SrcCity)
+	VerifyError("SrcASN", t, uint32(64496), rec.SrcASN)
+	VerifyError("SrcASOrg", t, "Example", rec.SrcASOrg)
+	VerifyError("DstCountry", t, "NL", rec.DstCountry)
+	VerifyError("DstCity", t, "Amsterdam", rec.DstCity)
+	VerifyError("DstASN", t, uint32(64497), rec.DstASN)
+	VerifyError("DstASOrg", t, "", rec.DstASOrg)
+
+	// the lookup errors are counted, the other databases still looked up
+	rec = &FlowRecord{SrcAddr: "10.84.30.201", DstAddr: "192.0.2.1"}
+	before := geoLookupErrors.Value("test.mmdb")
+	geoLocate(rec)
+	VerifyError("SrcASN", t, uint32(64496), rec.SrcASN)
+	VerifyError("DstCountry", t, "", rec.DstCountry)
+	VerifyError("lookup errors", t, before+2, geoLookupErrors.Value("test.mmdb"))
+
+	// no lookups without address
+	rec = &FlowRecord{}
+	geoLocate(rec)
+	VerifyError("no address", t, before+2, geoLookupErrors.Value("test.mmdb"))
+}
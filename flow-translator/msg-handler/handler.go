@@ -12,20 +12,22 @@ import (
 	"sync"
 )
 
+// Handler runs a message handler, with one worker per channel
 type Handler struct {
-	MH     MsgHandler
-	MHChan chan []byte
+	MH      MsgHandler
+	MHChans []chan *Message
 }
 
 type MsgHandler interface {
 	setup() error
-	handleMessages(chan []byte)
+	handleMessages(chan *Message)
 }
 
 func NewMsgHandler(handlerName string) *Handler {
 	var msgHandlerRegistered = map[string]MsgHandler{
 		opts.StrDataManager: new(DataManager),
 		opts.StrQueryAPI:    new(QueryAPI),
+		opts.StrKafka:       new(KafkaSink),
 	}
 	return &Handler{
 		MH: msgHandlerRegistered[handlerName],
@@ -41,11 +43,13 @@ func (h Handler) Run() error {
 	if err != nil {
 		return err
 	}
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		h.MH.handleMessages(h.MHChan)
-	}()
+	for _, mhChan := range h.MHChans {
+		wg.Add(1)
+		go func(mhChan chan *Message) {
+			defer wg.Done()
+			h.MH.handleMessages(mhChan)
+		}(mhChan)
+	}
 
 	wg.Wait()
 
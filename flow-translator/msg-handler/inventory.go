@@ -0,0 +1,70 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    inventory.go
+ * details: Annotates the flow and counter records with the details of their
+ *          interfaces as per the interface inventory
+ *
+ */
+package msghandler
+
+import (
+	"github.com/Juniper/collector/flow-translator/inventory"
+	opts "github.com/Juniper/collector/flow-translator/options"
+)
+
+// interfaceInventory is the configured interface inventory, nil if none
+var interfaceInventory *inventory.Database
+
+// openInterfaceInventory loads the configured interface inventory and
+// watches its files for changes
+func openInterfaceInventory() error {
+	interfaceInventory = nil
+	if len(opts.InventoryFiles) == 0 {
+		return nil
+	}
+	db, err := inventory.Open(opts.InventoryFiles)
+	if err != nil {
+		return err
+	}
+	opts.Logger.Printf("Interface inventory loaded, %d interfaces", db.Len())
+	if opts.InventoryReloadInterval > 0 {
+		db.Watch(opts.InventoryReloadInterval)
+	}
+	interfaceInventory = db
+	return nil
+}
+
+// annotateInterfaces sets the details of the input and output interfaces
+// of a flow record, the names from the options records of the exporter are
+// kept
+func annotateInterfaces(rec *FlowRecord) {
+	if interfaceInventory == nil {
+		return
+	}
+	if i, ok := interfaceInventory.Lookup(rec.Exporter, rec.InIf); ok {
+		if rec.InIfName == "" {
+			rec.InIfName = i.Name
+		}
+		rec.InIfDescription, rec.InIfSpeed, rec.InIfRole = i.Description,
+			i.Speed, i.Role
+	}
+	if i, ok := interfaceInventory.Lookup(rec.Exporter, rec.OutIf); ok {
+		if rec.OutIfName == "" {
+			rec.OutIfName = i.Name
+		}
+		rec.OutIfDescription, rec.OutIfSpeed, rec.OutIfRole = i.Description,
+			i.Speed, i.Role
+	}
+}
+
+// annotateCounterInterface sets the details of the interface of a counter
+// record
+func annotateCounterInterface(rec *CounterRecord) {
+	if interfaceInventory == nil {
+		return
+	}
+	if i, ok := interfaceInventory.Lookup(rec.Exporter, rec.IfIndex); ok {
+		rec.IfName, rec.IfDescription, rec.IfRole = i.Name, i.Description, i.Role
+	}
+}
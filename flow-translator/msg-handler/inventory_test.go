@@ -0,0 +1,77 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    inventory_test.go
+ * details: Deals with the Unit Test cases for the interface inventory
+ *          annotations
+ *
+ */
+package msghandler
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	opts "github.com/Juniper/collector/flow-translator/options"
+)
+
+const testInventory = `
+10.84.30.149:
+  556: {name: ge-0/0/1, description: uplink, speed: 10000000000, role: uplink}
+  573: {name: ge-0/0/2, role: access}
+10.84.30.141:
+  505: {name: xe-0/0/5, description: to core, speed: 10000000000, role: core}
+`
+
+func TestInterfaceInventory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "inventory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "inventory.yaml")
+	ioutil.WriteFile(path, []byte(testInventory), 0644)
+	defer func(files []string) {
+		opts.InventoryFiles = files
+		interfaceInventory = nil
+	}(opts.InventoryFiles)
+	opts.InventoryFiles = []string{path}
+	if err := openInterfaceInventory(); err != nil {
+		t.Fatalf("openInterfaceInventory failed: %v", err)
+	}
+
+	records, err := recordsByTopic(&Message{Topic: opts.KafkaTopicVFlowIPFIX,
+		Value: MockData[StrTestValidIPFIXMessage]}, nil)
+	if err != nil || len(records) == 0 {
+		t.Fatalf("recordsByTopic failed: %v %v", records, err)
+	}
+	rec := records[0].(*FlowRecord)
+	VerifyError("InIfName", t, "ge-0/0/1", rec.InIfName)
+	VerifyError("InIfDescription", t, "uplink", rec.InIfDescription)
+	VerifyError("InIfSpeed", t, uint64(10000000000), rec.InIfSpeed)
+	VerifyError("InIfRole", t, "uplink", rec.InIfRole)
+	VerifyError("OutIfName", t, "ge-0/0/2", rec.OutIfName)
+	VerifyError("OutIfDescription", t, "", rec.OutIfDescription)
+	VerifyError("OutIfRole", t, "access", rec.OutIfRole)
+
+	// the names of the options records are kept
+	rec = &FlowRecord{Exporter: "10.84.30.149", InIf: 556, InIfName: "ge-0/0/1.0"}
+	annotateInterfaces(rec)
+	VerifyError("options InIfName", t, "ge-0/0/1.0", rec.InIfName)
+	VerifyError("options InIfRole", t, "uplink", rec.InIfRole)
+
+	records, err = recordsByTopic(&Message{Topic: opts.KafkaTopicVFlowSFlow,
+		Value: MockData[StrTestSFlowCountersMessage]}, nil)
+	if err != nil || len(records) == 0 {
+		t.Fatalf("recordsByTopic of counters failed: %v %v", records, err)
+	}
+	counters, ok := records[0].(*CounterRecord)
+	if !ok {
+		t.Fatalf("Expected a counter record, got %+v", records[0])
+	}
+	VerifyError("IfName", t, "xe-0/0/5", counters.IfName)
+	VerifyError("IfDescription", t, "to core", counters.IfDescription)
+	VerifyError("IfRole", t, "core", counters.IfRole)
+}
@@ -0,0 +1,165 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    ipfix.go
+ * details: Maps the IPFIX messages into flow records
+ *
+ */
+package msghandler
+
+import (
+	"math"
+
+	"github.com/Juniper/collector/flow-translator/ipfix"
+	"github.com/Juniper/collector/flow-translator/metrics"
+	opts "github.com/Juniper/collector/flow-translator/options"
+)
+
+var invalidFields = metrics.NewCounter("flow_translator_invalid_fields_total",
+	"DataSet fields dropped as their value does not fit the element data type",
+	"field")
+
+// IPFIXMessage represents IPFIX message
+type IPFIXMessage struct {
+	AgentID         string                   `json:"AgentID"`
+	Header          map[string]interface{}   `json:"Header"`
+	DataSets        []map[string]interface{} `json:"DataSets"`
+	OptionsDataSets []map[string]interface{} `json:"OptionsDataSets"`
+	Timestamp       interface{}              `json:"Timestamp"`
+	SkippedSets     int                      `json:"SkippedSets"`
+}
+
+// DecodeIPFIXRecords returns a flow record per DataSet of the IPFIX message
+func DecodeIPFIXRecords(b []byte) ([]FlowRecord, error) {
+	records, err := decodeIPFIX(b)
+	if err != nil {
+		return nil, err
+	}
+	return flowRecords(records), nil
+}
+
+// decodeIPFIX returns the flow records of the IPFIX message along with its
+// sequence number, which counts the data records of the domain
+func decodeIPFIX(b []byte) ([]Record, error) {
+	var msg IPFIXMessage
+	if err := decodeMessage(b, &msg); err != nil {
+		opts.Logger.Println("IPFIX message decode error:", err)
+		return nil, err
+	}
+	timeStamp, err := timestampMillis(msg.Timestamp, "ipfix")
+	if err != nil {
+		return nil, err
+	}
+	var exportTime int64
+	if secs, ok := fieldInt(msg.Header["ExportTime"]); ok {
+		exportTime = secs * 1000
+	}
+	skewed := exporterClocks.check(msg.AgentID, exportTime, timeStamp)
+	exporterOptions.update(msg.AgentID, msg.OptionsDataSets, timeStamp)
+	res := make([]Record, 0, len(msg.DataSets)+1)
+	for _, dataSet := range msg.DataSets {
+		rec := dataSetRecord(normalizeDataSet(dataSet))
+		rec.Collection = opts.IPFIXCollection
+		rec.Exporter = msg.AgentID
+		rec.ExportTime = exportTime
+		rec.ReceiveTime = timeStamp
+		rec.ClockSkewed = skewed
+		rec.setEventTime(opts.DecoderIPFIX)
+		rec.AgentID = msg.AgentID
+		rec.Header = msg.Header
+		exporterOptions.annotate(&rec)
+		res = append(res, &rec)
+	}
+	if seq, ok := fieldUint(msg.Header["SequenceNo"]); ok {
+		domainID, _ := fieldUint(msg.Header["DomainID"])
+		res = append(res, &sequenceSample{
+			exporter:    msg.AgentID,
+			domain:      uint32(domainID),
+			decoder:     opts.DecoderIPFIX,
+			sequenceNo:  uint32(seq),
+			units:       uint32(len(msg.DataSets) + len(msg.OptionsDataSets)),
+			partial:     msg.SkippedSets > 0,
+			receiveTime: timeStamp,
+		})
+	}
+	return res, nil
+}
+
+// dataSetRecord maps the information elements of an IPFIX or NetFlow DataSet
+// into a flow record, the NetFlow records use the IPFIX element names
+func dataSetRecord(ds map[string]interface{}) FlowRecord {
+	rec := FlowRecord{
+		SrcAddr: firstString(ds, "sourceIPv4Address", "sourceIPv6Address"),
+		DstAddr: firstString(ds, "destinationIPv4Address",
+			"destinationIPv6Address"),
+		SrcPort:  uint16(firstUint(ds, "sourceTransportPort")),
+		DstPort:  uint16(firstUint(ds, "destinationTransportPort")),
+		Protocol: uint8(firstUint(ds, "protocolIdentifier")),
+		Bytes:    firstUint(ds, "octetDeltaCount", "octetTotalCount"),
+		Packets:  firstUint(ds, "packetDeltaCount", "packetTotalCount"),
+		InIf:     uint32(firstUint(ds, "ingressInterface")),
+		OutIf:    uint32(firstUint(ds, "egressInterface")),
+		Vlan:     uint16(firstUint(ds, "vlanId", "dot1qVlanId")),
+		TCPFlags: uint8(firstUint(ds, "tcpControlBits")),
+		InVRF:    uint32(firstUint(ds, "ingressVRFID")),
+		OutVRF:   uint32(firstUint(ds, "egressVRFID")),
+		ReverseBytes: firstUint(ds, "reverseOctetDeltaCount",
+			"reverseOctetTotalCount"),
+		ReversePackets: firstUint(ds, "reversePacketDeltaCount",
+			"reversePacketTotalCount"),
+		DataSets: ds,
+	}
+	if ms, ok := fieldInt(ds["flowStartMilliseconds"]); ok {
+		rec.Start = ms
+	} else if s, ok := fieldInt(ds["flowStartSeconds"]); ok {
+		rec.Start = s * 1000
+	}
+	if ms, ok := fieldInt(ds["flowEndMilliseconds"]); ok {
+		rec.End = ms
+	} else if s, ok := fieldInt(ds["flowEndSeconds"]); ok {
+		rec.End = s * 1000
+	}
+	rec.SamplingRate = dataSetSamplingRate(ds)
+	rec.upscale()
+	return rec
+}
+
+// dataSetSamplingRate returns the sampling rate of a DataSet, from the
+// sampling interval of the packet sampling (RFC 3954/5102), from the packet
+// interval and space of the systematic count-based sampling or from the
+// sampling probability of the random sampling (RFC 5477). It is 0 when the
+// DataSet has none of them
+func dataSetSamplingRate(ds map[string]interface{}) uint32 {
+	if rate := firstUint(ds, "samplingInterval"); rate > 0 {
+		return uint32(rate)
+	}
+	if rate := firstUint(ds, "samplerRandomInterval"); rate > 0 {
+		return uint32(rate)
+	}
+	interval := firstUint(ds, "samplingPacketInterval")
+	if interval > 0 {
+		return uint32((interval + firstUint(ds, "samplingPacketSpace")) / interval)
+	}
+	if p, ok := fieldFloat(ds["samplingProbability"]); ok && p > 0 && p <= 1 {
+		return uint32(math.Floor(1/p + 0.5))
+	}
+	return 0
+}
+
+// normalizeDataSet names, converts and validates the fields of a DataSet as
+// per the IPFIX element registry, the invalid fields are dropped
+func normalizeDataSet(ds map[string]interface{}) map[string]interface{} {
+	res := make(map[string]interface{}, len(ds))
+	for name, value := range ds {
+		field, v, err := ipfix.NormalizeField(name, value)
+		if err != nil {
+			invalidFields.Inc(field)
+			if opts.Verbose {
+				opts.Logger.Println("DataSet field dropped:", err)
+			}
+			continue
+		}
+		res[field] = v
+	}
+	return res
+}
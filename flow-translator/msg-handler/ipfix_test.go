@@ -0,0 +1,148 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    ipfix_test.go
+ * details: Deals with the Unit Test cases for the exported functions as defined in ipfix.go
+ *
+ */
+package msghandler
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	opts "github.com/Juniper/collector/flow-translator/options"
+)
+
+type ipfixArgs struct {
+	msg []byte
+}
+
+type IPFIXtestStruct struct {
+	name    string
+	args    ipfixArgs
+	want    []FlowRecord
+	wantErr bool
+}
+
+func TestDecodeIPFIXRecords(t *testing.T) {
+	tests := []IPFIXtestStruct{
+		{
+			name: StrTestValidIPFIXMessage,
+			args: ipfixArgs{
+				msg: MockData[StrTestValidIPFIXMessage],
+			},
+			wantErr: false,
+		},
+		{
+			name: StrTestInvalidIPFIXMessage,
+			args: ipfixArgs{
+				msg: MockData[StrTestInvalidIPFIXMessage],
+			},
+			wantErr: true,
+		},
+		{
+			name: StrTestInvalidTSIPFIXMessage,
+			args: ipfixArgs{
+				msg: MockData[StrTestInvalidTSIPFIXMessage],
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		testFnsMap := map[string]func(*testing.T, IPFIXtestStruct, []FlowRecord, error){
+			StrTestValidIPFIXMessage:     CheckValidIPFIXMessage,
+			StrTestInvalidIPFIXMessage:   CheckInvalidIPFIXMessage,
+			StrTestInvalidTSIPFIXMessage: CheckInvalidTSIPFIXMessage,
+		}
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeIPFIXRecords(tt.args.msg)
+			testFnsMap[tt.name](t, tt, got, err)
+		})
+	}
+}
+
+func CheckValidIPFIXMessage(t *testing.T, tt IPFIXtestStruct, result []FlowRecord, err error) {
+	if (err != nil) != tt.wantErr {
+		VerifyError(tt.name, t, nil, err.Error())
+		return
+	}
+	if len(result) != 1 {
+		VerifyError(tt.name, t, 1, len(result))
+		return
+	}
+	rec := result[0]
+	expected := FlowRecord{Collection: opts.IPFIXCollection,
+		Exporter: "10.84.30.149", SrcAddr: "10.84.29.30",
+		DstAddr: "10.84.30.218", SrcPort: 55246, DstPort: 8780, Protocol: 6,
+		Bytes: 52, Packets: 1, Start: 1522040157115, End: 1522040157115,
+		InIf: 556, OutIf: 573, TCPFlags: 0x11, Timestamp: 1522035620663,
+		ExportTime: 1522040162000, ReceiveTime: 1522035620663,
+		TimeSource: opts.TimeSourceReceive, ClockSkewed: true,
+		EstimatedBytes: 52, EstimatedPackets: 1, AgentID: "10.84.30.149"}
+	rec.Header, rec.DataSets = nil, nil
+	if !reflect.DeepEqual(expected, rec) {
+		t.Errorf("%s failed, expected '%+v', got '%+v'", tt.name, expected, rec)
+	}
+}
+
+func CheckInvalidIPFIXMessage(t *testing.T, tt IPFIXtestStruct, result []FlowRecord, err error) {
+	expected := "invalid character 'i' looking for beginning of value"
+	if (err == nil) != tt.wantErr {
+		VerifyError(tt.name, t, expected, err.Error())
+	}
+}
+
+func CheckInvalidTSIPFIXMessage(t *testing.T, tt IPFIXtestStruct, result []FlowRecord, err error) {
+	expected := "Invalid timeStamp in ipfix msg"
+	if (err == nil) != tt.wantErr {
+		VerifyError(tt.name, t, expected, err.Error())
+	}
+}
+
+func TestNormalizeDataSet(t *testing.T) {
+	ds := map[string]interface{}{
+		"sourceIPv4Address":      "10.84.29.30",
+		"destinationIPv4Address": "not an address",
+		"sourceMacAddress":       "0x00259094b4e6",
+		"vendorField":            "kept",
+	}
+	dropped := invalidFields.Value("destinationIPv4Address")
+	expected := map[string]interface{}{
+		"sourceIPv4Address": "10.84.29.30",
+		"sourceMacAddress":  "00:25:90:94:b4:e6",
+		"vendorField":       "kept",
+	}
+	got := normalizeDataSet(ds)
+	if !reflect.DeepEqual(expected, got) {
+		VerifyError("normalizeDataSet", t, expected, got)
+	}
+	VerifyError("dropped", t, dropped+1, invalidFields.Value("destinationIPv4Address"))
+}
+
+func TestDataSetSamplingRate(t *testing.T) {
+	tests := []struct {
+		name     string
+		ds       map[string]interface{}
+		expected uint32
+	}{
+		{"not sampled", map[string]interface{}{}, 0},
+		{"samplingInterval", map[string]interface{}{"samplingInterval": json.Number("1000")}, 1000},
+		{"samplerRandomInterval", map[string]interface{}{"samplingInterval": json.Number("0"),
+			"samplerRandomInterval": json.Number("512")}, 512},
+		{"packet interval and space", map[string]interface{}{
+			"samplingPacketInterval": json.Number("1"),
+			"samplingPacketSpace":    json.Number("99")}, 100},
+		{"probability", map[string]interface{}{"samplingProbability": json.Number("0.001")}, 1000},
+		{"invalid probability", map[string]interface{}{"samplingProbability": json.Number("2")}, 0},
+	}
+	for _, tt := range tests {
+		VerifyError(tt.name, t, tt.expected, dataSetSamplingRate(tt.ds))
+	}
+
+	rec := dataSetRecord(map[string]interface{}{"octetDeltaCount": json.Number("1500"),
+		"packetDeltaCount": json.Number("2"), "samplingInterval": json.Number("100")})
+	VerifyError("EstimatedBytes", t, uint64(150000), rec.EstimatedBytes)
+	VerifyError("EstimatedPackets", t, uint64(200), rec.EstimatedPackets)
+}
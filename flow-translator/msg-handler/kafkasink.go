@@ -0,0 +1,163 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    kafkasink.go
+ * details: Deals with the handling messages to publish the normalized
+ *          records, as sent to the Query API Server, on a Kafka topic
+ *
+ */
+package msghandler
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+
+	opts "github.com/Juniper/collector/flow-translator/options"
+)
+
+// HeaderCollection is the header holding the collection of a published record
+const HeaderCollection = "collection"
+
+// KafkaRecord is a normalized record to publish
+type KafkaRecord struct {
+	Topic   string
+	Key     []byte
+	Value   []byte
+	Headers map[string]string
+}
+
+// KafkaProducer publishes the records of the kafka message handler and
+// returns once they are all delivered, it is set by the Kafka client
+var KafkaProducer func(records []KafkaRecord) error
+
+// recordEncoders are the encodings of the published records
+var recordEncoders = map[string]func(interface{}) ([]byte, error){
+	opts.KafkaEncodingJSON: json.Marshal,
+}
+
+// recordKeys are the keys of the published records
+var recordKeys = map[string]func(Record) []byte{
+	opts.KafkaKeyNone:      func(Record) []byte { return nil },
+	opts.KafkaKeyAgentID:   agentIDKey,
+	opts.KafkaKeyFiveTuple: fiveTupleKey,
+}
+
+// KafkaSink structure
+type KafkaSink struct {
+	encode func(interface{}) ([]byte, error)
+	key    func(Record) []byte
+	stages *recordStages
+}
+
+func (ks *KafkaSink) setup() error {
+	if KafkaProducer == nil {
+		return fmt.Errorf("No Kafka producer for the %s message handler",
+			opts.StrKafka)
+	}
+	var ok bool
+	if ks.encode, ok = recordEncoders[opts.KafkaOutputEncoding]; !ok {
+		return fmt.Errorf("Invalid kafka-output-encoding %s",
+			opts.KafkaOutputEncoding)
+	}
+	if ks.key, ok = recordKeys[opts.KafkaOutputKey]; !ok {
+		return fmt.Errorf("Invalid kafka-output-key %s", opts.KafkaOutputKey)
+	}
+	ks.stages = newRecordStages(opts.StrKafka)
+	return nil
+}
+
+func (ks *KafkaSink) handleMessages(mhChan chan *Message) {
+	var (
+		msg *Message
+	)
+	for {
+		select {
+		case msg = <-mhChan:
+			if opts.Verbose {
+				opts.Logger.Println("Received Message on Kafka Handler ",
+					msg.Topic, string(msg.Value))
+			}
+			ks.pushDataToKafkaByTopic(msg)
+			msg.ack()
+		}
+	}
+}
+
+func (ks *KafkaSink) serializeDataByTopic(msg *Message) ([]KafkaRecord, error) {
+	recs, err := recordsByTopic(msg, ks.stages)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]KafkaRecord, len(recs))
+	for i, rec := range recs {
+		value, err := ks.encode(rec)
+		if err != nil {
+			return nil, err
+		}
+		records[i] = KafkaRecord{
+			Topic:   opts.KafkaOutputTopic,
+			Key:     ks.key(rec),
+			Value:   value,
+			Headers: map[string]string{HeaderCollection: rec.collection()},
+		}
+	}
+	return records, nil
+}
+
+func (ks *KafkaSink) pushDataToKafkaByTopic(msg *Message) {
+	records, err := ks.serializeDataByTopic(msg)
+	if err != nil {
+		opts.Logger.Println("Kafka -> data serialize error ", err)
+		deadLetter(msg, opts.StrKafka, StageDecode, err)
+		return
+	}
+	if opts.Verbose {
+		opts.Logger.Printf("Publishing %d records on %s", len(records),
+			opts.KafkaOutputTopic)
+	}
+	err = withRetry("Kafka", func() error {
+		return KafkaProducer(records)
+	})
+	if err != nil {
+		opts.Logger.Println("Kafka -> giving up on message ", err)
+		deadLetter(msg, opts.StrKafka, StageDelivery, err)
+	}
+}
+
+func agentIDKey(rec Record) []byte {
+	switch r := rec.(type) {
+	case *FlowRecord:
+		return []byte(r.Exporter)
+	case *CounterRecord:
+		return []byte(r.Exporter)
+	case *ConversationRecord:
+		return []byte(r.Exporter)
+	case *ExporterStatsRecord:
+		return []byte(r.Exporter)
+	}
+	return nil
+}
+
+// fiveTupleKey hashes the addresses, the ports and the protocol of the flow,
+// so that the records of a flow go to the same partition. The conversations
+// are keyed as their forward flow, the counters records by their interface
+// and the exporter stats by their domain
+func fiveTupleKey(rec Record) []byte {
+	h := fnv.New64a()
+	switch r := rec.(type) {
+	case *FlowRecord:
+		fmt.Fprintf(h, "%v|%v|%v|%v|%v", r.SrcAddr, r.DstAddr, r.Protocol,
+			r.SrcPort, r.DstPort)
+	case *ConversationRecord:
+		fmt.Fprintf(h, "%v|%v|%v|%v|%v", r.SrcAddr, r.DstAddr, r.Protocol,
+			r.SrcPort, r.DstPort)
+	case *CounterRecord:
+		fmt.Fprintf(h, "%v|%v", r.Exporter, r.IfIndex)
+	case *ExporterStatsRecord:
+		fmt.Fprintf(h, "%v|%v", r.Exporter, r.DomainID)
+	default:
+		return nil
+	}
+	return []byte(fmt.Sprintf("%016x", h.Sum64()))
+}
@@ -0,0 +1,97 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    kafkasink_test.go
+ * details: Deals with the Unit Test cases for the kafka message handler
+ *
+ */
+package msghandler
+
+import (
+	"encoding/json"
+	"testing"
+
+	opts "github.com/Juniper/collector/flow-translator/options"
+)
+
+func TestKafkaSinkSetup(t *testing.T) {
+	defer func() { KafkaProducer = nil }()
+	KafkaProducer = nil
+	if err := new(KafkaSink).setup(); err == nil {
+		t.Errorf("setup without producer expected error, got nil")
+	}
+	KafkaProducer = func([]KafkaRecord) error { return nil }
+	VerifyError("Setup", t, nil, new(KafkaSink).setup())
+}
+
+func TestKafkaSinkPush(t *testing.T) {
+	defer func(key string) {
+		opts.KafkaOutputKey = key
+		KafkaProducer = nil
+	}(opts.KafkaOutputKey)
+	var published []KafkaRecord
+	KafkaProducer = func(records []KafkaRecord) error {
+		published = append(published, records...)
+		return nil
+	}
+
+	tests := []struct {
+		name       string
+		msg        *Message
+		key        string
+		records    int
+		collection string
+	}{
+		{
+			name:       "IPFIX by AgentID",
+			msg:        &Message{Topic: opts.KafkaTopicVFlowIPFIX, Value: MockData[StrTestValidIPFIXMessage]},
+			key:        opts.KafkaKeyAgentID,
+			records:    1,
+			collection: opts.IPFIXCollection,
+		},
+		{
+			name:       "NetFlow v9 by 5-tuple",
+			msg:        &Message{Topic: opts.KafkaTopicVFlowNetflow9, Value: MockData[StrTestValidNetflow9Message]},
+			key:        opts.KafkaKeyFiveTuple,
+			records:    2,
+			collection: opts.NetflowCollection,
+		},
+		{
+			name: "Invalid message",
+			msg:  &Message{Topic: opts.KafkaTopicVFlowIPFIX, Value: MockData[StrTestInvalidIPFIXMessage]},
+			key:  opts.KafkaKeyAgentID,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			published = nil
+			opts.KafkaOutputKey = tt.key
+			ks := new(KafkaSink)
+			VerifyError(tt.name, t, nil, ks.setup())
+			ks.pushDataToKafkaByTopic(tt.msg)
+			VerifyError(tt.name, t, tt.records, len(published))
+			for _, r := range published {
+				VerifyError(tt.name, t, opts.KafkaOutputTopic, r.Topic)
+				VerifyError(tt.name, t, tt.collection, r.Headers[HeaderCollection])
+				var rec map[string]interface{}
+				if err := json.Unmarshal(r.Value, &rec); err != nil {
+					t.Errorf("%s invalid record %s", tt.name, r.Value)
+				}
+				if _, ok := rec["Timestamp"].(float64); !ok {
+					t.Errorf("%s no millisecond Timestamp in %s", tt.name, r.Value)
+				}
+			}
+			if tt.key == opts.KafkaKeyAgentID && len(published) > 0 {
+				VerifyError(tt.name, t, "10.84.30.149", string(published[0].Key))
+			}
+			if tt.key == opts.KafkaKeyFiveTuple && len(published) == 2 {
+				// the records of both directions are different flows
+				if string(published[0].Key) == string(published[1].Key) ||
+					len(published[0].Key) != 16 {
+					t.Errorf("%s unexpected keys %s %s", tt.name,
+						published[0].Key, published[1].Key)
+				}
+			}
+		})
+	}
+}
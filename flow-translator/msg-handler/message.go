@@ -0,0 +1,188 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    message.go
+ * details: Flow message as passed to the message handlers and the tracking
+ *          of its acknowledgement by all the enabled message handlers
+ *
+ */
+package msghandler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	opts "github.com/Juniper/collector/flow-translator/options"
+)
+
+// The stages at which a message handler can fail on a message
+const (
+	StageDecode   = "decode"
+	StageDelivery = "delivery"
+)
+
+// DeadLetter, if set, publishes the messages a message handler failed on
+// along with the error, the message is acknowledged once it is published
+var DeadLetter func(msg *Message, sink string, stage string, err error) error
+
+// Message is a flow message as received on Kafka or by one of the listeners
+type Message struct {
+	Topic string
+	Value []byte
+
+	// Partition and Offset locate the message on the source topic, they are
+	// -1 for the messages received by the listeners
+	Partition int32
+	Offset    int64
+
+	// Sink, if set, restricts the message to the given message handler
+	Sink string
+
+	// Key identifies the exporter, the messages of an exporter are handled
+	// in order. It is taken from the message when not set by the source
+	Key string
+
+	// OnAck, if set, is called once all the enabled message handlers have
+	// acknowledged the message, either delivered or given up on
+	OnAck func()
+
+	pending int32
+
+	// the records decoded once for all the message handlers
+	decodeOnce sync.Once
+	records    []Record
+	decodeErr  error
+}
+
+// expectAcks sets the number of acknowledgements before OnAck is called
+func (msg *Message) expectAcks(n int) {
+	atomic.StoreInt32(&msg.pending, int32(n))
+}
+
+// ack acknowledges the message for one message handler
+func (msg *Message) ack() {
+	if atomic.AddInt32(&msg.pending, -1) > 0 {
+		return
+	}
+	if msg.OnAck != nil {
+		msg.OnAck()
+	}
+}
+
+// deadLetter hands the message a message handler failed on to DeadLetter.
+// The publish is retried until it succeeds, the message is only acknowledged
+// once it is safe on the dead letter topic
+func deadLetter(msg *Message, sink string, stage string, err error) {
+	if DeadLetter == nil {
+		return
+	}
+	withRetries("Dead letter", 0, func() error {
+		return DeadLetter(msg, sink, stage, err)
+	})
+}
+
+// agentID returns the exporter of a message, the AgentID of the IPFIX and
+// NetFlow messages or the agent address of the sFlow messages. It is only
+// used to shard the messages, the fields are looked up in the JSON as is
+// rather than decoding the whole message
+func agentID(value []byte) string {
+	if id := stringField(value, "AgentID"); id != "" {
+		return id
+	}
+	return stringField(value, "IPAddress")
+}
+
+// stringField returns the value of the first string field of the name in
+// the JSON, or nothing if it is not a string
+func stringField(value []byte, name string) string {
+	i := bytes.Index(value, []byte(`"`+name+`"`))
+	if i < 0 {
+		return ""
+	}
+	b := bytes.TrimLeft(value[i+len(name)+2:], " \t\r\n")
+	if len(b) == 0 || b[0] != ':' {
+		return ""
+	}
+	b = bytes.TrimLeft(b[1:], " \t\r\n")
+	if len(b) == 0 || b[0] != '"' {
+		return ""
+	}
+	for end := 1; end < len(b); end++ {
+		switch b[end] {
+		case '\\':
+			end++
+		case '"':
+			var s string
+			if err := json.Unmarshal(b[:end+1], &s); err != nil {
+				return ""
+			}
+			return s
+		}
+	}
+	return ""
+}
+
+// permanentError is a delivery error which retrying does not fix, e.g. a
+// record rejected by the message handler
+type permanentError struct {
+	error
+}
+
+// Permanent marks the error as permanent, the push is given up on at once
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err}
+}
+
+// statusError returns the error of a response which is not 2xx. The 4xx
+// are the records rejected as invalid and are permanent, except the request
+// timeouts and the throttling
+func statusError(name string, response *http.Response, body []byte) error {
+	if response.StatusCode/100 == 2 {
+		return nil
+	}
+	err := fmt.Errorf("%s POST failed with status %s: %s", name,
+		response.Status, string(body))
+	if response.StatusCode/100 == 4 &&
+		response.StatusCode != http.StatusRequestTimeout &&
+		response.StatusCode != http.StatusTooManyRequests {
+		return Permanent(err)
+	}
+	return err
+}
+
+// withRetry calls push until it succeeds, with an exponential backoff between
+// the attempts. It gives up after opts.SinkMaxRetries retries, unless it is 0,
+// and at once on a permanent error
+func withRetry(name string, push func() error) error {
+	return withRetries(name, opts.SinkMaxRetries, push)
+}
+
+// withRetries is withRetry giving up after maxRetries retries, never when 0
+func withRetries(name string, maxRetries int, push func() error) error {
+	backoff := opts.SinkRetryBackoff
+	for retry := 0; ; retry++ {
+		err := push()
+		if err == nil {
+			return nil
+		}
+		if _, ok := err.(permanentError); ok {
+			return err
+		}
+		if maxRetries > 0 && retry >= maxRetries {
+			return err
+		}
+		opts.Logger.Printf("%s push error, retrying in %v: %v", name, backoff, err)
+		time.Sleep(backoff)
+		if backoff *= 2; backoff > opts.SinkRetryMaxBackoff {
+			backoff = opts.SinkRetryMaxBackoff
+		}
+	}
+}
@@ -0,0 +1,189 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    message_test.go
+ * details: Deals with the Unit Test cases for the message acknowledgement
+ *
+ */
+package msghandler
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	opts "github.com/Juniper/collector/flow-translator/options"
+)
+
+func TestMessageAck(t *testing.T) {
+	tests := []struct {
+		name  string
+		sinks int
+		acked int
+	}{
+		{name: "No enabled handler", sinks: 0, acked: 0},
+		{name: "One enabled handler", sinks: 1, acked: 1},
+		{name: "Two enabled handlers", sinks: 2, acked: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			acked := 0
+			msg := &Message{OnAck: func() { acked++ }}
+			msg.expectAcks(tt.sinks)
+			for i := 0; i < tt.sinks; i++ {
+				VerifyError(tt.name, t, 0, acked)
+				msg.ack()
+			}
+			VerifyError(tt.name, t, tt.acked, acked)
+		})
+	}
+}
+
+func TestSendToDisabledHandler(t *testing.T) {
+	defer func(channels []consChannel) { consChannels = channels }(consChannels)
+	enabled, disabled := true, false
+	consChannels = []consChannel{
+		newConsChannel(opts.StrQueryAPI, &enabled, 1),
+		newConsChannel(opts.StrKafka, &disabled, 1),
+	}
+
+	acked := 0
+	msg := &Message{Sink: opts.StrKafka, OnAck: func() { acked++ }}
+	if err := SendToInChannels(msg); err == nil {
+		t.Errorf("Expected an error for a disabled message handler")
+	}
+	VerifyError("Not acknowledged", t, 0, acked)
+	VerifyError("Not queued", t, 0, consChannels[0].queues[0].len())
+}
+
+func TestDeadLetterRetry(t *testing.T) {
+	defer func(retries int, backoff time.Duration) {
+		opts.SinkMaxRetries, opts.SinkRetryBackoff = retries, backoff
+		DeadLetter = nil
+	}(opts.SinkMaxRetries, opts.SinkRetryBackoff)
+	opts.SinkRetryBackoff = time.Millisecond
+	opts.SinkMaxRetries = 1
+
+	// the publish is retried beyond the retries of the message handlers
+	calls := 0
+	DeadLetter = func(msg *Message, sink string, stage string, err error) error {
+		if calls++; calls < 4 {
+			return fmt.Errorf("Unavailable")
+		}
+		return nil
+	}
+	deadLetter(&Message{}, opts.StrQueryAPI, StageDelivery, fmt.Errorf("Failed"))
+	VerifyError("Published on the last retry", t, 4, calls)
+}
+
+func TestWithRetry(t *testing.T) {
+	defer func(retries int, backoff time.Duration) {
+		opts.SinkMaxRetries, opts.SinkRetryBackoff = retries, backoff
+	}(opts.SinkMaxRetries, opts.SinkRetryBackoff)
+	opts.SinkRetryBackoff = time.Millisecond
+	opts.SinkMaxRetries = 2
+
+	calls := 0
+	err := withRetry("Test", func() error {
+		calls++
+		if calls < 3 {
+			return fmt.Errorf("Unavailable")
+		}
+		return nil
+	})
+	VerifyError("Succeeds on the last retry", t, nil, err)
+	VerifyError("Succeeds on the last retry", t, 3, calls)
+
+	calls = 0
+	err = withRetry("Test", func() error {
+		calls++
+		return fmt.Errorf("Unavailable")
+	})
+	if err == nil {
+		t.Errorf("Gives up failed, expected error, got nil")
+	}
+	VerifyError("Gives up", t, 3, calls)
+}
+
+func TestWithRetryPermanent(t *testing.T) {
+	defer func(retries int) { opts.SinkMaxRetries = retries }(opts.SinkMaxRetries)
+	opts.SinkMaxRetries = 0
+
+	// a permanent error is given up on at once, even without a retries limit
+	calls := 0
+	err := withRetry("Test", func() error {
+		calls++
+		return Permanent(fmt.Errorf("Rejected"))
+	})
+	if err == nil {
+		t.Errorf("Permanent error expected error, got nil")
+	}
+	VerifyError("Permanent error", t, 1, calls)
+}
+
+func TestStatusError(t *testing.T) {
+	tests := []struct {
+		status    int
+		err       bool
+		permanent bool
+	}{
+		{status: http.StatusOK},
+		{status: http.StatusCreated},
+		{status: http.StatusBadRequest, err: true, permanent: true},
+		{status: http.StatusRequestEntityTooLarge, err: true, permanent: true},
+		{status: http.StatusRequestTimeout, err: true},
+		{status: http.StatusTooManyRequests, err: true},
+		{status: http.StatusInternalServerError, err: true},
+		{status: http.StatusServiceUnavailable, err: true},
+	}
+	for _, tt := range tests {
+		err := statusError("Test", &http.Response{StatusCode: tt.status,
+			Status: http.StatusText(tt.status)}, nil)
+		_, permanent := err.(permanentError)
+		if (err != nil) != tt.err || permanent != tt.permanent {
+			t.Errorf("status %d expected error %v permanent %v, got %v",
+				tt.status, tt.err, tt.permanent, err)
+		}
+	}
+}
+
+func TestDeadLetterRejected(t *testing.T) {
+	defer func(ip, port string) {
+		opts.QueryApiIPAddress, opts.QueryApiPort = ip, port
+		DeadLetter, KafkaProducer = nil, nil
+	}(opts.QueryApiIPAddress, opts.QueryApiPort)
+	// the default retries, 0 retrying forever
+	VerifyError("Default retries", t, 0, opts.SinkMaxRetries)
+
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "Invalid record", http.StatusBadRequest)
+		}))
+	defer server.Close()
+	opts.QueryApiIPAddress, opts.QueryApiPort, _ = net.SplitHostPort(
+		server.Listener.Addr().String())
+
+	var stages []string
+	DeadLetter = func(msg *Message, sink string, stage string, err error) error {
+		stages = append(stages, sink+" "+stage)
+		return nil
+	}
+	qm := new(QueryAPI)
+	VerifyError("Setup", t, nil, qm.setup())
+	qm.pushDataToQueryAPIByTopic(&Message{Topic: opts.KafkaTopicVFlowIPFIX,
+		Value: MockData[StrTestValidIPFIXMessage]})
+
+	KafkaProducer = func([]KafkaRecord) error {
+		return Permanent(fmt.Errorf("Message size too large"))
+	}
+	ks := new(KafkaSink)
+	VerifyError("Setup", t, nil, ks.setup())
+	ks.pushDataToKafkaByTopic(&Message{Topic: opts.KafkaTopicVFlowIPFIX,
+		Value: MockData[StrTestValidIPFIXMessage]})
+	expected := fmt.Sprint([]string{opts.StrQueryAPI + " " + StageDelivery,
+		opts.StrKafka + " " + StageDelivery})
+	VerifyError("Dead lettered", t, expected, fmt.Sprint(stages))
+}
@@ -17,6 +17,12 @@ var (
 	StrTestValidSFlowMessage     = "Valid sflow message"
 	StrTestInvalidSFlowMessage   = "Invalid sflow message"
 	StrTestInvalidTSSFlowMessage = "Invalid timestamp in sflow message"
+	StrTestSFlowCountersMessage  = "Valid sflow counters message"
+
+	StrTestValidNetflow9Message    = "Valid netflow9 message"
+	StrTestValidNetflow5Message    = "Valid netflow5 message"
+	StrTestInvalidNetflowMessage   = "Invalid netflow message"
+	StrTestInvalidTSNetflowMessage = "Invalid timestamp in netflow message"
 )
 
 var MockData map[string][]byte
@@ -32,5 +38,11 @@ func InitMockData() {
 		StrTestValidSFlowMessage:     []byte(`{"Header":{"Version":5,"IPVersion":1,"AgentSubID":16,"SequenceNo":55739,"SysUpTime":1104544871,"SamplesNo":1,"Timestamp":1522108407531878,"IPAddress":"10.84.30.141"},"ExtSWData":{"SrcVlan":0,"SrcPriority":0,"DstVlan":0,"DstPriority":0},"Sample":{"SequenceNo":132547,"SourceID":0,"SamplingRate":2560,"SamplePool":1249241330,"Drops":0,"Input":505,"Output":0,"RecordsNo":2},"Packet":{"L2":{"SrcMAC":"00:25:90:94:b4:e6","DstMAC":"54:e0:32:88:73:81","Vlan":0,"EtherType":2048},"L3":{"Version":4,"TOS":0,"TotalLen":52,"ID":37626,"Flags":0,"FragOff":0,"TTL":64,"Protocol":6,"Checksum":25392,"Src":"10.84.30.201","Dst":"172.29.111.95"},"L4":{"SrcPort":9092,"DstPort":54510,"DataOffset":8,"Reserved":0,"Flags":16}}}`),
 		StrTestInvalidTSSFlowMessage: []byte(`{"Header":{"Version":5,"IPVersion":1,"AgentSubID":16,"SequenceNo":55739,"SysUpTime":1104544871,"SamplesNo":1,"Timestamp":"wrong","IPAddress":"10.84.30.141"},"ExtSWData":{"SrcVlan":0,"SrcPriority":0,"DstVlan":0,"DstPriority":0},"Sample":{"SequenceNo":132547,"SourceID":0,"SamplingRate":2560,"SamplePool":1249241330,"Drops":0,"Input":505,"Output":0,"RecordsNo":2},"Packet":{"L2":{"SrcMAC":"00:25:90:94:b4:e6","DstMAC":"54:e0:32:88:73:81","Vlan":0,"EtherType":2048},"L3":{"Version":4,"TOS":0,"TotalLen":52,"ID":37626,"Flags":0,"FragOff":0,"TTL":64,"Protocol":6,"Checksum":25392,"Src":"10.84.30.201","Dst":"172.29.111.95"},"L4":{"SrcPort":9092,"DstPort":54510,"DataOffset":8,"Reserved":0,"Flags":16}}}`),
 		StrTestInvalidSFlowMessage:   []byte(`invalid sflow message`),
+		StrTestSFlowCountersMessage:  []byte(`{"Header":{"Version":5,"IPVersion":1,"AgentSubID":16,"SequenceNo":55740,"SysUpTime":1104544871,"SamplesNo":1,"Timestamp":1522108407531878,"IPAddress":"10.84.30.141"},"Counters":{"SequenceNo":42,"SourceID":505,"IfCounters":{"IfIndex":505,"IfType":6,"IfSpeed":1000000000,"IfDirection":1,"IfStatus":3,"InOctets":4000000000,"InUcastPkts":100,"InMulticastPkts":2,"InBroadcastPkts":1,"InDiscards":7,"InErrors":8,"InUnknownProtos":0,"OutOctets":12345,"OutUcastPkts":200,"OutMulticastPkts":0,"OutBroadcastPkts":0,"OutDiscards":9,"OutErrors":10,"PromiscuousMode":0},"EthernetCounters":{"AlignmentErrors":1,"FCSErrors":2,"SingleCollisionFrames":0,"MultipleCollisionFrames":0,"SQETestErrors":0,"DeferredTransmissions":0,"LateCollisions":0,"ExcessiveCollisions":0,"InternalMacTransmitErrors":0,"CarrierSenseErrors":0,"FrameTooLongs":0,"InternalMacReceiveErrors":0,"SymbolErrors":13}}}`),
+		/* NetFlow v9 Data */
+		StrTestValidNetflow9Message:    []byte(`{"AgentID":"10.84.30.150","Timestamp":1522035620663678,"Header":{"Version":9,"Count":2,"SysUpTime":1104544871,"UNIXSecs":1522040162,"SeqNum":5560,"SrcID":0},"DataSets":[{"sourceIPv4Address":"10.84.29.30","destinationIPv4Address":"10.84.30.218","protocolIdentifier":6,"sourceTransportPort":55246,"destinationTransportPort":8780,"ingressInterface":556,"egressInterface":573,"octetDeltaCount":52,"packetDeltaCount":1,"flowStartSysUpTime":1104540000,"flowEndSysUpTime":1104541000},{"sourceIPv4Address":"10.84.30.218","destinationIPv4Address":"10.84.29.30","protocolIdentifier":6,"sourceTransportPort":8780,"destinationTransportPort":55246,"ingressInterface":573,"egressInterface":556,"octetDeltaCount":1500,"packetDeltaCount":3,"flowStartSysUpTime":1104540000,"flowEndSysUpTime":1104541000}]}`),
+		StrTestInvalidTSNetflowMessage: []byte(`{"AgentID":"10.84.30.150","Timestamp":"abcd","Header":{"Version":9,"Count":1,"SysUpTime":1104544871,"UNIXSecs":1522040162,"SeqNum":5560,"SrcID":0},"DataSets":[{"sourceIPv4Address":"10.84.29.30","destinationIPv4Address":"10.84.30.218","octetDeltaCount":52,"packetDeltaCount":1}]}`),
+		StrTestValidNetflow5Message:    []byte(`{"AgentID":"10.84.30.151","Timestamp":1522035620663678,"Header":{"Version":5,"Count":1,"SysUpTime":1104544871,"UNIXSecs":1522040162,"UNIXNSecs":0,"SeqNum":88120,"EngineType":0,"EngineID":0,"SamplingInterval":0},"DataSets":[{"sourceIPv4Address":"10.84.29.30","destinationIPv4Address":"10.84.30.218","ipNextHopIPv4Address":"10.84.30.165","ingressInterface":556,"egressInterface":573,"packetDeltaCount":1,"octetDeltaCount":52,"flowStartSysUpTime":1104540000,"flowEndSysUpTime":1104541000,"flowStartMilliseconds":1522040157129,"flowEndMilliseconds":1522040158129,"sourceTransportPort":55246,"destinationTransportPort":8780,"tcpControlBits":"0x11","protocolIdentifier":6,"ipClassOfService":0,"bgpSourceAsNumber":64512,"bgpDestinationAsNumber":64512,"sourceIPv4PrefixLength":0,"destinationIPv4PrefixLength":29}]}`),
+		StrTestInvalidNetflowMessage:   []byte(`invalid netflow message`),
 	}
 }
@@ -0,0 +1,105 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    netflow.go
+ * details: Maps the NetFlow v5/v9 messages into flow records
+ *
+ */
+package msghandler
+
+import (
+	opts "github.com/Juniper/collector/flow-translator/options"
+)
+
+// NetflowMessage represents NetFlow v5/v9 message
+type NetflowMessage struct {
+	AgentID         string                   `json:"AgentID"`
+	Header          map[string]interface{}   `json:"Header"`
+	DataSets        []map[string]interface{} `json:"DataSets"`
+	OptionsDataSets []map[string]interface{} `json:"OptionsDataSets"`
+	Timestamp       interface{}              `json:"Timestamp"`
+}
+
+// DecodeNetflowRecords returns a flow record per DataSet of the NetFlow
+// message
+func DecodeNetflowRecords(b []byte) ([]FlowRecord, error) {
+	records, err := decodeNetflow(b)
+	if err != nil {
+		return nil, err
+	}
+	return flowRecords(records), nil
+}
+
+// decodeNetflow returns the flow records of the NetFlow message along with
+// its sequence number, which counts the packets of the source id for v9 and
+// the flows of the engine for v5
+func decodeNetflow(b []byte) ([]Record, error) {
+	var msg NetflowMessage
+	if err := decodeMessage(b, &msg); err != nil {
+		opts.Logger.Println("NetFlow message decode error:", err)
+		return nil, err
+	}
+	timeStamp, err := timestampMillis(msg.Timestamp, "netflow")
+	if err != nil {
+		return nil, err
+	}
+	// the sysUpTime of the flows is relative to the boot of the exporter
+	sysUpTime, upOK := fieldInt(msg.Header["SysUpTime"])
+	unixSecs, secsOK := fieldInt(msg.Header["UNIXSecs"])
+	bootTime := unixSecs*1000 - sysUpTime
+
+	// the v5 export time is precise to the nanosecond
+	var exportTime int64
+	if secsOK {
+		nsecs, _ := fieldInt(msg.Header["UNIXNSecs"])
+		exportTime = unixSecs*1000 + nsecs/1e6
+	}
+	decoder := opts.DecoderNetflow9
+	if version, _ := fieldInt(msg.Header["Version"]); version == 5 {
+		decoder = opts.DecoderNetflow5
+	}
+	skewed := exporterClocks.check(msg.AgentID, exportTime, timeStamp)
+	exporterOptions.update(msg.AgentID, msg.OptionsDataSets, timeStamp)
+	res := make([]Record, 0, len(msg.DataSets)+1)
+	for _, dataSet := range msg.DataSets {
+		rec := dataSetRecord(normalizeDataSet(dataSet))
+		if upOK && secsOK {
+			if first, ok := fieldInt(dataSet["flowStartSysUpTime"]); ok && rec.Start == 0 {
+				rec.Start = bootTime + first
+			}
+			if last, ok := fieldInt(dataSet["flowEndSysUpTime"]); ok && rec.End == 0 {
+				rec.End = bootTime + last
+			}
+		}
+		rec.Collection = opts.NetflowCollection
+		rec.Exporter = msg.AgentID
+		rec.ExportTime = exportTime
+		rec.ReceiveTime = timeStamp
+		rec.ClockSkewed = skewed
+		rec.setEventTime(decoder)
+		rec.AgentID = msg.AgentID
+		rec.Header = msg.Header
+		exporterOptions.annotate(&rec)
+		res = append(res, &rec)
+	}
+	if seq, ok := fieldUint(msg.Header["SeqNum"]); ok {
+		sample := &sequenceSample{
+			exporter:    msg.AgentID,
+			decoder:     decoder,
+			sequenceNo:  uint32(seq),
+			units:       1,
+			receiveTime: timeStamp,
+		}
+		if decoder == opts.DecoderNetflow5 {
+			engineType, _ := fieldUint(msg.Header["EngineType"])
+			engineID, _ := fieldUint(msg.Header["EngineID"])
+			sample.domain = uint32(engineType<<8 | engineID)
+			sample.units = uint32(len(msg.DataSets))
+		} else {
+			srcID, _ := fieldUint(msg.Header["SrcID"])
+			sample.domain = uint32(srcID)
+		}
+		res = append(res, sample)
+	}
+	return res, nil
+}
@@ -0,0 +1,80 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    netflow_test.go
+ * details: Deals with the Unit Test cases for the exported functions as defined in netflow.go
+ *
+ */
+package msghandler
+
+import (
+	"testing"
+)
+
+type netflowArgs struct {
+	msg []byte
+}
+
+type netflowTestStruct struct {
+	name    string
+	args    netflowArgs
+	want    int
+	wantErr bool
+}
+
+func TestDecodeNetflowRecords(t *testing.T) {
+	tests := []netflowTestStruct{
+		{
+			name: StrTestValidNetflow9Message,
+			args: netflowArgs{
+				msg: MockData[StrTestValidNetflow9Message],
+			},
+			want:    2,
+			wantErr: false,
+		},
+		{
+			name: StrTestValidNetflow5Message,
+			args: netflowArgs{
+				msg: MockData[StrTestValidNetflow5Message],
+			},
+			want:    1,
+			wantErr: false,
+		},
+		{
+			name: StrTestInvalidNetflowMessage,
+			args: netflowArgs{
+				msg: MockData[StrTestInvalidNetflowMessage],
+			},
+			wantErr: true,
+		},
+		{
+			name: StrTestInvalidTSNetflowMessage,
+			args: netflowArgs{
+				msg: MockData[StrTestInvalidTSNetflowMessage],
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeNetflowRecords(tt.args.msg)
+			if (err != nil) != tt.wantErr {
+				VerifyError(tt.name, t, tt.wantErr, err)
+				return
+			}
+			if len(got) != tt.want {
+				VerifyError(tt.name, t, tt.want, len(got))
+			}
+			for _, rec := range got {
+				if rec.Collection != "netflow_collection" {
+					VerifyError(tt.name, t, "netflow_collection", rec.Collection)
+				}
+				// v9 from the sysUpTime, v5 from the decoded milliseconds
+				VerifyError(tt.name, t, int64(1522040157129), rec.Start)
+				VerifyError(tt.name, t, int64(1522040158129), rec.End)
+				VerifyError(tt.name, t, int64(1000), rec.Duration)
+				VerifyError(tt.name, t, int64(1522040162000), rec.ExportTime)
+			}
+		})
+	}
+}
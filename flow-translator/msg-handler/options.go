@@ -0,0 +1,142 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    options.go
+ * details: Per exporter cache of the IPFIX/NetFlow v9 options records, the
+ *          interface names, VRF names and sampling rates annotate the flows
+ *
+ */
+package msghandler
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/Juniper/collector/flow-translator/metrics"
+)
+
+// ExporterOptions are the options of an exporter as per its options records.
+// SamplingRate is the rate of the options records without sampler, Samplers
+// the rates by samplerId or selectorId. Updated is the time of the last
+// options record in milliseconds since the epoch
+type ExporterOptions struct {
+	Interfaces   map[uint32]InterfaceOptions `json:"Interfaces"`
+	VRFs         map[uint32]string           `json:"VRFs"`
+	SamplingRate uint32                      `json:"SamplingRate"`
+	Samplers     map[uint64]uint32           `json:"Samplers"`
+	Updated      int64                       `json:"Updated"`
+}
+
+// InterfaceOptions are the name and the description of an interface
+type InterfaceOptions struct {
+	Name        string `json:"Name"`
+	Description string `json:"Description"`
+}
+
+// optionsCache keeps the options by exporter, it is shared by the message
+// handlers as the options records are the same for all of them
+type optionsCache struct {
+	mu        sync.RWMutex
+	exporters map[string]*ExporterOptions
+}
+
+var exporterOptions = newOptionsCache()
+
+func init() {
+	metrics.HandleFunc("/debug/exporter-options", exporterOptions.serveHTTP)
+}
+
+func newOptionsCache() *optionsCache {
+	return &optionsCache{exporters: map[string]*ExporterOptions{}}
+}
+
+// update caches the options records of an exporter, the interface names
+// are scoped by ingressInterface or egressInterface (or the NetFlow v9
+// interface scope), the VRF names by ingressVRFID or egressVRFID and the
+// sampling rates by samplerId or selectorId, if any
+func (oc *optionsCache) update(exporter string, records []map[string]interface{},
+	timestamp int64) {
+	if len(records) == 0 {
+		return
+	}
+	oc.mu.Lock()
+	defer oc.mu.Unlock()
+	o, ok := oc.exporters[exporter]
+	if !ok {
+		o = &ExporterOptions{
+			Interfaces: map[uint32]InterfaceOptions{},
+			VRFs:       map[uint32]string{},
+			Samplers:   map[uint64]uint32{},
+		}
+		oc.exporters[exporter] = o
+	}
+	o.Updated = timestamp
+	for _, record := range records {
+		ds := normalizeDataSet(record)
+		name := fieldString(ds["interfaceName"])
+		description := fieldString(ds["interfaceDescription"])
+		if name != "" || description != "" {
+			if ifIndex, ok := firstUintOK(ds, "ingressInterface",
+				"egressInterface", "scopeInterface"); ok {
+				o.Interfaces[uint32(ifIndex)] = InterfaceOptions{name, description}
+			}
+		}
+		if name := fieldString(ds["VRFname"]); name != "" {
+			if id, ok := firstUintOK(ds, "ingressVRFID", "egressVRFID"); ok {
+				o.VRFs[uint32(id)] = name
+			}
+		}
+		if rate := dataSetSamplingRate(ds); rate > 0 {
+			if id, ok := firstUintOK(ds, "samplerId", "selectorId"); ok {
+				o.Samplers[id] = rate
+			} else {
+				o.SamplingRate = rate
+			}
+		}
+	}
+}
+
+// annotate sets the interface and VRF names of a flow record, and its
+// sampling rate if its DataSet has none
+func (oc *optionsCache) annotate(rec *FlowRecord) {
+	oc.mu.RLock()
+	defer oc.mu.RUnlock()
+	o, ok := oc.exporters[rec.Exporter]
+	if !ok {
+		return
+	}
+	rec.InIfName = o.Interfaces[rec.InIf].Name
+	rec.OutIfName = o.Interfaces[rec.OutIf].Name
+	rec.InVRFName = o.VRFs[rec.InVRF]
+	rec.OutVRFName = o.VRFs[rec.OutVRF]
+	if rec.SamplingRate > 0 {
+		return
+	}
+	if id, ok := firstUintOK(rec.DataSets, "samplerId", "selectorId"); ok {
+		rec.SamplingRate = o.Samplers[id]
+	} else {
+		rec.SamplingRate = o.SamplingRate
+	}
+	rec.upscale()
+}
+
+// serveHTTP writes the options of the exporters, or of the exporter given
+// by the exporter query parameter
+func (oc *optionsCache) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	oc.mu.RLock()
+	defer oc.mu.RUnlock()
+	res := oc.exporters
+	if exporter := r.URL.Query().Get("exporter"); exporter != "" {
+		o, ok := oc.exporters[exporter]
+		if !ok {
+			http.Error(w, "Unknown exporter "+exporter, http.StatusNotFound)
+			return
+		}
+		res = map[string]*ExporterOptions{exporter: o}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
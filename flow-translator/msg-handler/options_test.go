@@ -0,0 +1,96 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    options_test.go
+ * details: Deals with the Unit Test cases for the exporter options cache
+ *
+ */
+package msghandler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	opts "github.com/Juniper/collector/flow-translator/options"
+)
+
+const testOptionsMessage = `{"AgentID":"10.84.30.160","Header":{"Version":10},
+"DataSets":[],
+"OptionsDataSets":[
+{"ingressInterface":556,"interfaceName":"ge-0/0/1","interfaceDescription":"uplink"},
+{"egressInterface":573,"interfaceName":"ge-0/0/2"},
+{"ingressVRFID":7,"VRFname":"blue"},
+{"samplingInterval":100},
+{"selectorId":3,"samplingPacketInterval":1,"samplingPacketSpace":999}],
+"Timestamp":1522035620663000}`
+
+const testOptionsDataMessage = `{"AgentID":"10.84.30.160","Header":{"Version":10},
+"DataSets":[
+{"sourceIPv4Address":"10.1.1.1","destinationIPv4Address":"10.2.2.2",
+"ingressInterface":556,"egressInterface":573,"ingressVRFID":7,"egressVRFID":8,
+"octetDeltaCount":52,"packetDeltaCount":1},
+{"sourceIPv4Address":"10.1.1.1","destinationIPv4Address":"10.2.2.2",
+"selectorId":3,"octetDeltaCount":52,"packetDeltaCount":1},
+{"sourceIPv4Address":"10.1.1.1","destinationIPv4Address":"10.2.2.2",
+"samplingInterval":10,"octetDeltaCount":52,"packetDeltaCount":1}],
+"Timestamp":1522035621663000}`
+
+func TestExporterOptions(t *testing.T) {
+	recs, err := DecodeIPFIXRecords([]byte(testOptionsMessage))
+	if err != nil || len(recs) != 0 {
+		t.Fatalf("DecodeIPFIXRecords of options failed: %v %v", recs, err)
+	}
+	recs, err = DecodeIPFIXRecords([]byte(testOptionsDataMessage))
+	if err != nil || len(recs) != 3 {
+		t.Fatalf("DecodeIPFIXRecords failed: %v %v", recs, err)
+	}
+	VerifyError("InIfName", t, "ge-0/0/1", recs[0].InIfName)
+	VerifyError("OutIfName", t, "ge-0/0/2", recs[0].OutIfName)
+	VerifyError("InVRF", t, uint32(7), recs[0].InVRF)
+	VerifyError("InVRFName", t, "blue", recs[0].InVRFName)
+	VerifyError("OutVRFName", t, "", recs[0].OutVRFName)
+	VerifyError("SamplingRate", t, uint32(100), recs[0].SamplingRate)
+	VerifyError("EstimatedBytes", t, uint64(5200), recs[0].EstimatedBytes)
+	VerifyError("selector SamplingRate", t, uint32(1000), recs[1].SamplingRate)
+	VerifyError("selector EstimatedPackets", t, uint64(1000), recs[1].EstimatedPackets)
+	VerifyError("record SamplingRate", t, uint32(10), recs[2].SamplingRate)
+	VerifyError("record EstimatedBytes", t, uint64(520), recs[2].EstimatedBytes)
+
+	w := httptest.NewRecorder()
+	exporterOptions.serveHTTP(w, httptest.NewRequest("GET",
+		"/debug/exporter-options?exporter=10.84.30.160", nil))
+	VerifyError("status", t, http.StatusOK, w.Code)
+	var res map[string]ExporterOptions
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatalf("Invalid exporter options %s: %v", w.Body.String(), err)
+	}
+	o := res["10.84.30.160"]
+	VerifyError("Interfaces", t, InterfaceOptions{"ge-0/0/1", "uplink"}, o.Interfaces[556])
+	VerifyError("VRFs", t, "blue", o.VRFs[7])
+	VerifyError("Samplers", t, uint32(1000), o.Samplers[3])
+	VerifyError("Updated", t, int64(1522035620663), o.Updated)
+
+	w = httptest.NewRecorder()
+	exporterOptions.serveHTTP(w, httptest.NewRequest("GET",
+		"/debug/exporter-options?exporter=10.0.0.1", nil))
+	VerifyError("unknown exporter", t, http.StatusNotFound, w.Code)
+}
+
+func TestExporterOptionsOnce(t *testing.T) {
+	// an options record with an invalid field, from two message handlers
+	msg := &Message{Topic: opts.KafkaTopicVFlowIPFIX, Value: []byte(`{
+"AgentID":"10.84.30.161","Header":{"Version":10},"DataSets":[],
+"OptionsDataSets":[{"ingressInterface":556,"interfaceName":"ge-0/0/1",
+"samplingInterval":-1}],"Timestamp":1522035620663000}`)}
+	invalid := invalidFields.Value("samplingInterval")
+	for _, sink := range []string{opts.StrQueryAPI, opts.StrKafka} {
+		if _, err := recordsByTopic(msg, newRecordStages(sink)); err != nil {
+			t.Fatalf("recordsByTopic for %s failed: %v", sink, err)
+		}
+	}
+	VerifyError("invalid fields", t, invalid+1, invalidFields.Value("samplingInterval"))
+	VerifyError("Interfaces", t, InterfaceOptions{Name: "ge-0/0/1"},
+		exporterOptions.exporters["10.84.30.161"].Interfaces[556])
+}
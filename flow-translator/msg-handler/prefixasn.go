@@ -0,0 +1,83 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    prefixasn.go
+ * details: Enriches the flow records with the longest prefix and origin AS
+ *          of their source and destination addresses
+ *
+ */
+package msghandler
+
+import (
+	"net"
+	"time"
+
+	"github.com/Juniper/collector/flow-translator/metrics"
+	opts "github.com/Juniper/collector/flow-translator/options"
+	prefixasn "github.com/Juniper/collector/flow-translator/prefix-asn"
+)
+
+var (
+	prefixLookups = metrics.NewCounter(
+		"flow_translator_prefix_asn_lookups_total",
+		"Lookups in the prefix to AS table, per result", "result")
+	prefixLookupLatency = metrics.NewHistogram(
+		"flow_translator_prefix_asn_lookup_seconds",
+		"Latency of the lookups in the prefix to AS table",
+		[]float64{1e-7, 2.5e-7, 5e-7, 1e-6, 2.5e-6, 5e-6, 1e-5, 1e-4, 1e-3})
+)
+
+// prefixASNTable is the configured prefix to AS table, nil if none
+var prefixASNTable *prefixasn.Database
+
+// openPrefixASNTable loads the configured prefix to AS table and watches
+// its files for changes
+func openPrefixASNTable() error {
+	prefixASNTable = nil
+	if opts.PrefixASNFile == "" {
+		return nil
+	}
+	db, err := prefixasn.Open(opts.PrefixASNFile, opts.ASNamesFile)
+	if err != nil {
+		return err
+	}
+	v4, v6 := db.Len()
+	opts.Logger.Printf("Prefix to AS table %s loaded, %d IPv4 and %d IPv6 "+
+		"prefixes", opts.PrefixASNFile, v4, v6)
+	if opts.PrefixASNReloadInterval > 0 {
+		db.Watch(opts.PrefixASNReloadInterval)
+	}
+	prefixASNTable = db
+	return nil
+}
+
+// routeLocate sets the longest prefix and origin AS of the source and
+// destination addresses of a flow record
+func routeLocate(rec *FlowRecord) {
+	if prefixASNTable == nil {
+		return
+	}
+	src := lookupRoute(rec.SrcAddr)
+	rec.SrcPrefix, rec.SrcOriginASN, rec.SrcOriginASName = src.Prefix,
+		src.ASN, src.Name
+	dst := lookupRoute(rec.DstAddr)
+	rec.DstPrefix, rec.DstOriginASN, rec.DstOriginASName = dst.Prefix,
+		dst.ASN, dst.Name
+}
+
+// lookupRoute returns the route of an address, accounting for the lookup
+func lookupRoute(addr string) prefixasn.Route {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return prefixasn.Route{}
+	}
+	start := time.Now()
+	route, ok := prefixASNTable.Lookup(ip)
+	prefixLookupLatency.Observe(time.Since(start).Seconds())
+	if ok {
+		prefixLookups.Inc("found")
+	} else {
+		prefixLookups.Inc("not_found")
+	}
+	return route
+}
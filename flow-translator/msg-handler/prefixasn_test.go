@@ -0,0 +1,65 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    prefixasn_test.go
+ * details: Deals with the Unit Test cases for the prefix to AS enrichment
+ *
+ */
+package msghandler
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	opts "github.com/Juniper/collector/flow-translator/options"
+)
+
+func TestRouteLocate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "prefixasn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "prefixes.csv")
+	ioutil.WriteFile(path, []byte("10.84.0.0/16,64496,Example\n"+
+		"10.84.30.0/24,64497,Other\n"), 0644)
+	defer func(file string) {
+		opts.PrefixASNFile = file
+		prefixASNTable = nil
+	}(opts.PrefixASNFile)
+	opts.PrefixASNFile = path
+	if err := openPrefixASNTable(); err != nil {
+		t.Fatalf("openPrefixASNTable failed: %v", err)
+	}
+
+	before := prefixLookupLatency.Value()
+	// the router exported private AS 64512
+	msg := &Message{Topic: opts.KafkaTopicVFlowIPFIX,
+		Value: MockData[StrTestValidIPFIXMessage]}
+	records, err := recordsByTopic(msg, nil)
+	if err != nil || len(records) == 0 {
+		t.Fatalf("recordsByTopic failed: %v %v", records, err)
+	}
+	// the flows are looked up once whatever the number of message handlers
+	if _, err := recordsByTopic(msg, newRecordStages(opts.StrKafka)); err != nil {
+		t.Fatalf("recordsByTopic of another message handler failed: %v", err)
+	}
+	rec, ok := records[0].(*FlowRecord)
+	if !ok {
+		t.Fatalf("Expected a flow record, got %+v", records[0])
+	}
+	VerifyError("SrcPrefix", t, "10.84.0.0/16", rec.SrcPrefix)
+	VerifyError("SrcOriginASN", t, uint32(64496), rec.SrcOriginASN)
+	VerifyError("SrcOriginASName", t, "Example", rec.SrcOriginASName)
+	VerifyError("DstPrefix", t, "10.84.30.0/24", rec.DstPrefix)
+	VerifyError("DstOriginASN", t, uint32(64497), rec.DstOriginASN)
+	VerifyError("DstOriginASName", t, "Other", rec.DstOriginASName)
+	VerifyError("lookups", t, before+2, prefixLookupLatency.Value())
+
+	rec = &FlowRecord{SrcAddr: "192.0.2.1"}
+	routeLocate(rec)
+	VerifyError("not found", t, "", rec.SrcPrefix)
+	VerifyError("no lookup without address", t, before+3, prefixLookupLatency.Value())
+}
@@ -8,11 +8,11 @@
 package msghandler
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
-	"strings"
 	"time"
 
 	opts "github.com/Juniper/collector/flow-translator/options"
@@ -21,6 +21,7 @@ import (
 // QueryAPI structure
 type QueryAPI struct {
 	netClient *http.Client
+	stages    *recordStages
 }
 
 // QueryAPIMessage structure as the data needs to be pushed to Query API Server
@@ -33,44 +34,55 @@ func (qm *QueryAPI) setup() error {
 	qm.netClient = &http.Client{
 		Timeout: time.Second * 10,
 	}
+	qm.stages = newRecordStages(opts.StrQueryAPI)
 	return nil
 }
 
-func (qm *QueryAPI) handleMessages(mhChan chan []byte) {
+func (qm *QueryAPI) handleMessages(mhChan chan *Message) {
 	var (
-		msg []byte
+		msg *Message
 	)
 	for {
 		select {
 		case msg = <-mhChan:
 			if opts.Verbose {
-				opts.Logger.Println("Received Message on Query API Handler ", string(msg))
+				opts.Logger.Println("Received Message on Query API Handler ",
+					msg.Topic, string(msg.Value))
 			}
 			qm.pushDataToQueryAPIByTopic(msg)
+			msg.ack()
 		}
 	}
 }
 
-func (qm *QueryAPI) serializeDataByTopic(msg []byte) ([]QueryAPIMessage, error) {
-	if opts.KafkaTopic == opts.KafkaTopicVFlowIPFIX {
-		return SerializeIPFIXMsgInQA(msg)
-	} else if opts.KafkaTopic == opts.KafkaTopicVFlowSFlow {
-		return SerializeSFlowMsgInQA(msg)
-	} else {
-		opts.Logger.Println("Not supported Topic:", opts.KafkaTopic)
+func (qm *QueryAPI) serializeDataByTopic(msg *Message) ([]QueryAPIMessage, error) {
+	records, err := recordsByTopic(msg, qm.stages)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]QueryAPIMessage, len(records))
+	for i, rec := range records {
+		res[i] = QueryAPIMessage{TableName: rec.collection(), Data: rec}
 	}
-	return nil, nil
+	return res, nil
 }
 
-func (qm *QueryAPI) pushDataToQueryAPIByTopic(msg []byte) {
+func (qm *QueryAPI) pushDataToQueryAPIByTopic(msg *Message) {
 	augMsgs, err := qm.serializeDataByTopic(msg)
 	if err != nil {
 		opts.Logger.Println("QA -> data serialize error ", err)
+		deadLetter(msg, opts.StrQueryAPI, StageDecode, err)
 		return
 	}
-	qm.pushDataToQueryAPI(augMsgs)
+	if err := qm.pushDataToQueryAPI(augMsgs); err != nil {
+		opts.Logger.Println("QA -> giving up on message ", err)
+		deadLetter(msg, opts.StrQueryAPI, StageDelivery, err)
+	}
 }
 
+// pushDataToQueryAPI posts the records one by one, a record is retried until
+// it is accepted so that none of the records of the message is lost. The
+// message is given up on when a record is rejected
 func (qm *QueryAPI) pushDataToQueryAPI(qmMsgs []QueryAPIMessage) error {
 	var (
 		reqUrl      string
@@ -84,24 +96,34 @@ func (qm *QueryAPI) pushDataToQueryAPI(qmMsgs []QueryAPIMessage) error {
 		qmMsg, err := json.Marshal(qmMsgs[idx])
 		if err != nil {
 			opts.Logger.Println("data json.Marshal() error ", err)
+			continue
 		}
 		if opts.Verbose {
 			opts.Logger.Println("Sending POST data to Query API Server", reqUrl, string(qmMsg))
 		}
-		response, err := qm.netClient.Post(reqUrl, contentType,
-			strings.NewReader(string(qmMsg)))
+		err = withRetry("QueryAPI", func() error {
+			return qm.post(reqUrl, contentType, qmMsg)
+		})
 		if err != nil {
-			opts.Logger.Println("QueryAPI POST error ", err)
-		} else {
-			defer response.Body.Close()
-			body, err := ioutil.ReadAll(response.Body)
-			if err != nil {
-				opts.Logger.Fatalln("Parse error response body ", err)
-			}
-			if opts.Verbose {
-				opts.Logger.Println("Getting response from Query API ", string(body))
-			}
+			return err
 		}
 	}
 	return nil
 }
+
+func (qm *QueryAPI) post(reqUrl string, contentType string, qmMsg []byte) error {
+	response, err := qm.netClient.Post(reqUrl, contentType,
+		bytes.NewReader(qmMsg))
+	if err != nil {
+		return fmt.Errorf("QueryAPI POST error %v", err)
+	}
+	defer response.Body.Close()
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return fmt.Errorf("Parse error response body %v", err)
+	}
+	if opts.Verbose {
+		opts.Logger.Println("Getting response from Query API ", string(body))
+	}
+	return statusError("QueryAPI", response, body)
+}
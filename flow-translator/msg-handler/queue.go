@@ -0,0 +1,110 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    queue.go
+ * details: Bounded queue of the messages waiting for a message handler, the
+ *          overflow is handled as per the configured queue policy
+ *
+ */
+package msghandler
+
+import (
+	"sync"
+
+	"github.com/Juniper/collector/flow-translator/metrics"
+	opts "github.com/Juniper/collector/flow-translator/options"
+)
+
+// the queues of the workers of a message handler are summed up
+var (
+	queueDepth = metrics.NewGauge("flow_translator_sink_queue_depth",
+		"Number of messages waiting in the message handler queue", "sink")
+	queueCapacity = metrics.NewGauge("flow_translator_sink_queue_capacity",
+		"Capacity of the message handler queue", "sink")
+	queueDropped = metrics.NewCounter("flow_translator_sink_queue_dropped_total",
+		"Number of messages dropped as the message handler queue was full",
+		"sink")
+)
+
+type sinkQueue struct {
+	name     string
+	capacity int
+	policy   string
+
+	mu       sync.Mutex
+	notEmpty *sync.Cond
+	notFull  *sync.Cond
+	items    []*Message
+}
+
+func newSinkQueue(name string, capacity int, policy string) *sinkQueue {
+	q := &sinkQueue{
+		name:     name,
+		capacity: capacity,
+		policy:   policy,
+	}
+	q.notEmpty = sync.NewCond(&q.mu)
+	q.notFull = sync.NewCond(&q.mu)
+	queueCapacity.Add(float64(capacity), name)
+	return q
+}
+
+// push queues the message, when the queue is full it blocks or drops a
+// message as per the policy. The dropped message is acknowledged for this
+// message handler so that its offset can still be committed
+func (q *sinkQueue) push(msg *Message) {
+	q.mu.Lock()
+	var dropped *Message
+	if len(q.items) >= q.capacity {
+		switch q.policy {
+		case opts.QueuePolicyDropNewest:
+			dropped = msg
+		case opts.QueuePolicyDropOldest:
+			dropped = q.items[0]
+			q.items = q.items[1:]
+		default:
+			for len(q.items) >= q.capacity {
+				q.notFull.Wait()
+			}
+		}
+	}
+	if dropped != msg {
+		q.items = append(q.items, msg)
+		q.notEmpty.Signal()
+	}
+	if dropped == nil {
+		queueDepth.Inc(q.name)
+	}
+	q.mu.Unlock()
+
+	if dropped != nil {
+		queueDropped.Inc(q.name)
+		if opts.Verbose {
+			opts.Logger.Println(q.name, "queue full, data dropped:",
+				string(dropped.Value))
+		}
+		dropped.ack()
+	}
+}
+
+// pop returns the oldest message, it blocks until one is queued
+func (q *sinkQueue) pop() *Message {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.items) == 0 {
+		q.notEmpty.Wait()
+	}
+	msg := q.items[0]
+	q.items[0] = nil
+	q.items = q.items[1:]
+	q.notFull.Signal()
+	queueDepth.Add(-1, q.name)
+	return msg
+}
+
+// len returns the number of queued messages
+func (q *sinkQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.items)
+}
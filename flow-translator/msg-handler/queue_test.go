@@ -0,0 +1,81 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    queue_test.go
+ * details: Deals with the Unit Test cases for the message handler queues
+ *
+ */
+package msghandler
+
+import (
+	"testing"
+	"time"
+
+	opts "github.com/Juniper/collector/flow-translator/options"
+)
+
+func TestSinkQueueDrop(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  string
+		dropped string
+		first   string
+	}{
+		{
+			name:    "Drop oldest",
+			policy:  opts.QueuePolicyDropOldest,
+			dropped: "1",
+			first:   "2",
+		},
+		{
+			name:    "Drop newest",
+			policy:  opts.QueuePolicyDropNewest,
+			dropped: "3",
+			first:   "1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := "test-" + tt.policy
+			q := newSinkQueue(sink, 2, tt.policy)
+			dropped := queueDropped.Value(sink)
+			depth := queueDepth.Value(sink)
+			acked := ""
+			for _, v := range []string{"1", "2", "3"} {
+				msg := &Message{Value: []byte(v)}
+				msg.OnAck = func() { acked += string(msg.Value) }
+				msg.expectAcks(1)
+				q.push(msg)
+			}
+			VerifyError(tt.name, t, tt.dropped, acked)
+			VerifyError(tt.name, t, 2, q.len())
+			VerifyError(tt.name, t, dropped+1, queueDropped.Value(sink))
+			VerifyError(tt.name, t, depth+2, queueDepth.Value(sink))
+			VerifyError(tt.name, t, tt.first, string(q.pop().Value))
+			VerifyError(tt.name, t, depth+1, queueDepth.Value(sink))
+		})
+	}
+}
+
+func TestSinkQueueBlock(t *testing.T) {
+	q := newSinkQueue("test-block", 1, opts.QueuePolicyBlock)
+	q.push(&Message{Value: []byte("1")})
+
+	pushed := make(chan struct{})
+	go func() {
+		q.push(&Message{Value: []byte("2")})
+		close(pushed)
+	}()
+	select {
+	case <-pushed:
+		t.Fatalf("push did not block on a full queue")
+	case <-time.After(50 * time.Millisecond):
+	}
+	VerifyError("Block", t, "1", string(q.pop().Value))
+	select {
+	case <-pushed:
+	case <-time.After(time.Second):
+		t.Fatalf("push still blocked once the queue was popped")
+	}
+	VerifyError("Block", t, "2", string(q.pop().Value))
+}
@@ -0,0 +1,234 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    record.go
+ * details: The normalized flow record, the messages of every decoder are
+ *          mapped into it and every message handler serializes from it
+ *
+ */
+package msghandler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// Record is a record as stored by the message handlers, a FlowRecord, a
+// CounterRecord, a ConversationRecord or an ExporterStatsRecord. The decoders
+// also return the sequenceSample of a message, which is tracked by the
+// message handlers rather than stored
+type Record interface {
+	collection() string
+}
+
+// FlowRecord is the normalized record of a flow. The decoded messages are
+// split into one record per IPFIX/NetFlow DataSet or per sFlow sample.
+// The times are in milliseconds since the epoch
+type FlowRecord struct {
+	// Collection is the collection/table the record is stored in
+	Collection string `json:"-"`
+
+	Exporter  string `json:"Exporter"`
+	SrcAddr   string `json:"SrcAddr"`
+	DstAddr   string `json:"DstAddr"`
+	SrcPort   uint16 `json:"SrcPort"`
+	DstPort   uint16 `json:"DstPort"`
+	Protocol  uint8  `json:"Protocol"`
+	Bytes     uint64 `json:"Bytes"`
+	Packets   uint64 `json:"Packets"`
+	Start     int64  `json:"Start"`
+	End       int64  `json:"End"`
+	InIf      uint32 `json:"InIf"`
+	OutIf     uint32 `json:"OutIf"`
+	Vlan      uint16 `json:"Vlan"`
+	TCPFlags  uint8  `json:"TCPFlags"`
+	Timestamp int64  `json:"Timestamp"`
+
+	// Timestamp is the event time, as per the configured time sources, taken
+	// from TimeSource. ExportTime and ReceiveTime are the times the message
+	// was exported and received at, Duration is End - Start. ClockSkewed
+	// tells the exporter clock is skewed beyond clock-skew-threshold
+	Duration    int64  `json:"Duration"`
+	ExportTime  int64  `json:"ExportTime"`
+	ReceiveTime int64  `json:"ReceiveTime"`
+	TimeSource  string `json:"TimeSource"`
+	ClockSkewed bool   `json:"ClockSkewed"`
+
+	// SamplingRate is 1 in SamplingRate packets, the estimated bytes and
+	// packets are the sampled ones scaled by the rate
+	SamplingRate     uint32 `json:"SamplingRate"`
+	EstimatedBytes   uint64 `json:"EstimatedBytes"`
+	EstimatedPackets uint64 `json:"EstimatedPackets"`
+
+	// ReverseBytes and ReversePackets are the counters of the reverse
+	// direction of the biflows (RFC 5103), they are 0 for the other flows
+	ReverseBytes   uint64 `json:"ReverseBytes"`
+	ReversePackets uint64 `json:"ReversePackets"`
+
+	// The names of the interfaces and of the VRFs as per the options records
+	// of the exporter
+	InIfName   string `json:"InIfName"`
+	OutIfName  string `json:"OutIfName"`
+	InVRF      uint32 `json:"InVRF"`
+	OutVRF     uint32 `json:"OutVRF"`
+	InVRFName  string `json:"InVRFName"`
+	OutVRFName string `json:"OutVRFName"`
+
+	// The details of the interfaces as per the interface inventory, the
+	// speeds in bits per second
+	InIfDescription  string `json:"InIfDescription"`
+	OutIfDescription string `json:"OutIfDescription"`
+	InIfSpeed        uint64 `json:"InIfSpeed"`
+	OutIfSpeed       uint64 `json:"OutIfSpeed"`
+	InIfRole         string `json:"InIfRole"`
+	OutIfRole        string `json:"OutIfRole"`
+
+	// The country (ISO code), city and autonomous system of the addresses as
+	// per the GeoIP databases, empty when not configured or not found
+	SrcCountry string `json:"SrcCountry"`
+	SrcCity    string `json:"SrcCity"`
+	SrcASN     uint32 `json:"SrcASN"`
+	SrcASOrg   string `json:"SrcASOrg"`
+	DstCountry string `json:"DstCountry"`
+	DstCity    string `json:"DstCity"`
+	DstASN     uint32 `json:"DstASN"`
+	DstASOrg   string `json:"DstASOrg"`
+
+	// The longest prefix of the addresses in the prefix to AS table, with its
+	// origin AS and AS name, empty when not configured or not found
+	SrcPrefix       string `json:"SrcPrefix"`
+	SrcOriginASN    uint32 `json:"SrcOriginASN"`
+	SrcOriginASName string `json:"SrcOriginASName"`
+	DstPrefix       string `json:"DstPrefix"`
+	DstOriginASN    uint32 `json:"DstOriginASN"`
+	DstOriginASName string `json:"DstOriginASName"`
+
+	// The fields as received, for the consumers of the vFlow message format
+	AgentID   string                 `json:"AgentID,omitempty"`
+	Header    map[string]interface{} `json:"Header"`
+	DataSets  map[string]interface{} `json:"DataSets,omitempty"`
+	ExtSWData map[string]interface{} `json:"ExtSWData,omitempty"`
+	Packet    map[string]interface{} `json:"Packet,omitempty"`
+	Sample    map[string]interface{} `json:"Sample,omitempty"`
+}
+
+func (rec *FlowRecord) collection() string {
+	return rec.Collection
+}
+
+// biflow tells whether the record holds both directions of the flow
+func (rec *FlowRecord) biflow() bool {
+	return rec.ReverseBytes > 0 || rec.ReversePackets > 0
+}
+
+// upscale sets the estimated bytes and packets of the flow as per its
+// sampling rate, the flows without sampling rate are not sampled
+func (rec *FlowRecord) upscale() {
+	rate := uint64(rec.SamplingRate)
+	if rate == 0 {
+		rate = 1
+	}
+	rec.EstimatedBytes = rec.Bytes * rate
+	rec.EstimatedPackets = rec.Packets * rate
+}
+
+// fieldUint returns the unsigned integer value of a decoded field, the
+// numbers as decoded with UseNumber or the hex strings as used for the
+// octet array fields such as tcpControlBits
+func fieldUint(v interface{}) (uint64, bool) {
+	switch n := v.(type) {
+	case json.Number:
+		u, err := strconv.ParseUint(n.String(), 10, 64)
+		return u, err == nil
+	case float64:
+		return uint64(n), n >= 0
+	case uint64:
+		return n, true
+	case string:
+		u, err := strconv.ParseUint(n, 0, 64)
+		return u, err == nil
+	}
+	return 0, false
+}
+
+// fieldInt returns the integer value of a decoded field
+func fieldInt(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case json.Number:
+		i, err := n.Int64()
+		return i, err == nil
+	case float64:
+		return int64(n), true
+	case int64:
+		return n, true
+	}
+	return 0, false
+}
+
+// fieldFloat returns the floating point value of a decoded field
+func fieldFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+func fieldString(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return ""
+}
+
+// firstUint returns the first of the fields set, e.g. the delta or the
+// total count
+func firstUint(m map[string]interface{}, names ...string) uint64 {
+	for _, name := range names {
+		if u, ok := fieldUint(m[name]); ok {
+			return u
+		}
+	}
+	return 0
+}
+
+// firstUintOK returns the first of the fields set, and whether any is set
+func firstUintOK(ds map[string]interface{}, names ...string) (uint64, bool) {
+	for _, name := range names {
+		if u, ok := fieldUint(ds[name]); ok {
+			return u, true
+		}
+	}
+	return 0, false
+}
+
+func firstString(m map[string]interface{}, names ...string) string {
+	for _, name := range names {
+		if s := fieldString(m[name]); s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+// timestampMillis returns the microsecond Timestamp of a message in
+// milliseconds
+func timestampMillis(v interface{}, format string) (int64, error) {
+	ts, ok := fieldInt(v)
+	if !ok {
+		return 0, fmt.Errorf("Invalid timeStamp in %s msg", format)
+	}
+	return ts / 1000, nil
+}
+
+// decodeMessage decodes a message keeping the numbers as json.Number
+func decodeMessage(b []byte, msg interface{}) error {
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	return d.Decode(msg)
+}
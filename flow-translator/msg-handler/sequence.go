@@ -0,0 +1,193 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    sequence.go
+ * details: Tracks the sequence numbers of the exporters to account for the
+ *          lost, reordered messages and the exporter restarts
+ *
+ */
+package msghandler
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Juniper/collector/flow-translator/metrics"
+	opts "github.com/Juniper/collector/flow-translator/options"
+)
+
+// sequenceReorderWindow is how far behind the expected sequence number a
+// message is still taken as reordered, further behind the exporter is taken
+// as restarted
+const sequenceReorderWindow = 1 << 16
+
+var (
+	sequenceReceived = metrics.NewCounter(
+		"flow_translator_exporter_sequence_received_total",
+		"Sequence numbers received, per exporter and observation domain",
+		"sink", "exporter", "domain")
+	sequenceLost = metrics.NewCounter(
+		"flow_translator_exporter_sequence_lost_total",
+		"Sequence numbers missing, per exporter and observation domain",
+		"sink", "exporter", "domain")
+	sequenceReordered = metrics.NewCounter(
+		"flow_translator_exporter_sequence_reordered_total",
+		"Messages received after a later message of the exporter",
+		"sink", "exporter", "domain")
+	sequenceResets = metrics.NewCounter(
+		"flow_translator_exporter_sequence_resets_total",
+		"Sequence number resets, e.g. on exporter restart",
+		"sink", "exporter", "domain")
+)
+
+// sequenceSample is the sequence number of a decoded message, it is not
+// stored but tracked by the message handlers. Units is how much the
+// sequence number of the exporter advances with the message: the data
+// records for IPFIX and NetFlow v5, the packets for NetFlow v9 and the
+// datagrams for sFlow. Partial is set when the message has records which
+// could not be decoded, and thus counted, e.g. of an unknown IPFIX template
+type sequenceSample struct {
+	exporter    string
+	domain      uint32
+	decoder     string
+	sequenceNo  uint32
+	units       uint32
+	partial     bool
+	receiveTime int64
+}
+
+func (s *sequenceSample) collection() string {
+	return ""
+}
+
+// ExporterStatsRecord holds the sequence number accounting of an exporter
+// observation domain (the IPFIX domain, the NetFlow v9 source id, the
+// NetFlow v5 engine type and id or the sFlow sub-agent) from Start to End.
+// A reordered message is counted as lost when its gap is seen, and taken off
+// the lost ones of the interval when it is received
+type ExporterStatsRecord struct {
+	Collection string `json:"-"`
+
+	Exporter  string  `json:"Exporter"`
+	DomainID  uint32  `json:"DomainID"`
+	Format    string  `json:"Format"`
+	Start     int64   `json:"Start"`
+	End       int64   `json:"End"`
+	Messages  uint64  `json:"Messages"`
+	Received  uint64  `json:"Received"`
+	Lost      uint64  `json:"Lost"`
+	Reordered uint64  `json:"Reordered"`
+	Resets    uint64  `json:"Resets"`
+	LossRatio float64 `json:"LossRatio"`
+	Timestamp int64   `json:"Timestamp"`
+}
+
+func (rec *ExporterStatsRecord) collection() string {
+	return rec.Collection
+}
+
+type sequenceKey struct {
+	exporter string
+	domain   uint32
+	decoder  string
+}
+
+type sequenceState struct {
+	next uint32
+	last uint32
+	// partial is set when the units of the last message are not all known,
+	// the next sequence number is then taken as is
+	partial bool
+	stats   ExporterStatsRecord
+}
+
+// sequenceTracker keeps the expected sequence number of every exporter
+// observation domain, every message handler has its own as they all see
+// every message
+type sequenceTracker struct {
+	sink     string
+	interval int64
+	mu       sync.Mutex
+	state    map[sequenceKey]*sequenceState
+}
+
+func newSequenceTracker(sink string, interval time.Duration) *sequenceTracker {
+	return &sequenceTracker{
+		sink:     sink,
+		interval: int64(interval / time.Millisecond),
+		state:    map[sequenceKey]*sequenceState{},
+	}
+}
+
+// update accounts for the sequence number of a message, it returns the
+// stats of the exporter domain once the interval has elapsed since the
+// previous ones, as per the receive times
+func (st *sequenceTracker) update(s *sequenceSample) *ExporterStatsRecord {
+	key := sequenceKey{s.exporter, s.domain, s.decoder}
+	domain := strconv.FormatUint(uint64(s.domain), 10)
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	state, ok := st.state[key]
+	if !ok {
+		state = &sequenceState{next: s.sequenceNo + s.units, last: s.sequenceNo}
+		state.stats.Start = s.receiveTime
+		st.state[key] = state
+	}
+	state.stats.Messages++
+	switch diff := s.sequenceNo - state.next; {
+	case !ok || diff == 0:
+		state.next = s.sequenceNo + s.units
+	case s.sequenceNo == state.last:
+		// another message of the same datagram, e.g. the sFlow samples
+		return st.flush(state, s)
+	case diff < 1<<31 && state.partial:
+		// the gap is the records of the last message which were not decoded
+		state.next = s.sequenceNo + s.units
+	case diff < 1<<31:
+		state.stats.Lost += uint64(diff)
+		sequenceLost.Add(float64(diff), st.sink, s.exporter, domain)
+		state.next = s.sequenceNo + s.units
+	case state.next-s.sequenceNo <= sequenceReorderWindow:
+		state.stats.Reordered++
+		sequenceReordered.Inc(st.sink, s.exporter, domain)
+		// it was counted as lost with its gap, unless in a previous interval
+		if state.stats.Lost >= uint64(s.units) {
+			state.stats.Lost -= uint64(s.units)
+		} else {
+			state.stats.Lost = 0
+		}
+	default:
+		state.stats.Resets++
+		sequenceResets.Inc(st.sink, s.exporter, domain)
+		state.next = s.sequenceNo + s.units
+	}
+	state.last = s.sequenceNo
+	if s.sequenceNo+s.units == state.next {
+		state.partial = s.partial
+	}
+	state.stats.Received += uint64(s.units)
+	sequenceReceived.Add(float64(s.units), st.sink, s.exporter, domain)
+	return st.flush(state, s)
+}
+
+// flush returns the stats of the exporter domain if due, and starts the
+// next interval
+func (st *sequenceTracker) flush(state *sequenceState,
+	s *sequenceSample) *ExporterStatsRecord {
+	if st.interval <= 0 || s.receiveTime-state.stats.Start < st.interval {
+		return nil
+	}
+	rec := state.stats
+	rec.Collection = opts.ExporterStatsColl
+	rec.Exporter = s.exporter
+	rec.DomainID = s.domain
+	rec.Format = s.decoder
+	rec.End = s.receiveTime
+	rec.Timestamp = s.receiveTime
+	if total := rec.Received + rec.Lost; total > 0 {
+		rec.LossRatio = float64(rec.Lost) / float64(total)
+	}
+	state.stats = ExporterStatsRecord{Start: s.receiveTime}
+	return &rec
+}
@@ -0,0 +1,139 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    sequence_test.go
+ * details: Deals with the Unit Test cases for the exporter sequence numbers
+ *          accounting
+ *
+ */
+package msghandler
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	opts "github.com/Juniper/collector/flow-translator/options"
+)
+
+func TestSequenceTracker(t *testing.T) {
+	st := newSequenceTracker("test", time.Minute)
+	lost := sequenceLost.Value("test", "10.84.30.180", "524288")
+	resets := sequenceResets.Value("test", "10.84.30.180", "524288")
+	sample := func(seq uint32, units uint32, receiveTime int64) *sequenceSample {
+		return &sequenceSample{exporter: "10.84.30.180", domain: 524288,
+			decoder: opts.DecoderIPFIX, sequenceNo: seq, units: units,
+			receiveTime: receiveTime}
+	}
+	samples := []*sequenceSample{
+		sample(4294967290, 10, 1000), // first message
+		sample(4, 5, 2000),           // in order, across the wrap
+		sample(29, 10, 3000),         // 20 records lost
+		sample(9, 20, 4000),          // the lost ones, reordered, not lost
+		sample(39, 100000, 5000),     // in order
+		sample(39, 100000, 5000),     // duplicated
+		sample(0, 5, 6000),           // exporter restart
+	}
+	for _, s := range samples {
+		if stats := st.update(s); stats != nil {
+			t.Fatalf("Unexpected stats before the interval %+v", stats)
+		}
+	}
+	stats := st.update(sample(5, 10, 61000))
+	if stats == nil {
+		t.Fatalf("Expected stats after the interval")
+	}
+	expected := ExporterStatsRecord{Collection: opts.ExporterStatsColl,
+		Exporter: "10.84.30.180", DomainID: 524288, Format: opts.DecoderIPFIX,
+		Start: 1000, End: 61000, Messages: 8, Received: 100060, Lost: 0,
+		Reordered: 1, Resets: 1, LossRatio: 0, Timestamp: 61000}
+	VerifyError("stats", t, expected, *stats)
+	// the counter of the lost records keeps the gaps seen
+	VerifyError("lost", t, lost+20,
+		sequenceLost.Value("test", "10.84.30.180", "524288"))
+	VerifyError("resets", t, resets+1,
+		sequenceResets.Value("test", "10.84.30.180", "524288"))
+
+	// the next interval starts over
+	stats = st.update(sample(15, 10, 121000))
+	if stats == nil || stats.Messages != 1 || stats.Lost != 0 || stats.Start != 61000 {
+		t.Errorf("Expected the stats of the next interval, got %+v", stats)
+	}
+}
+
+func TestSequenceTrackerPartial(t *testing.T) {
+	st := newSequenceTracker("test", time.Minute)
+	sample := func(seq uint32, units uint32, partial bool,
+		receiveTime int64) *sequenceSample {
+		return &sequenceSample{exporter: "10.84.30.180", domain: 524288,
+			decoder: opts.DecoderIPFIX, sequenceNo: seq, units: units,
+			partial: partial, receiveTime: receiveTime}
+	}
+	samples := []*sequenceSample{
+		sample(100, 10, false, 1000), // first message
+		sample(110, 5, true, 2000),   // records of unknown templates skipped
+		sample(130, 0, false, 3000),  // templates only, after the skipped ones
+		sample(130, 10, false, 4000), // in order
+		sample(150, 10, false, 5000), // 10 records lost
+	}
+	for _, s := range samples {
+		st.update(s)
+	}
+	stats := st.update(sample(160, 10, false, 61000))
+	if stats == nil {
+		t.Fatalf("Expected stats after the interval")
+	}
+	VerifyError("Messages", t, uint64(6), stats.Messages)
+	VerifyError("Received", t, uint64(45), stats.Received)
+	VerifyError("Lost", t, uint64(10), stats.Lost)
+	VerifyError("Resets", t, uint64(0), stats.Resets)
+}
+
+func TestSFlowWithoutSamples(t *testing.T) {
+	value := []byte(`{"Header":{"Version":5,"IPVersion":1,"AgentSubID":16,` +
+		`"SequenceNo":55739,"SysUpTime":1104544871,"SamplesNo":0,` +
+		`"Timestamp":1522108407531878,"IPAddress":"10.84.30.141"}}`)
+	records, err := decodeSFlow(value)
+	if err != nil || len(records) != 1 {
+		t.Fatalf("Expected only the sequence number, got %+v %v", records, err)
+	}
+	if s, ok := records[0].(*sequenceSample); !ok || s.sequenceNo != 55739 {
+		t.Errorf("Expected the sequence number, got %+v", records[0])
+	}
+}
+
+func TestSequenceStage(t *testing.T) {
+	stages := newRecordStages("test")
+	first := MockData[StrTestValidSFlowMessage]
+	// the next datagram a minute later
+	next := bytes.Replace(bytes.Replace(first, []byte(`"SequenceNo":55739`),
+		[]byte(`"SequenceNo":55740`), 1), []byte(`1522108407531878`),
+		[]byte(`1522108467531878`), 1)
+	for i, value := range [][]byte{first, first, next} {
+		records, err := recordsByTopic(&Message{Topic: opts.KafkaTopicVFlowSFlow,
+			Value: value}, stages)
+		if err != nil || len(records) == 0 {
+			t.Fatalf("recordsByTopic %d failed: %v %v", i, records, err)
+		}
+		if _, ok := records[0].(*FlowRecord); !ok {
+			t.Errorf("Expected a flow record, got %+v", records[0])
+		}
+		if i < 2 && len(records) != 1 {
+			t.Errorf("Expected no stats before the interval, got %+v", records)
+		}
+		if i == 2 {
+			if len(records) != 2 {
+				t.Fatalf("Expected the stats after the interval, got %+v", records)
+			}
+			stats, ok := records[1].(*ExporterStatsRecord)
+			if !ok {
+				t.Fatalf("Expected the stats after the interval, got %+v", records[1])
+			}
+			// the samples of the same datagram are not reordered messages
+			VerifyError("Messages", t, uint64(3), stats.Messages)
+			VerifyError("Received", t, uint64(2), stats.Received)
+			VerifyError("Reordered", t, uint64(0), stats.Reordered)
+			VerifyError("Lost", t, uint64(0), stats.Lost)
+		}
+	}
+}
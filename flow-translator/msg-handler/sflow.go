@@ -0,0 +1,163 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    sflow.go
+ * details: Maps the sFlow messages into flow records
+ *
+ */
+package msghandler
+
+import (
+	opts "github.com/Juniper/collector/flow-translator/options"
+	"github.com/Juniper/collector/flow-translator/packet"
+)
+
+// ipv6HeaderLen is added to the IPv6 payload length for the sampled bytes
+const ipv6HeaderLen = 40
+
+// SflowMessage represents sFlow message, a flow sample or the interface
+// counters of a counter sample
+type SflowMessage struct {
+	ExtSWData map[string]interface{} `json:"ExtSWData"`
+	Header    map[string]interface{} `json:"Header"`
+	Packet    map[string]interface{} `json:"Packet"`
+	Sample    map[string]interface{} `json:"Sample"`
+	Counters  map[string]interface{} `json:"Counters"`
+}
+
+// decodeSFlow returns the flow record of a flow sample or the counters
+// record of a counter sample, along with the sequence number of the datagram.
+// The messages of the datagrams without samples only have the sequence number
+func decodeSFlow(b []byte) ([]Record, error) {
+	var msg SflowMessage
+	if err := decodeMessage(b, &msg); err != nil {
+		opts.Logger.Println("sFlow message decode error:", err)
+		return nil, err
+	}
+	timeStamp, err := timestampMillis(msg.Header["Timestamp"], "sflow")
+	if err != nil {
+		return nil, err
+	}
+	var res []Record
+	if msg.Counters != nil {
+		res = append(res, sflowCounterRecord(&msg, timeStamp))
+	} else if msg.Sample != nil {
+		res = append(res, sflowFlowRecord(&msg, timeStamp))
+	}
+	// the samples of a datagram share its sequence number
+	seq, ok := fieldUint(msg.Header["SequenceNo"])
+	if !ok {
+		return res, nil
+	}
+	subAgentID, _ := fieldUint(msg.Header["AgentSubID"])
+	return append(res, &sequenceSample{
+		exporter:    fieldString(msg.Header["IPAddress"]),
+		domain:      uint32(subAgentID),
+		decoder:     opts.DecoderSFlow,
+		sequenceNo:  uint32(seq),
+		units:       1,
+		receiveTime: timeStamp,
+	}), nil
+}
+
+// DecodeSFlowRecords returns the flow record of the sampled packet of the
+// sFlow message, there is none for the counters messages
+func DecodeSFlowRecords(b []byte) ([]FlowRecord, error) {
+	records, err := decodeSFlow(b)
+	if err != nil {
+		return nil, err
+	}
+	return flowRecords(records), nil
+}
+
+// DecodeSFlowCounters returns the interface counters record of the sFlow
+// counters message, there is none for the flow samples
+func DecodeSFlowCounters(b []byte) ([]CounterRecord, error) {
+	records, err := decodeSFlow(b)
+	if err != nil {
+		return nil, err
+	}
+	var res []CounterRecord
+	for _, rec := range records {
+		if c, ok := rec.(*CounterRecord); ok {
+			res = append(res, *c)
+		}
+	}
+	return res, nil
+}
+
+func sflowFlowRecord(msg *SflowMessage, timeStamp int64) *FlowRecord {
+	rec := &FlowRecord{
+		Collection:  opts.SFLOWCollection,
+		Exporter:    fieldString(msg.Header["IPAddress"]),
+		Packets:     1,
+		Start:       timeStamp,
+		End:         timeStamp,
+		InIf:        uint32(firstUint(msg.Sample, "Input")),
+		OutIf:       uint32(firstUint(msg.Sample, "Output")),
+		ReceiveTime: timeStamp,
+		Header:      msg.Header,
+		ExtSWData:   msg.ExtSWData,
+		Packet:      msg.Packet,
+		Sample:      msg.Sample,
+	}
+	l2, _ := msg.Packet["L2"].(map[string]interface{})
+	l3, _ := msg.Packet["L3"].(map[string]interface{})
+	l4, _ := msg.Packet["L4"].(map[string]interface{})
+	rec.Vlan = uint16(firstUint(l2, "Vlan"))
+	if rec.Vlan == 0 {
+		rec.Vlan = uint16(firstUint(msg.ExtSWData, "SrcVlan"))
+	}
+	rec.SrcAddr = fieldString(l3["Src"])
+	rec.DstAddr = fieldString(l3["Dst"])
+	if totalLen, ok := fieldUint(l3["TotalLen"]); ok {
+		rec.Protocol = uint8(firstUint(l3, "Protocol"))
+		rec.Bytes = totalLen
+	} else if payloadLen, ok := fieldUint(l3["PayloadLen"]); ok {
+		rec.Protocol = uint8(firstUint(l3, "NextHeader"))
+		rec.Bytes = payloadLen + ipv6HeaderLen
+	}
+	switch rec.Protocol {
+	case packet.ProtoTCP:
+		rec.TCPFlags = uint8(firstUint(l4, "Flags"))
+		fallthrough
+	case packet.ProtoUDP:
+		rec.SrcPort = uint16(firstUint(l4, "SrcPort"))
+		rec.DstPort = uint16(firstUint(l4, "DstPort"))
+	}
+	rec.SamplingRate = uint32(firstUint(msg.Sample, "SamplingRate"))
+	rec.upscale()
+	rec.setEventTime(opts.DecoderSFlow)
+	return rec
+}
+
+func sflowCounterRecord(msg *SflowMessage, timeStamp int64) *CounterRecord {
+	rec := &CounterRecord{
+		Collection: opts.SFLOWCounters,
+		Exporter:   fieldString(msg.Header["IPAddress"]),
+		SequenceNo: uint32(firstUint(msg.Counters, "SequenceNo")),
+		SysUpTime:  uint32(firstUint(msg.Header, "SysUpTime")),
+		Timestamp:  timeStamp,
+		Counters:   map[string]uint64{},
+	}
+	ifc, _ := msg.Counters["IfCounters"].(map[string]interface{})
+	eth, _ := msg.Counters["EthernetCounters"].(map[string]interface{})
+	if ifc != nil {
+		rec.IfIndex = uint32(firstUint(ifc, "IfIndex"))
+		rec.IfType = uint32(firstUint(ifc, "IfType"))
+		rec.IfSpeed = firstUint(ifc, "IfSpeed")
+		rec.IfDirection = uint32(firstUint(ifc, "IfDirection"))
+		rec.IfStatus = uint32(firstUint(ifc, "IfStatus"))
+	} else {
+		// the source of the sample is the interface
+		rec.IfIndex = uint32(firstUint(msg.Counters, "SourceID")) & 0x00ffffff
+	}
+	for name := range counterWidths {
+		for _, counters := range []map[string]interface{}{ifc, eth} {
+			if v, ok := fieldUint(counters[name]); ok {
+				rec.Counters[name] = v
+			}
+		}
+	}
+	return rec
+}
@@ -1,14 +1,17 @@
 /*
  * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
  *
- * file:    sflow_qa_test.go
- * details: Deals with the Unit Test cases for the exported functions as defined in sflow_qa.go
+ * file:    sflow_test.go
+ * details: Deals with the Unit Test cases for the exported functions as defined in sflow.go
  *
  */
 package msghandler
 
 import (
+	"reflect"
 	"testing"
+
+	opts "github.com/Juniper/collector/flow-translator/options"
 )
 
 type sflowArgs struct {
@@ -18,11 +21,11 @@ type sflowArgs struct {
 type sflowTestStruct struct {
 	name    string
 	args    sflowArgs
-	want    []QueryAPIMessage
+	want    []FlowRecord
 	wantErr bool
 }
 
-func TestSerializeSFlowMsgInQA(t *testing.T) {
+func TestDecodeSFlowRecords(t *testing.T) {
 	tests := []sflowTestStruct{
 		{
 			name: StrTestValidSFlowMessage,
@@ -47,32 +50,49 @@ func TestSerializeSFlowMsgInQA(t *testing.T) {
 		},
 	}
 	for _, tt := range tests {
-		testFnsMap := map[string]func(*testing.T, sflowTestStruct, []QueryAPIMessage, error){
+		testFnsMap := map[string]func(*testing.T, sflowTestStruct, []FlowRecord, error){
 			StrTestValidSFlowMessage:     CheckValidSFlowMessage,
 			StrTestInvalidSFlowMessage:   CheckInvalidSFlowMessage,
 			StrTestInvalidTSSFlowMessage: CheckInvalidTSSFlowMessage,
 		}
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := SerializeSFlowMsgInQA(tt.args.msg)
+			got, err := DecodeSFlowRecords(tt.args.msg)
 			testFnsMap[tt.name](t, tt, got, err)
 		})
 	}
 }
 
-func CheckValidSFlowMessage(t *testing.T, tt sflowTestStruct, result []QueryAPIMessage, err error) {
+func CheckValidSFlowMessage(t *testing.T, tt sflowTestStruct, result []FlowRecord, err error) {
 	if (err != nil) != tt.wantErr {
 		VerifyError(tt.name, t, nil, err.Error())
+		return
+	}
+	if len(result) != 1 {
+		VerifyError(tt.name, t, 1, len(result))
+		return
+	}
+	rec := result[0]
+	expected := FlowRecord{Collection: opts.SFLOWCollection,
+		Exporter: "10.84.30.141", SrcAddr: "10.84.30.201",
+		DstAddr: "172.29.111.95", SrcPort: 9092, DstPort: 54510, Protocol: 6,
+		Bytes: 52, Packets: 1, Start: 1522108407531, End: 1522108407531,
+		InIf: 505, TCPFlags: 16, Timestamp: 1522108407531,
+		ReceiveTime: 1522108407531, TimeSource: opts.TimeSourceReceive,
+		SamplingRate: 2560, EstimatedBytes: 52 * 2560, EstimatedPackets: 2560}
+	rec.Header, rec.ExtSWData, rec.Packet, rec.Sample = nil, nil, nil, nil
+	if !reflect.DeepEqual(expected, rec) {
+		t.Errorf("%s failed, expected '%+v', got '%+v'", tt.name, expected, rec)
 	}
 }
 
-func CheckInvalidSFlowMessage(t *testing.T, tt sflowTestStruct, result []QueryAPIMessage, err error) {
+func CheckInvalidSFlowMessage(t *testing.T, tt sflowTestStruct, result []FlowRecord, err error) {
 	expected := "invalid character 'i' looking for beginning of value"
 	if (err == nil) != tt.wantErr {
 		VerifyError(tt.name, t, expected, err.Error())
 	}
 }
 
-func CheckInvalidTSSFlowMessage(t *testing.T, tt sflowTestStruct, result []QueryAPIMessage, err error) {
+func CheckInvalidTSSFlowMessage(t *testing.T, tt sflowTestStruct, result []FlowRecord, err error) {
 	expected := "Invalid timeStamp in sflow msg"
 	if (err == nil) != tt.wantErr {
 		VerifyError(tt.name, t, expected, err.Error())
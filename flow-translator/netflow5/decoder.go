@@ -0,0 +1,115 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    decoder.go
+ * details: NetFlow v5 packet decoder, the fixed format records are mapped
+ *          onto the IPFIX Information Element names
+ *
+ */
+package netflow5
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+)
+
+const (
+	// Version is the NetFlow version as set in the packet header
+	Version = 5
+
+	packetHeaderLen  = 24
+	recordLen        = 48
+	samplingIntvMask = 0x3fff
+)
+
+// PacketHeader represents the NetFlow v5 Packet Header
+type PacketHeader struct {
+	Version          uint16 `json:"Version"`
+	Count            uint16 `json:"Count"`
+	SysUpTime        uint32 `json:"SysUpTime"`
+	UNIXSecs         uint32 `json:"UNIXSecs"`
+	UNIXNSecs        uint32 `json:"UNIXNSecs"`
+	SeqNum           uint32 `json:"SeqNum"`
+	EngineType       uint8  `json:"EngineType"`
+	EngineID         uint8  `json:"EngineID"`
+	SamplingInterval uint16 `json:"SamplingInterval"`
+}
+
+// Message represents a decoded NetFlow v5 packet, the JSON encoding is the
+// one expected on the vflow.netflow5 topic
+type Message struct {
+	AgentID   string                   `json:"AgentID"`
+	Header    PacketHeader             `json:"Header"`
+	DataSets  []map[string]interface{} `json:"DataSets"`
+	Timestamp int64                    `json:"Timestamp"`
+}
+
+// Decode decodes a NetFlow v5 packet as received from the exporter at the
+// received time
+func Decode(b []byte, exporter net.IP, received time.Time) (*Message, error) {
+	if len(b) < packetHeaderLen {
+		return nil, fmt.Errorf("Invalid NetFlow v5 packet length %d", len(b))
+	}
+	msg := &Message{
+		AgentID: exporter.String(),
+		Header: PacketHeader{
+			Version:          binary.BigEndian.Uint16(b[0:2]),
+			Count:            binary.BigEndian.Uint16(b[2:4]),
+			SysUpTime:        binary.BigEndian.Uint32(b[4:8]),
+			UNIXSecs:         binary.BigEndian.Uint32(b[8:12]),
+			UNIXNSecs:        binary.BigEndian.Uint32(b[12:16]),
+			SeqNum:           binary.BigEndian.Uint32(b[16:20]),
+			EngineType:       b[20],
+			EngineID:         b[21],
+			SamplingInterval: binary.BigEndian.Uint16(b[22:24]),
+		},
+		Timestamp: received.UnixNano() / 1000,
+	}
+	if msg.Header.Version != Version {
+		return nil, fmt.Errorf("Invalid NetFlow version %d", msg.Header.Version)
+	}
+	count := int(msg.Header.Count)
+	if len(b) < packetHeaderLen+count*recordLen {
+		return nil, fmt.Errorf("Invalid NetFlow v5 packet length %d for %d records",
+			len(b), count)
+	}
+	// the router boot time, the record First and Last are relative to it
+	bootTime := int64(msg.Header.UNIXSecs)*1000 +
+		int64(msg.Header.UNIXNSecs)/1e6 - int64(msg.Header.SysUpTime)
+	samplingInterval := msg.Header.SamplingInterval & samplingIntvMask
+	msg.DataSets = make([]map[string]interface{}, count)
+	for i := 0; i < count; i++ {
+		r := b[packetHeaderLen+i*recordLen : packetHeaderLen+(i+1)*recordLen]
+		first := binary.BigEndian.Uint32(r[24:28])
+		last := binary.BigEndian.Uint32(r[28:32])
+		rec := map[string]interface{}{
+			"sourceIPv4Address":           net.IP(r[0:4]).String(),
+			"destinationIPv4Address":      net.IP(r[4:8]).String(),
+			"ipNextHopIPv4Address":        net.IP(r[8:12]).String(),
+			"ingressInterface":            uint64(binary.BigEndian.Uint16(r[12:14])),
+			"egressInterface":             uint64(binary.BigEndian.Uint16(r[14:16])),
+			"packetDeltaCount":            uint64(binary.BigEndian.Uint32(r[16:20])),
+			"octetDeltaCount":             uint64(binary.BigEndian.Uint32(r[20:24])),
+			"flowStartSysUpTime":          uint64(first),
+			"flowEndSysUpTime":            uint64(last),
+			"flowStartMilliseconds":       uint64(bootTime + int64(first)),
+			"flowEndMilliseconds":         uint64(bootTime + int64(last)),
+			"sourceTransportPort":         uint64(binary.BigEndian.Uint16(r[32:34])),
+			"destinationTransportPort":    uint64(binary.BigEndian.Uint16(r[34:36])),
+			"tcpControlBits":              fmt.Sprintf("0x%02x", r[37]),
+			"protocolIdentifier":          uint64(r[38]),
+			"ipClassOfService":            uint64(r[39]),
+			"bgpSourceAsNumber":           uint64(binary.BigEndian.Uint16(r[40:42])),
+			"bgpDestinationAsNumber":      uint64(binary.BigEndian.Uint16(r[42:44])),
+			"sourceIPv4PrefixLength":      uint64(r[44]),
+			"destinationIPv4PrefixLength": uint64(r[45]),
+		}
+		if samplingInterval != 0 {
+			rec["samplingInterval"] = uint64(samplingInterval)
+		}
+		msg.DataSets[i] = rec
+	}
+	return msg, nil
+}
@@ -0,0 +1,114 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    decoder_test.go
+ * details: Deals with the Unit Test cases for the NetFlow v5 decoder
+ *
+ */
+package netflow5
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+var testExporter = net.ParseIP("10.84.30.151")
+
+// testReceived is the receive time of the test packets
+var testReceived = time.Unix(1522040162, 500000000)
+
+func buildPacket(records ...[]byte) []byte {
+	b := make([]byte, packetHeaderLen)
+	binary.BigEndian.PutUint16(b[0:2], Version)
+	binary.BigEndian.PutUint16(b[2:4], uint16(len(records)))
+	binary.BigEndian.PutUint32(b[4:8], 1104544871)
+	binary.BigEndian.PutUint32(b[8:12], 1522040162)
+	binary.BigEndian.PutUint32(b[12:16], 0)
+	binary.BigEndian.PutUint32(b[16:20], 88120)
+	binary.BigEndian.PutUint16(b[22:24], 0x4000|100)
+	for _, r := range records {
+		b = append(b, r...)
+	}
+	return b
+}
+
+func buildRecord() []byte {
+	r := make([]byte, recordLen)
+	copy(r[0:4], net.ParseIP("10.84.29.30").To4())
+	copy(r[4:8], net.ParseIP("10.84.30.218").To4())
+	copy(r[8:12], net.ParseIP("10.84.30.165").To4())
+	binary.BigEndian.PutUint16(r[12:14], 556)
+	binary.BigEndian.PutUint16(r[14:16], 573)
+	binary.BigEndian.PutUint32(r[16:20], 1)
+	binary.BigEndian.PutUint32(r[20:24], 52)
+	binary.BigEndian.PutUint32(r[24:28], 1104540000)
+	binary.BigEndian.PutUint32(r[28:32], 1104541000)
+	binary.BigEndian.PutUint16(r[32:34], 55246)
+	binary.BigEndian.PutUint16(r[34:36], 8780)
+	r[37] = 0x11
+	r[38] = 6
+	binary.BigEndian.PutUint16(r[40:42], 64512)
+	r[45] = 29
+	return r
+}
+
+func TestDecode(t *testing.T) {
+	msg, err := Decode(buildPacket(buildRecord(), buildRecord()), testExporter,
+		testReceived)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if msg.Timestamp != 1522040162500000 {
+		t.Errorf("expected the receive time as Timestamp, got %d", msg.Timestamp)
+	}
+	if len(msg.DataSets) != 2 || msg.Header.SeqNum != 88120 {
+		t.Fatalf("expected 2 records with sequence 88120, got %d and %d",
+			len(msg.DataSets), msg.Header.SeqNum)
+	}
+	expected := map[string]interface{}{
+		"sourceIPv4Address":           "10.84.29.30",
+		"destinationIPv4Address":      "10.84.30.218",
+		"ipNextHopIPv4Address":        "10.84.30.165",
+		"ingressInterface":            uint64(556),
+		"egressInterface":             uint64(573),
+		"octetDeltaCount":             uint64(52),
+		"packetDeltaCount":            uint64(1),
+		"sourceTransportPort":         uint64(55246),
+		"destinationTransportPort":    uint64(8780),
+		"protocolIdentifier":          uint64(6),
+		"tcpControlBits":              "0x11",
+		"bgpSourceAsNumber":           uint64(64512),
+		"destinationIPv4PrefixLength": uint64(29),
+		"flowStartMilliseconds":       uint64(1522040162000 - 1104544871 + 1104540000),
+		"samplingInterval":            uint64(100),
+	}
+	for k, v := range expected {
+		if msg.DataSets[1][k] != v {
+			t.Errorf("field %s expected '%v', got '%v'", k, v, msg.DataSets[1][k])
+		}
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  []byte
+	}{
+		{name: "Short packet", msg: []byte{0x00, 0x05}},
+		{name: "Invalid version", msg: func() []byte {
+			b := buildPacket()
+			b[1] = 9
+			return b
+		}()},
+		{name: "Truncated record", msg: buildPacket(buildRecord())[:packetHeaderLen+10]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Decode(tt.msg, testExporter, testReceived); err == nil {
+				t.Errorf("%s expected error, got nil", tt.name)
+			}
+		})
+	}
+}
@@ -0,0 +1,217 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    decoder.go
+ * details: NetFlow v9 (RFC 3954) packet decoder, the templates are kept per
+ *          exporter and source id in an IPFIX template cache
+ *
+ */
+package netflow9
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/Juniper/collector/flow-translator/ipfix"
+)
+
+const (
+	// Version is the NetFlow version as set in the packet header
+	Version = 9
+
+	packetHeaderLen       = 20
+	flowSetHeaderLen      = 4
+	templateFlowSetID     = 0
+	optionsTemplateSetID  = 1
+	minDataFlowSetID      = 256
+	optionsTemplateHdrLen = 6
+)
+
+// scopeFieldNames are the names of the NetFlow v9 option scope field types
+var scopeFieldNames = map[uint16]string{
+	1: "scopeSystem",
+	2: "scopeInterface",
+	3: "scopeLineCard",
+	4: "scopeCache",
+	5: "scopeTemplate",
+}
+
+// PacketHeader represents the NetFlow v9 Packet Header
+type PacketHeader struct {
+	Version   uint16 `json:"Version"`
+	Count     uint16 `json:"Count"`
+	SysUpTime uint32 `json:"SysUpTime"`
+	UNIXSecs  uint32 `json:"UNIXSecs"`
+	SeqNum    uint32 `json:"SeqNum"`
+	SrcID     uint32 `json:"SrcID"`
+}
+
+// Message represents a decoded NetFlow v9 packet, the JSON encoding is the
+// one expected on the vflow.netflow9 topic
+type Message struct {
+	AgentID         string                   `json:"AgentID"`
+	Header          PacketHeader             `json:"Header"`
+	DataSets        []map[string]interface{} `json:"DataSets"`
+	OptionsDataSets []map[string]interface{} `json:"OptionsDataSets,omitempty"`
+	Timestamp       int64                    `json:"Timestamp"`
+}
+
+// Decoder decodes NetFlow v9 packets
+type Decoder struct {
+	cache *ipfix.TemplateCache
+}
+
+// NewDecoder creates a Decoder using the given TemplateCache
+func NewDecoder(cache *ipfix.TemplateCache) *Decoder {
+	return &Decoder{cache: cache}
+}
+
+// Decode decodes a NetFlow v9 packet as received from the exporter at the
+// received time. Data FlowSets whose template is not known yet are skipped,
+// the number of skipped FlowSets is returned along with the message.
+func (d *Decoder) Decode(b []byte, exporter net.IP, received time.Time) (*Message, int, error) {
+	if len(b) < packetHeaderLen {
+		return nil, 0, fmt.Errorf("Invalid NetFlow v9 packet length %d", len(b))
+	}
+	msg := &Message{
+		AgentID: exporter.String(),
+		Header: PacketHeader{
+			Version:   binary.BigEndian.Uint16(b[0:2]),
+			Count:     binary.BigEndian.Uint16(b[2:4]),
+			SysUpTime: binary.BigEndian.Uint32(b[4:8]),
+			UNIXSecs:  binary.BigEndian.Uint32(b[8:12]),
+			SeqNum:    binary.BigEndian.Uint32(b[12:16]),
+			SrcID:     binary.BigEndian.Uint32(b[16:20]),
+		},
+		DataSets:  []map[string]interface{}{},
+		Timestamp: received.UnixNano() / 1000,
+	}
+	if msg.Header.Version != Version {
+		return nil, 0, fmt.Errorf("Invalid NetFlow version %d", msg.Header.Version)
+	}
+	skipped := 0
+	buf := b[packetHeaderLen:]
+	for len(buf) >= flowSetHeaderLen {
+		setID := binary.BigEndian.Uint16(buf[0:2])
+		setLen := int(binary.BigEndian.Uint16(buf[2:4]))
+		if setLen < flowSetHeaderLen || setLen > len(buf) {
+			return nil, skipped, fmt.Errorf("Invalid NetFlow v9 flowset length %d",
+				setLen)
+		}
+		setBody := buf[flowSetHeaderLen:setLen]
+		buf = buf[setLen:]
+		switch {
+		case setID == templateFlowSetID:
+			if err := d.decodeTemplateFlowSet(setBody, msg); err != nil {
+				return nil, skipped, err
+			}
+		case setID == optionsTemplateSetID:
+			if err := d.decodeOptionsTemplateFlowSet(setBody, msg); err != nil {
+				return nil, skipped, err
+			}
+		case setID >= minDataFlowSetID:
+			t, ok := d.cache.Get(msg.AgentID, msg.Header.SrcID, setID)
+			if !ok {
+				skipped++
+				continue
+			}
+			records, err := decodeDataFlowSet(t, setBody)
+			if err != nil {
+				return nil, skipped, err
+			}
+			if t.IsOptions {
+				msg.OptionsDataSets = append(msg.OptionsDataSets, records...)
+			} else {
+				msg.DataSets = append(msg.DataSets, records...)
+			}
+		}
+	}
+	return msg, skipped, nil
+}
+
+func decodeFields(b []byte, count int) ([]ipfix.TemplateField, []byte, error) {
+	fields := make([]ipfix.TemplateField, count)
+	if len(b) < 4*count {
+		return nil, nil, fmt.Errorf("Invalid NetFlow v9 template field count %d",
+			count)
+	}
+	for i := range fields {
+		fields[i].ElementID = binary.BigEndian.Uint16(b[0:2])
+		fields[i].Length = binary.BigEndian.Uint16(b[2:4])
+		b = b[4:]
+	}
+	return fields, b, nil
+}
+
+func (d *Decoder) decodeTemplateFlowSet(b []byte, msg *Message) error {
+	for len(b) >= 4 {
+		t := &ipfix.Template{ID: binary.BigEndian.Uint16(b[0:2])}
+		if t.ID < minDataFlowSetID {
+			// padding
+			break
+		}
+		fields, rest, err := decodeFields(b[4:], int(binary.BigEndian.Uint16(b[2:4])))
+		if err != nil {
+			return err
+		}
+		t.Fields = fields
+		b = rest
+		d.cache.Add(msg.AgentID, msg.Header.SrcID, t)
+	}
+	return nil
+}
+
+func (d *Decoder) decodeOptionsTemplateFlowSet(b []byte, msg *Message) error {
+	for len(b) >= optionsTemplateHdrLen {
+		t := &ipfix.Template{ID: binary.BigEndian.Uint16(b[0:2]), IsOptions: true}
+		if t.ID < minDataFlowSetID {
+			// padding
+			break
+		}
+		scopeLen := int(binary.BigEndian.Uint16(b[2:4]))
+		optionLen := int(binary.BigEndian.Uint16(b[4:6]))
+		fields, rest, err := decodeFields(b[optionsTemplateHdrLen:],
+			(scopeLen+optionLen)/4)
+		if err != nil {
+			return err
+		}
+		t.ScopeCount = uint16(scopeLen / 4)
+		t.Fields = fields
+		b = rest
+		d.cache.Add(msg.AgentID, msg.Header.SrcID, t)
+	}
+	return nil
+}
+
+func decodeDataFlowSet(t *ipfix.Template, b []byte) ([]map[string]interface{}, error) {
+	var records []map[string]interface{}
+	recLen := 0
+	for _, f := range t.Fields {
+		recLen += int(f.Length)
+	}
+	if recLen == 0 {
+		return records, nil
+	}
+	for len(b) >= recLen {
+		rec := make(map[string]interface{}, len(t.Fields))
+		for i, f := range t.Fields {
+			v := b[:f.Length]
+			b = b[f.Length:]
+			if i < int(t.ScopeCount) {
+				name, ok := scopeFieldNames[f.ElementID]
+				if !ok {
+					name = fmt.Sprintf("scope%d", f.ElementID)
+				}
+				rec[name] = ipfix.InfoElement{Name: name, Type: ipfix.Unsigned32}.
+					Interpret(v)
+				continue
+			}
+			ie := ipfix.LookupElement(0, f.ElementID)
+			rec[ie.Name] = ie.Interpret(v)
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
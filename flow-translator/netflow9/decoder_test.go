@@ -0,0 +1,125 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    decoder_test.go
+ * details: Deals with the Unit Test cases for the NetFlow v9 decoder
+ *
+ */
+package netflow9
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Juniper/collector/flow-translator/ipfix"
+)
+
+var testExporter = net.ParseIP("10.84.30.150")
+
+// testReceived is the receive time of the test packets
+var testReceived = time.Unix(1522040162, 500000000)
+
+func buildPacket(srcID uint32, flowSets ...[]byte) []byte {
+	b := make([]byte, packetHeaderLen)
+	for _, s := range flowSets {
+		b = append(b, s...)
+	}
+	binary.BigEndian.PutUint16(b[0:2], Version)
+	binary.BigEndian.PutUint16(b[2:4], uint16(len(flowSets)))
+	binary.BigEndian.PutUint32(b[4:8], 1104544871)
+	binary.BigEndian.PutUint32(b[8:12], 1522040162)
+	binary.BigEndian.PutUint32(b[12:16], 5560)
+	binary.BigEndian.PutUint32(b[16:20], srcID)
+	return b
+}
+
+func buildFlowSet(id uint16, body []byte) []byte {
+	b := make([]byte, flowSetHeaderLen, flowSetHeaderLen+len(body))
+	binary.BigEndian.PutUint16(b[0:2], id)
+	binary.BigEndian.PutUint16(b[2:4], uint16(flowSetHeaderLen+len(body)))
+	return append(b, body...)
+}
+
+func TestDecode(t *testing.T) {
+	d := NewDecoder(ipfix.NewTemplateCache())
+	// template 256: IPV4_SRC_ADDR, IPV4_DST_ADDR, IN_BYTES, L4_SRC_PORT
+	template := []byte{
+		0x01, 0x00, 0x00, 0x04,
+		0x00, 0x08, 0x00, 0x04,
+		0x00, 0x0c, 0x00, 0x04,
+		0x00, 0x01, 0x00, 0x04,
+		0x00, 0x07, 0x00, 0x02,
+	}
+	// options template 257: scope interface, SAMPLING_INTERVAL
+	optionsTemplate := []byte{
+		0x01, 0x01, 0x00, 0x04, 0x00, 0x04,
+		0x00, 0x02, 0x00, 0x04,
+		0x00, 0x22, 0x00, 0x04,
+		0x00, 0x00, // padding
+	}
+	record := []byte{
+		10, 84, 29, 30,
+		10, 84, 30, 218,
+		0x00, 0x00, 0x05, 0xdc,
+		0xd7, 0xce,
+		0x00, 0x00, // padding
+	}
+	options := []byte{
+		0x00, 0x00, 0x02, 0x2c,
+		0x00, 0x00, 0x0a, 0x00,
+	}
+	msg, skipped, err := d.Decode(buildPacket(7,
+		buildFlowSet(templateFlowSetID, template),
+		buildFlowSet(optionsTemplateSetID, optionsTemplate),
+		buildFlowSet(256, record),
+		buildFlowSet(257, options)), testExporter, testReceived)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if skipped != 0 || len(msg.DataSets) != 1 || len(msg.OptionsDataSets) != 1 {
+		t.Fatalf("expected 1 data and 1 options record, got %d and %d (skipped %d)",
+			len(msg.DataSets), len(msg.OptionsDataSets), skipped)
+	}
+	if msg.Header.SrcID != 7 || msg.Header.SeqNum != 5560 {
+		t.Errorf("unexpected packet header %+v", msg.Header)
+	}
+	if msg.Timestamp != 1522040162500000 {
+		t.Errorf("expected the receive time as Timestamp, got %d", msg.Timestamp)
+	}
+	expected := map[string]interface{}{
+		"sourceIPv4Address":      "10.84.29.30",
+		"destinationIPv4Address": "10.84.30.218",
+		"octetDeltaCount":        uint64(1500),
+		"sourceTransportPort":    uint64(55246),
+	}
+	for k, v := range expected {
+		if msg.DataSets[0][k] != v {
+			t.Errorf("field %s expected '%v', got '%v'", k, v, msg.DataSets[0][k])
+		}
+	}
+	if msg.OptionsDataSets[0]["scopeInterface"] != uint64(556) ||
+		msg.OptionsDataSets[0]["samplingInterval"] != uint64(2560) {
+		t.Errorf("unexpected options record %v", msg.OptionsDataSets[0])
+	}
+
+	// templates are kept per source id
+	_, skipped, _ = d.Decode(buildPacket(8, buildFlowSet(256, record)),
+		testExporter, testReceived)
+	if skipped != 1 {
+		t.Errorf("template must not be shared across source ids")
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	d := NewDecoder(ipfix.NewTemplateCache())
+	if _, _, err := d.Decode([]byte{0x00, 0x09}, testExporter, testReceived); err == nil {
+		t.Errorf("expected error for short packet")
+	}
+	b := buildPacket(0)
+	b[1] = 5
+	if _, _, err := d.Decode(b, testExporter, testReceived); err == nil {
+		t.Errorf("expected error for invalid version")
+	}
+}
@@ -0,0 +1,145 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    kafka.go
+ * details: Builds the librdkafka properties shared by all the Kafka clients,
+ *          the brokers, the TLS and SASL settings and the extra properties
+ *
+ */
+package options
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+)
+
+// The environment variables the Kafka credentials are read from, they take
+// precedence over the config file
+const (
+	EnvKafkaSASLUsername   = "KAFKA_SASL_USERNAME"
+	EnvKafkaSASLPassword   = "KAFKA_SASL_PASSWORD"
+	EnvKafkaSSLKeyPassword = "KAFKA_SSL_KEY_PASSWORD"
+)
+
+var (
+	kafkaSecurityProtocols = []string{"plaintext", "ssl", "sasl_plaintext",
+		"sasl_ssl"}
+	kafkaSASLMechanisms = []string{"PLAIN", "SCRAM-SHA-256", "SCRAM-SHA-512"}
+
+	// kafkaClientConfig holds the properties as built by ParseArgs
+	kafkaClientConfig = map[string]interface{}{}
+)
+
+// KafkaClientConfig returns the librdkafka properties every Kafka client is
+// created with, the clients add their own properties on top of them
+func KafkaClientConfig() map[string]interface{} {
+	conf := map[string]interface{}{
+		"bootstrap.servers": KafkaBrokerList,
+	}
+	for key, value := range kafkaClientConfig {
+		conf[key] = value
+	}
+	return conf
+}
+
+func isOneOf(value string, values []string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// secret returns the value of the environment variable if set, else the
+// content of the file if set, else the value from the config file
+func secret(getenv func(string) string, env string, file string,
+	value string) (string, error) {
+	if v := getenv(env); v != "" {
+		return v, nil
+	}
+	if file != "" {
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(b)), nil
+	}
+	return value, nil
+}
+
+// buildKafkaClientConfig validates the Kafka security settings of the config
+// and returns the resulting librdkafka properties
+func buildKafkaClientConfig(config *ConfigOptions,
+	getenv func(string) string) (map[string]interface{}, error) {
+	conf := make(map[string]interface{})
+	for key, value := range config.KafkaProperties {
+		conf[key] = value
+	}
+
+	protocol := strings.ToLower(config.KafkaSecurityProtocol)
+	if protocol == "" {
+		return conf, nil
+	}
+	if !isOneOf(protocol, kafkaSecurityProtocols) {
+		return nil, fmt.Errorf("Invalid kafka-security-protocol '%s'",
+			config.KafkaSecurityProtocol)
+	}
+	conf["security.protocol"] = protocol
+
+	if strings.HasSuffix(protocol, "ssl") {
+		for key, value := range map[string]string{
+			"ssl.ca.location":          config.KafkaSSLCALocation,
+			"ssl.certificate.location": config.KafkaSSLCertLocation,
+			"ssl.key.location":         config.KafkaSSLKeyLocation,
+		} {
+			if value != "" {
+				conf[key] = value
+			}
+		}
+		keyPassword, err := secret(getenv, EnvKafkaSSLKeyPassword,
+			config.KafkaSSLKeyPasswordFile, config.KafkaSSLKeyPassword)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid kafka-ssl-key-password-file: %v", err)
+		}
+		if keyPassword != "" {
+			conf["ssl.key.password"] = keyPassword
+		}
+	}
+
+	if strings.HasPrefix(protocol, "sasl") {
+		mechanism := strings.ToUpper(config.KafkaSASLMechanism)
+		if !isOneOf(mechanism, kafkaSASLMechanisms) {
+			return nil, fmt.Errorf("Invalid kafka-sasl-mechanism '%s'",
+				config.KafkaSASLMechanism)
+		}
+		username, err := secret(getenv, EnvKafkaSASLUsername,
+			config.KafkaSASLUsernameFile, config.KafkaSASLUsername)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid kafka-sasl-username-file: %v", err)
+		}
+		password, err := secret(getenv, EnvKafkaSASLPassword,
+			config.KafkaSASLPasswordFile, config.KafkaSASLPassword)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid kafka-sasl-password-file: %v", err)
+		}
+		if username == "" || password == "" {
+			return nil, fmt.Errorf("No SASL credentials for %s", protocol)
+		}
+		conf["sasl.mechanisms"] = mechanism
+		conf["sasl.username"] = username
+		conf["sasl.password"] = password
+	}
+	return conf, nil
+}
+
+func parseKafkaClientConfig(config *ConfigOptions) {
+	conf, err := buildKafkaClientConfig(config, os.Getenv)
+	if err != nil {
+		log.Fatalf("Config file %v: %v", MHConfigFile, err)
+	}
+	kafkaClientConfig = conf
+}
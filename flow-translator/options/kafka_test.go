@@ -0,0 +1,110 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    kafka_test.go
+ * details: Deals with the Unit Test cases for the Kafka client properties
+ *
+ */
+package options
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestBuildKafkaClientConfig(t *testing.T) {
+	f, err := ioutil.TempFile("", "sasl-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("from-file\n")
+	f.Close()
+
+	env := map[string]string{EnvKafkaSASLUsername: "from-env"}
+	getenv := func(key string) string { return env[key] }
+
+	tests := []struct {
+		name     string
+		config   ConfigOptions
+		expected map[string]interface{}
+		wantErr  bool
+	}{
+		{
+			name: "Plaintext with extra properties",
+			config: ConfigOptions{
+				KafkaProperties: map[string]string{"client.id": "translator"},
+			},
+			expected: map[string]interface{}{"client.id": "translator"},
+		},
+		{
+			name: "SSL",
+			config: ConfigOptions{
+				KafkaSecurityProtocol: "SSL",
+				KafkaSSLCALocation:    "/etc/kafka/ca.pem",
+				KafkaSSLKeyPassword:   "secret",
+			},
+			expected: map[string]interface{}{
+				"security.protocol": "ssl",
+				"ssl.ca.location":   "/etc/kafka/ca.pem",
+				"ssl.key.password":  "secret",
+			},
+		},
+		{
+			name: "SASL SCRAM with credentials from env and file",
+			config: ConfigOptions{
+				KafkaSecurityProtocol: "sasl_ssl",
+				KafkaSASLMechanism:    "scram-sha-512",
+				KafkaSASLUsername:     "from-config",
+				KafkaSASLPasswordFile: f.Name(),
+			},
+			expected: map[string]interface{}{
+				"security.protocol": "sasl_ssl",
+				"sasl.mechanisms":   "SCRAM-SHA-512",
+				"sasl.username":     "from-env",
+				"sasl.password":     "from-file",
+			},
+		},
+		{
+			name:    "Invalid protocol",
+			config:  ConfigOptions{KafkaSecurityProtocol: "tls"},
+			wantErr: true,
+		},
+		{
+			name: "Invalid mechanism",
+			config: ConfigOptions{KafkaSecurityProtocol: "sasl_plaintext",
+				KafkaSASLMechanism: "GSSAPI"},
+			wantErr: true,
+		},
+		{
+			name: "No SASL password",
+			config: ConfigOptions{KafkaSecurityProtocol: "sasl_plaintext",
+				KafkaSASLMechanism: "PLAIN"},
+			wantErr: true,
+		},
+		{
+			name: "Missing password file",
+			config: ConfigOptions{KafkaSecurityProtocol: "sasl_plaintext",
+				KafkaSASLMechanism: "PLAIN", KafkaSASLPasswordFile: "/nonexistent"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf, err := buildKafkaClientConfig(&tt.config, getenv)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("%s expected error %v, got %v", tt.name, tt.wantErr, err)
+			}
+			if len(conf) != len(tt.expected) {
+				t.Errorf("%s expected %v, got %v", tt.name, tt.expected, conf)
+			}
+			for key, value := range tt.expected {
+				if conf[key] != value {
+					t.Errorf("%s %s: expected '%v', got '%v'", tt.name, key,
+						value, conf[key])
+				}
+			}
+		})
+	}
+}
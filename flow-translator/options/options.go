@@ -11,6 +11,9 @@ import (
 	"io/ioutil"
 	"log"
 	"os"
+	"runtime"
+	"sort"
+	"time"
 
 	"github.com/urfave/cli"
 	"gopkg.in/yaml.v2"
@@ -18,39 +21,160 @@ import (
 
 // ConfigOptions configuration options
 type ConfigOptions struct {
-	Verbose           bool   `yaml:"verbose" env:"IPFIX_TRANSLATOR_LOG_ENABLE"`
-	KafkaBrokerList   string `yaml:"kafka-broker-list" env:"KAFKA_BROKER_LIST"`
-	KafkaTopic        string `yaml:"kafka-topic" env:"KAFKA_TOPIC"`
-	DataMgrIpAddress  string `yaml:"data-manager-ip" env:"DATA_MANAGER_IP_ADDRESS"`
-	DataMgrPort       string `yaml:"data-manager-port" env:"DATA_MANAGER_PORT"`
-	QueryApiIPAddress string `yaml:"query-api-ip" env:"QUERY_API_IP_ADDRESS"`
-	QueryApiPort      string `yaml:"query-api-port" env:"QUERY_API_PORT"`
-	LogFile           string `yaml:"log-file" env:"IPFIX_LOG_FILE"`
-	SendToDM          bool   `yaml:"sendto-data-manager" env:"SENDTO_DATA_MANAGER"`
-	SendToQA          bool   `yaml:"sendto-query-api" env:"SENDTO_QUERY_API"`
+	Verbose            bool              `yaml:"verbose" env:"IPFIX_TRANSLATOR_LOG_ENABLE"`
+	KafkaBrokerList    string            `yaml:"kafka-broker-list" env:"KAFKA_BROKER_LIST"`
+	KafkaTopic         string            `yaml:"kafka-topic" env:"KAFKA_TOPIC"`
+	DataMgrIpAddress   string            `yaml:"data-manager-ip" env:"DATA_MANAGER_IP_ADDRESS"`
+	DataMgrPort        string            `yaml:"data-manager-port" env:"DATA_MANAGER_PORT"`
+	QueryApiIPAddress  string            `yaml:"query-api-ip" env:"QUERY_API_IP_ADDRESS"`
+	QueryApiPort       string            `yaml:"query-api-port" env:"QUERY_API_PORT"`
+	LogFile            string            `yaml:"log-file" env:"IPFIX_LOG_FILE"`
+	SendToDM           bool              `yaml:"sendto-data-manager" env:"SENDTO_DATA_MANAGER"`
+	SendToQA           bool              `yaml:"sendto-query-api" env:"SENDTO_QUERY_API"`
+	SendToKafka        bool              `yaml:"sendto-kafka" env:"SENDTO_KAFKA"`
+	KafkaOutputTopic   string            `yaml:"kafka-output-topic" env:"KAFKA_OUTPUT_TOPIC"`
+	KafkaOutputKey     string            `yaml:"kafka-output-key" env:"KAFKA_OUTPUT_KEY"`
+	KafkaOutputEnc     string            `yaml:"kafka-output-encoding" env:"KAFKA_OUTPUT_ENCODING"`
+	IPFIXListenAddr    string            `yaml:"ipfix-listen-address" env:"IPFIX_LISTEN_ADDRESS"`
+	Netflow9ListenAddr string            `yaml:"netflow9-listen-address" env:"NETFLOW9_LISTEN_ADDRESS"`
+	Netflow5ListenAddr string            `yaml:"netflow5-listen-address" env:"NETFLOW5_LISTEN_ADDRESS"`
+	SFlowListenAddr    string            `yaml:"sflow-listen-address" env:"SFLOW_LISTEN_ADDRESS"`
+	KafkaTopics        map[string]string `yaml:"kafka-topics" env:"KAFKA_TOPICS"`
+	KafkaCommitIntv    time.Duration     `yaml:"kafka-commit-interval" env:"KAFKA_COMMIT_INTERVAL"`
+	KafkaGroupID       string            `yaml:"kafka-group-id" env:"KAFKA_GROUP_ID"`
+	KafkaOffsetReset   string            `yaml:"kafka-auto-offset-reset" env:"KAFKA_AUTO_OFFSET_RESET"`
+	KafkaDLQTopic      string            `yaml:"kafka-dlq-topic" env:"KAFKA_DLQ_TOPIC"`
+	SinkMaxRetries     int               `yaml:"sink-max-retries" env:"SINK_MAX_RETRIES"`
+	SinkQueueSize      int               `yaml:"sink-queue-size" env:"SINK_QUEUE_SIZE"`
+	SinkWorkers        int               `yaml:"sink-workers" env:"SINK_WORKERS"`
+	SinkQueuePolicy    string            `yaml:"sink-queue-policy" env:"SINK_QUEUE_POLICY"`
+	MetricsListenAddr  string            `yaml:"metrics-listen-address" env:"METRICS_LISTEN_ADDRESS"`
+	SinkRetryBackoff   time.Duration     `yaml:"sink-retry-backoff" env:"SINK_RETRY_BACKOFF"`
+	SinkRetryMaxBack   time.Duration     `yaml:"sink-retry-max-backoff" env:"SINK_RETRY_MAX_BACKOFF"`
+	IPFIXElemsFile     string            `yaml:"ipfix-elements-file" env:"IPFIX_ELEMENTS_FILE"`
+	IPFIXEntElemsFile  string            `yaml:"ipfix-enterprise-elements-file" env:"IPFIX_ENTERPRISE_ELEMENTS_FILE"`
+	StitchWindow       time.Duration     `yaml:"stitch-window" env:"STITCH_WINDOW"`
+	ClockSkewThreshold time.Duration     `yaml:"clock-skew-threshold" env:"CLOCK_SKEW_THRESHOLD"`
+	ExporterStatsIntv  time.Duration     `yaml:"exporter-stats-interval" env:"EXPORTER_STATS_INTERVAL"`
+	GeoIPCityDB        string            `yaml:"geoip-city-database" env:"GEOIP_CITY_DATABASE"`
+	GeoIPASNDB         string            `yaml:"geoip-asn-database" env:"GEOIP_ASN_DATABASE"`
+	GeoIPReloadIntv    time.Duration     `yaml:"geoip-reload-interval" env:"GEOIP_RELOAD_INTERVAL"`
+	PrefixASNFile      string            `yaml:"prefix-asn-file" env:"PREFIX_ASN_FILE"`
+	ASNamesFile        string            `yaml:"as-names-file" env:"AS_NAMES_FILE"`
+	PrefixReloadIntv   time.Duration     `yaml:"prefix-asn-reload-interval" env:"PREFIX_ASN_RELOAD_INTERVAL"`
+	InventoryFiles     []string          `yaml:"interface-inventory-files" env:"INTERFACE_INVENTORY_FILES"`
+	InventoryReload    time.Duration     `yaml:"interface-inventory-reload-interval" env:"INTERFACE_INVENTORY_RELOAD_INTERVAL"`
+
+	KafkaSecurityProtocol   string            `yaml:"kafka-security-protocol" env:"KAFKA_SECURITY_PROTOCOL"`
+	KafkaSSLCALocation      string            `yaml:"kafka-ssl-ca-location" env:"KAFKA_SSL_CA_LOCATION"`
+	KafkaSSLCertLocation    string            `yaml:"kafka-ssl-certificate-location" env:"KAFKA_SSL_CERTIFICATE_LOCATION"`
+	KafkaSSLKeyLocation     string            `yaml:"kafka-ssl-key-location" env:"KAFKA_SSL_KEY_LOCATION"`
+	KafkaSSLKeyPassword     string            `yaml:"kafka-ssl-key-password" env:"KAFKA_SSL_KEY_PASSWORD"`
+	KafkaSSLKeyPasswordFile string            `yaml:"kafka-ssl-key-password-file" env:"KAFKA_SSL_KEY_PASSWORD_FILE"`
+	KafkaSASLMechanism      string            `yaml:"kafka-sasl-mechanism" env:"KAFKA_SASL_MECHANISM"`
+	KafkaSASLUsername       string            `yaml:"kafka-sasl-username" env:"KAFKA_SASL_USERNAME"`
+	KafkaSASLUsernameFile   string            `yaml:"kafka-sasl-username-file" env:"KAFKA_SASL_USERNAME_FILE"`
+	KafkaSASLPassword       string            `yaml:"kafka-sasl-password" env:"KAFKA_SASL_PASSWORD"`
+	KafkaSASLPasswordFile   string            `yaml:"kafka-sasl-password-file" env:"KAFKA_SASL_PASSWORD_FILE"`
+	KafkaProperties         map[string]string `yaml:"kafka-properties" env:"KAFKA_PROPERTIES"`
+
+	EventTime map[string][]string `yaml:"event-time" env:"EVENT_TIME"`
 }
 
 var (
-	Verbose              = false
-	KafkaBrokerList      = "127.0.0.1:9092"
-	KafkaTopicVFlowIPFIX = "vflow.ipfix"
-	KafkaTopicVFlowSFlow = "vflow.sflow"
-	DataMgrIpAddress     = "127.0.0.1"
-	DataMgrPort          = "9000"
-	QueryApiIPAddress    = "127.0.0.1"
-	QueryApiPort         = "8080"
-	LogFile              = "/var/log/flow-translator.log"
-	SendToDM             = false
-	SendToQA             = true
+	Verbose                 = false
+	KafkaBrokerList         = "127.0.0.1:9092"
+	KafkaTopicVFlowIPFIX    = "vflow.ipfix"
+	KafkaTopicVFlowSFlow    = "vflow.sflow"
+	KafkaTopicVFlowNetflow9 = "vflow.netflow9"
+	KafkaTopicVFlowNetflow5 = "vflow.netflow5"
+	DataMgrIpAddress        = "127.0.0.1"
+	DataMgrPort             = "9000"
+	QueryApiIPAddress       = "127.0.0.1"
+	QueryApiPort            = "8080"
+	LogFile                 = "/var/log/flow-translator.log"
+	SendToDM                = false
+	SendToQA                = true
+	SendToKafka             = false
+	KafkaOutputTopic        = "flow.normalized"
+	KafkaOutputKey          = KafkaKeyAgentID
+	KafkaOutputEncoding     = KafkaEncodingJSON
+	IPFIXListenAddr         = ":4739"
+	Netflow9ListenAddr      = ":4729"
+	Netflow5ListenAddr      = ":2055"
+	SFlowListenAddr         = ":6343"
+	KafkaCommitInterval     = time.Second
+	KafkaDLQTopic           = ""
+	KafkaGroupID            = StrKafkaConGroupID
+	KafkaAutoOffsetReset    = "earliest"
+	SinkMaxRetries          = 0
+	SinkRetryBackoff        = time.Second
+	SinkRetryMaxBackoff     = time.Minute
+	SinkQueueSize           = 10000
+	SinkWorkers             = runtime.NumCPU()
+	SinkQueuePolicy         = QueuePolicyBlock
+	MetricsListenAddr       = ""
+	IPFIXElementsFile       = ""
+	IPFIXEntElementsFile    = ""
+	StitchWindow            = time.Duration(0)
+	ClockSkewThreshold      = time.Minute
+	ExporterStatsInterval   = time.Minute
+	GeoIPCityDatabase       = ""
+	GeoIPASNDatabase        = ""
+	GeoIPReloadInterval     = time.Minute
+	PrefixASNFile           = ""
+	ASNamesFile             = ""
+	PrefixASNReloadInterval = time.Minute
+	InventoryFiles          = []string{}
+	InventoryReloadInterval = time.Minute
 
 	StrDataManager     = "data-manager"
 	StrQueryAPI        = "query-api"
+	StrKafka           = "kafka"
 	StrKafkaConGroupID = "ipfixConsGrpID"
+	StrKafkaDLQGroupID = "ipfixDLQRedriveGrpID"
 	MHConfigFileStr    = "config-file"
 	MHConfigFile       = "/etc/flow-translator/flow-translator.conf"
 	KafkaTopic         = KafkaTopicVFlowIPFIX
 	IPFIXCollection    = "ipfix_collection"
 	SFLOWCollection    = "sflow_collection"
+	NetflowCollection  = "netflow_collection"
+	SFLOWCounters      = "sflow_counters"
+	ConversationColl   = "conversation_collection"
+	ExporterStatsColl  = "exporter_stats"
+
+	KafkaKeyNone      = "none"
+	KafkaKeyAgentID   = "agent-id"
+	KafkaKeyFiveTuple = "5-tuple"
+	KafkaEncodingJSON = "json"
+
+	QueuePolicyBlock      = "block"
+	QueuePolicyDropOldest = "drop-oldest"
+	QueuePolicyDropNewest = "drop-newest"
+
+	DecoderIPFIX    = "ipfix"
+	DecoderSFlow    = "sflow"
+	DecoderNetflow9 = "netflow9"
+	DecoderNetflow5 = "netflow5"
+
+	TimeSourceStart   = "start"
+	TimeSourceEnd     = "end"
+	TimeSourceExport  = "export"
+	TimeSourceReceive = "receive"
+
+	// KafkaTopics maps the subscribed topics to the decoder of their messages
+	KafkaTopics = map[string]string{}
+	// EventTimeSources are the sources of the record time by decoder, the
+	// first source the record has is used, the receive time otherwise
+	EventTimeSources = map[string][]string{}
+	// DefaultTopicDecoders are the decoders of the vFlow topics, also used for
+	// the messages received by the listeners
+	DefaultTopicDecoders = map[string]string{
+		KafkaTopicVFlowIPFIX:    DecoderIPFIX,
+		KafkaTopicVFlowSFlow:    DecoderSFlow,
+		KafkaTopicVFlowNetflow9: DecoderNetflow9,
+		KafkaTopicVFlowNetflow5: DecoderNetflow5,
+	}
 )
 
 var Logger *log.Logger
@@ -63,14 +187,41 @@ func ParseArgs(c *cli.Context) error {
 		log.Fatalln("config file read error ", err)
 	}
 	config := ConfigOptions{
-		Verbose:          Verbose,
-		KafkaBrokerList:  KafkaBrokerList,
-		DataMgrIpAddress: DataMgrIpAddress,
-		DataMgrPort:      DataMgrPort,
-		LogFile:          LogFile,
-		KafkaTopic:       KafkaTopic,
-		SendToDM:         SendToDM,
-		SendToQA:         SendToQA,
+		Verbose:            Verbose,
+		KafkaBrokerList:    KafkaBrokerList,
+		DataMgrIpAddress:   DataMgrIpAddress,
+		DataMgrPort:        DataMgrPort,
+		LogFile:            LogFile,
+		KafkaTopic:         KafkaTopic,
+		SendToDM:           SendToDM,
+		SendToQA:           SendToQA,
+		SendToKafka:        SendToKafka,
+		KafkaOutputTopic:   KafkaOutputTopic,
+		KafkaOutputKey:     KafkaOutputKey,
+		KafkaOutputEnc:     KafkaOutputEncoding,
+		IPFIXListenAddr:    IPFIXListenAddr,
+		Netflow9ListenAddr: Netflow9ListenAddr,
+		Netflow5ListenAddr: Netflow5ListenAddr,
+		SFlowListenAddr:    SFlowListenAddr,
+		KafkaCommitIntv:    KafkaCommitInterval,
+		KafkaDLQTopic:      KafkaDLQTopic,
+		KafkaGroupID:       KafkaGroupID,
+		KafkaOffsetReset:   KafkaAutoOffsetReset,
+		SinkMaxRetries:     SinkMaxRetries,
+		SinkQueueSize:      SinkQueueSize,
+		SinkWorkers:        SinkWorkers,
+		SinkQueuePolicy:    SinkQueuePolicy,
+		MetricsListenAddr:  MetricsListenAddr,
+		SinkRetryBackoff:   SinkRetryBackoff,
+		SinkRetryMaxBack:   SinkRetryMaxBackoff,
+		IPFIXElemsFile:     IPFIXElementsFile,
+		IPFIXEntElemsFile:  IPFIXEntElementsFile,
+		StitchWindow:       StitchWindow,
+		ClockSkewThreshold: ClockSkewThreshold,
+		ExporterStatsIntv:  ExporterStatsInterval,
+		GeoIPReloadIntv:    GeoIPReloadInterval,
+		PrefixReloadIntv:   PrefixASNReloadInterval,
+		InventoryReload:    InventoryReloadInterval,
 	}
 	err = yaml.Unmarshal(b, &config)
 	if err != nil {
@@ -84,6 +235,123 @@ func ParseArgs(c *cli.Context) error {
 	KafkaTopic = config.KafkaTopic
 	SendToDM = config.SendToDM
 	SendToQA = config.SendToQA
+	SendToKafka = config.SendToKafka
+	KafkaOutputTopic = config.KafkaOutputTopic
+	KafkaOutputKey = config.KafkaOutputKey
+	KafkaOutputEncoding = config.KafkaOutputEnc
+	IPFIXListenAddr = config.IPFIXListenAddr
+	Netflow9ListenAddr = config.Netflow9ListenAddr
+	Netflow5ListenAddr = config.Netflow5ListenAddr
+	SFlowListenAddr = config.SFlowListenAddr
+	KafkaCommitInterval = config.KafkaCommitIntv
+	if KafkaCommitInterval <= 0 {
+		log.Fatalf("Config file %v invalid kafka-commit-interval %v",
+			MHConfigFile, KafkaCommitInterval)
+	}
+	KafkaDLQTopic = config.KafkaDLQTopic
+	KafkaGroupID = config.KafkaGroupID
+	KafkaAutoOffsetReset = config.KafkaOffsetReset
+	switch KafkaAutoOffsetReset {
+	case "earliest", "latest":
+	default:
+		log.Fatalf("Config file %v invalid kafka-auto-offset-reset '%v'",
+			MHConfigFile, KafkaAutoOffsetReset)
+	}
+	SinkMaxRetries = config.SinkMaxRetries
+	SinkRetryBackoff = config.SinkRetryBackoff
+	SinkRetryMaxBackoff = config.SinkRetryMaxBack
+	SinkQueueSize = config.SinkQueueSize
+	if SinkQueueSize <= 0 {
+		log.Fatalf("Config file %v invalid sink-queue-size %v",
+			MHConfigFile, SinkQueueSize)
+	}
+	SinkWorkers = config.SinkWorkers
+	if SinkWorkers <= 0 {
+		log.Fatalf("Config file %v invalid sink-workers %v",
+			MHConfigFile, SinkWorkers)
+	}
+	SinkQueuePolicy = config.SinkQueuePolicy
+	switch SinkQueuePolicy {
+	case QueuePolicyBlock, QueuePolicyDropOldest, QueuePolicyDropNewest:
+	default:
+		log.Fatalf("Config file %v invalid sink-queue-policy '%v'",
+			MHConfigFile, SinkQueuePolicy)
+	}
+	MetricsListenAddr = config.MetricsListenAddr
+	IPFIXElementsFile = config.IPFIXElemsFile
+	IPFIXEntElementsFile = config.IPFIXEntElemsFile
+	StitchWindow = config.StitchWindow
+	if StitchWindow < 0 {
+		log.Fatalf("Config file %v invalid stitch-window %v",
+			MHConfigFile, StitchWindow)
+	}
+	EventTimeSources = config.EventTime
+	for decoder, sources := range EventTimeSources {
+		if !isValidDecoder(decoder) {
+			log.Fatalf("Config file %v invalid event-time decoder '%v'",
+				MHConfigFile, decoder)
+		}
+		for _, source := range sources {
+			if !isValidTimeSource(source) {
+				log.Fatalf("Config file %v invalid event-time source '%v' for %v",
+					MHConfigFile, source, decoder)
+			}
+		}
+	}
+	ClockSkewThreshold = config.ClockSkewThreshold
+	if ClockSkewThreshold < 0 {
+		log.Fatalf("Config file %v invalid clock-skew-threshold %v",
+			MHConfigFile, ClockSkewThreshold)
+	}
+	ExporterStatsInterval = config.ExporterStatsIntv
+	if ExporterStatsInterval < 0 {
+		log.Fatalf("Config file %v invalid exporter-stats-interval %v",
+			MHConfigFile, ExporterStatsInterval)
+	}
+	GeoIPCityDatabase = config.GeoIPCityDB
+	GeoIPASNDatabase = config.GeoIPASNDB
+	GeoIPReloadInterval = config.GeoIPReloadIntv
+	if GeoIPReloadInterval < 0 {
+		log.Fatalf("Config file %v invalid geoip-reload-interval %v",
+			MHConfigFile, GeoIPReloadInterval)
+	}
+	PrefixASNFile = config.PrefixASNFile
+	ASNamesFile = config.ASNamesFile
+	if ASNamesFile != "" && PrefixASNFile == "" {
+		log.Fatalf("Config file %v as-names-file without prefix-asn-file",
+			MHConfigFile)
+	}
+	PrefixASNReloadInterval = config.PrefixReloadIntv
+	if PrefixASNReloadInterval < 0 {
+		log.Fatalf("Config file %v invalid prefix-asn-reload-interval %v",
+			MHConfigFile, PrefixASNReloadInterval)
+	}
+	InventoryFiles = config.InventoryFiles
+	InventoryReloadInterval = config.InventoryReload
+	if InventoryReloadInterval < 0 {
+		log.Fatalf("Config file %v invalid interface-inventory-reload-interval %v",
+			MHConfigFile, InventoryReloadInterval)
+	}
+	parseKafkaClientConfig(&config)
+	KafkaTopics = config.KafkaTopics
+	if len(KafkaTopics) == 0 {
+		// a topic other than the vFlow ones is decoded as IPFIX
+		decoder, ok := DefaultTopicDecoders[KafkaTopic]
+		if !ok {
+			decoder = DecoderIPFIX
+		}
+		KafkaTopics = map[string]string{KafkaTopic: decoder}
+	}
+	if _, ok := KafkaTopics[KafkaDLQTopic]; ok {
+		log.Fatalf("Config file %v kafka-dlq-topic %v is also consumed",
+			MHConfigFile, KafkaDLQTopic)
+	}
+	for topic, decoder := range KafkaTopics {
+		if !isValidDecoder(decoder) {
+			log.Fatalf("Config file %v invalid decoder '%v' for topic %v",
+				MHConfigFile, decoder, topic)
+		}
+	}
 	if LogFile != "" {
 		Logger = log.New(os.Stderr, "[jFlow] ", log.Ldate|log.Ltime)
 		f, err := os.OpenFile(LogFile, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
@@ -95,3 +363,30 @@ func ParseArgs(c *cli.Context) error {
 	}
 	return nil
 }
+
+func isValidDecoder(decoder string) bool {
+	for _, d := range DefaultTopicDecoders {
+		if d == decoder {
+			return true
+		}
+	}
+	return false
+}
+
+func isValidTimeSource(source string) bool {
+	switch source {
+	case TimeSourceStart, TimeSourceEnd, TimeSourceExport, TimeSourceReceive:
+		return true
+	}
+	return false
+}
+
+// KafkaTopicList returns the topics to subscribe to
+func KafkaTopicList() []string {
+	topics := make([]string, 0, len(KafkaTopics))
+	for topic := range KafkaTopics {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	return topics
+}
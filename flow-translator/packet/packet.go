@@ -0,0 +1,236 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    packet.go
+ * details: Decodes the Ethernet, IPv4/IPv6 and TCP/UDP/ICMP headers of a
+ *          packet, the JSON encoding is the same as used by vFlow for the
+ *          sFlow raw packet headers
+ *
+ */
+package packet
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+)
+
+const (
+	EtherTypeIPv4 = 0x0800
+	EtherTypeIPv6 = 0x86dd
+	EtherTypeVLAN = 0x8100
+	EtherTypeQinQ = 0x88a8
+
+	ProtoICMP   = 1
+	ProtoTCP    = 6
+	ProtoUDP    = 17
+	ProtoICMPv6 = 58
+
+	ethernetHeaderLen = 14
+	vlanTagLen        = 4
+	ipv4MinHeaderLen  = 20
+	ipv6HeaderLen     = 40
+	tcpMinHeaderLen   = 20
+	udpHeaderLen      = 8
+	icmpMinHeaderLen  = 4
+)
+
+// Datalink represents the Ethernet header
+type Datalink struct {
+	SrcMAC    string `json:"SrcMAC"`
+	DstMAC    string `json:"DstMAC"`
+	Vlan      uint16 `json:"Vlan"`
+	EtherType uint16 `json:"EtherType"`
+}
+
+// IPv4Header represents the IPv4 header
+type IPv4Header struct {
+	Version  uint8  `json:"Version"`
+	TOS      uint8  `json:"TOS"`
+	TotalLen uint16 `json:"TotalLen"`
+	ID       uint16 `json:"ID"`
+	Flags    uint8  `json:"Flags"`
+	FragOff  uint16 `json:"FragOff"`
+	TTL      uint8  `json:"TTL"`
+	Protocol uint8  `json:"Protocol"`
+	Checksum uint16 `json:"Checksum"`
+	Src      string `json:"Src"`
+	Dst      string `json:"Dst"`
+}
+
+// IPv6Header represents the IPv6 header
+type IPv6Header struct {
+	Version      uint8  `json:"Version"`
+	TrafficClass uint8  `json:"TrafficClass"`
+	FlowLabel    uint32 `json:"FlowLabel"`
+	PayloadLen   uint16 `json:"PayloadLen"`
+	NextHeader   uint8  `json:"NextHeader"`
+	HopLimit     uint8  `json:"HopLimit"`
+	Src          string `json:"Src"`
+	Dst          string `json:"Dst"`
+}
+
+// TCPHeader represents the TCP header
+type TCPHeader struct {
+	SrcPort    uint16 `json:"SrcPort"`
+	DstPort    uint16 `json:"DstPort"`
+	DataOffset uint8  `json:"DataOffset"`
+	Reserved   uint8  `json:"Reserved"`
+	Flags      uint8  `json:"Flags"`
+}
+
+// UDPHeader represents the UDP header
+type UDPHeader struct {
+	SrcPort  uint16 `json:"SrcPort"`
+	DstPort  uint16 `json:"DstPort"`
+	Length   uint16 `json:"Length"`
+	Checksum uint16 `json:"Checksum"`
+}
+
+// ICMPHeader represents the ICMP and ICMPv6 header
+type ICMPHeader struct {
+	Type uint8 `json:"Type"`
+	Code uint8 `json:"Code"`
+}
+
+// Packet represents a decoded packet, L3 and L4 are nil when the packet is
+// truncated or the protocol is not supported
+type Packet struct {
+	L2 Datalink    `json:"L2"`
+	L3 interface{} `json:"L3"`
+	L4 interface{} `json:"L4"`
+
+	// Payload is the data following the L4 header
+	Payload []byte `json:"-"`
+}
+
+// DecodeEthernet decodes a packet starting with the Ethernet header
+func DecodeEthernet(b []byte) (*Packet, error) {
+	if len(b) < ethernetHeaderLen {
+		return nil, fmt.Errorf("Invalid ethernet header length %d", len(b))
+	}
+	p := &Packet{
+		L2: Datalink{
+			DstMAC:    net.HardwareAddr(b[0:6]).String(),
+			SrcMAC:    net.HardwareAddr(b[6:12]).String(),
+			EtherType: binary.BigEndian.Uint16(b[12:14]),
+		},
+	}
+	b = b[ethernetHeaderLen:]
+	for p.L2.EtherType == EtherTypeVLAN || p.L2.EtherType == EtherTypeQinQ {
+		if len(b) < vlanTagLen {
+			return p, nil
+		}
+		p.L2.Vlan = binary.BigEndian.Uint16(b[0:2]) & 0x0fff
+		p.L2.EtherType = binary.BigEndian.Uint16(b[2:4])
+		b = b[vlanTagLen:]
+	}
+	return p, p.decodeL3(p.L2.EtherType, b)
+}
+
+// DecodeIP decodes a packet starting with the IPv4 or IPv6 header
+func DecodeIP(b []byte) (*Packet, error) {
+	p := &Packet{}
+	if len(b) == 0 {
+		return p, nil
+	}
+	switch b[0] >> 4 {
+	case 4:
+		return p, p.decodeL3(EtherTypeIPv4, b)
+	case 6:
+		return p, p.decodeL3(EtherTypeIPv6, b)
+	}
+	return nil, fmt.Errorf("Invalid IP version %d", b[0]>>4)
+}
+
+func (p *Packet) decodeL3(etherType uint16, b []byte) error {
+	var proto uint8
+	switch etherType {
+	case EtherTypeIPv4:
+		if len(b) < ipv4MinHeaderLen {
+			return nil
+		}
+		hdrLen := int(b[0]&0x0f) * 4
+		if hdrLen < ipv4MinHeaderLen {
+			return fmt.Errorf("Invalid IPv4 header length %d", hdrLen)
+		}
+		flagsFrag := binary.BigEndian.Uint16(b[6:8])
+		ip := &IPv4Header{
+			Version:  b[0] >> 4,
+			TOS:      b[1],
+			TotalLen: binary.BigEndian.Uint16(b[2:4]),
+			ID:       binary.BigEndian.Uint16(b[4:6]),
+			Flags:    uint8(flagsFrag >> 13),
+			FragOff:  flagsFrag & 0x1fff,
+			TTL:      b[8],
+			Protocol: b[9],
+			Checksum: binary.BigEndian.Uint16(b[10:12]),
+			Src:      net.IP(b[12:16]).String(),
+			Dst:      net.IP(b[16:20]).String(),
+		}
+		p.L3 = ip
+		proto = ip.Protocol
+		if len(b) < hdrLen || ip.FragOff != 0 {
+			return nil
+		}
+		b = b[hdrLen:]
+	case EtherTypeIPv6:
+		if len(b) < ipv6HeaderLen {
+			return nil
+		}
+		vtf := binary.BigEndian.Uint32(b[0:4])
+		ip := &IPv6Header{
+			Version:      uint8(vtf >> 28),
+			TrafficClass: uint8(vtf >> 20),
+			FlowLabel:    vtf & 0x000fffff,
+			PayloadLen:   binary.BigEndian.Uint16(b[4:6]),
+			NextHeader:   b[6],
+			HopLimit:     b[7],
+			Src:          net.IP(b[8:24]).String(),
+			Dst:          net.IP(b[24:40]).String(),
+		}
+		p.L3 = ip
+		proto = ip.NextHeader
+		b = b[ipv6HeaderLen:]
+	default:
+		return nil
+	}
+	p.decodeL4(proto, b)
+	return nil
+}
+
+func (p *Packet) decodeL4(proto uint8, b []byte) {
+	switch proto {
+	case ProtoTCP:
+		if len(b) < tcpMinHeaderLen {
+			return
+		}
+		tcp := &TCPHeader{
+			SrcPort:    binary.BigEndian.Uint16(b[0:2]),
+			DstPort:    binary.BigEndian.Uint16(b[2:4]),
+			DataOffset: b[12] >> 4,
+			Reserved:   b[12] & 0x0f,
+			Flags:      b[13],
+		}
+		p.L4 = tcp
+		if hdrLen := int(tcp.DataOffset) * 4; hdrLen <= len(b) {
+			p.Payload = b[hdrLen:]
+		}
+	case ProtoUDP:
+		if len(b) < udpHeaderLen {
+			return
+		}
+		p.L4 = &UDPHeader{
+			SrcPort:  binary.BigEndian.Uint16(b[0:2]),
+			DstPort:  binary.BigEndian.Uint16(b[2:4]),
+			Length:   binary.BigEndian.Uint16(b[4:6]),
+			Checksum: binary.BigEndian.Uint16(b[6:8]),
+		}
+		p.Payload = b[udpHeaderLen:]
+	case ProtoICMP, ProtoICMPv6:
+		if len(b) < icmpMinHeaderLen {
+			return
+		}
+		p.L4 = &ICMPHeader{Type: b[0], Code: b[1]}
+	}
+}
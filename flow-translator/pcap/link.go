@@ -0,0 +1,85 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    link.go
+ * details: Decodes the captured packets as per the link type of the
+ *          capture interface
+ *
+ */
+package pcap
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/Juniper/collector/flow-translator/packet"
+)
+
+const (
+	LinkTypeNull     = 0
+	LinkTypeEthernet = 1
+	LinkTypeRaw      = 101
+	LinkTypeLinuxSLL = 113
+	LinkTypeIPv4     = 228
+	LinkTypeIPv6     = 229
+
+	// DLT_RAW as found in the pcap files of some platforms
+	linkTypeRawAlt = 12
+
+	nullHeaderLen     = 4
+	linuxSLLHeaderLen = 16
+)
+
+// Decode decodes the packet headers, the link layer header is skipped for
+// the link types other than Ethernet
+func (p *Packet) Decode() (*packet.Packet, error) {
+	switch p.LinkType {
+	case LinkTypeEthernet:
+		return packet.DecodeEthernet(p.Data)
+	case LinkTypeRaw, linkTypeRawAlt, LinkTypeIPv4, LinkTypeIPv6:
+		return packet.DecodeIP(p.Data)
+	case LinkTypeNull:
+		if len(p.Data) < nullHeaderLen {
+			return nil, fmt.Errorf("Invalid null header length %d", len(p.Data))
+		}
+		return packet.DecodeIP(p.Data[nullHeaderLen:])
+	case LinkTypeLinuxSLL:
+		if len(p.Data) < linuxSLLHeaderLen {
+			return nil, fmt.Errorf("Invalid Linux SLL header length %d", len(p.Data))
+		}
+		switch binary.BigEndian.Uint16(p.Data[14:16]) {
+		case packet.EtherTypeIPv4, packet.EtherTypeIPv6:
+			return packet.DecodeIP(p.Data[linuxSLLHeaderLen:])
+		}
+		return &packet.Packet{}, nil
+	}
+	return nil, fmt.Errorf("Unsupported link type %d", p.LinkType)
+}
+
+// UDPPayload returns the source address, the destination port and the
+// payload of a UDP datagram, ok is false when the packet is not a complete
+// UDP datagram, i.e. truncated by the capture or an IP fragment
+func UDPPayload(p *packet.Packet) (src string, port uint16, payload []byte, ok bool) {
+	udp, isUDP := p.L4.(*packet.UDPHeader)
+	if !isUDP {
+		return "", 0, nil, false
+	}
+	switch ip := p.L3.(type) {
+	case *packet.IPv4Header:
+		// more fragments
+		if ip.Flags&0x1 != 0 {
+			return "", 0, nil, false
+		}
+		src = ip.Src
+	case *packet.IPv6Header:
+		src = ip.Src
+	default:
+		return "", 0, nil, false
+	}
+	n := int(udp.Length) - 8
+	if n < 0 || len(p.Payload) < n {
+		return "", 0, nil, false
+	}
+	// the Ethernet padding of short frames is dropped
+	return src, udp.DstPort, p.Payload[:n], true
+}
@@ -0,0 +1,277 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    reader.go
+ * details: Reads the packets of pcap and pcapng files, without libpcap
+ *
+ */
+package pcap
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+)
+
+const (
+	magicMicroseconds = 0xa1b2c3d4
+	magicNanoseconds  = 0xa1b23c4d
+
+	blockSectionHeader       = 0x0a0d0d0a
+	blockInterfaceDesc       = 0x00000001
+	blockSimplePacket        = 0x00000003
+	blockEnhancedPacket      = 0x00000006
+	byteOrderMagic           = 0x1a2b3c4d
+	optionEndOfOpt           = 0
+	optionInterfaceTSResol   = 9
+	pcapGlobalHeaderLen      = 24
+	pcapRecordHeaderLen      = 16
+	pcapngBlockHeaderLen     = 8
+	maxPcapngBlockLen        = 16 * 1024 * 1024
+	defaultTimestampsPerSecs = 1000000
+)
+
+// Packet is a captured packet along with the link type of its interface
+type Packet struct {
+	Timestamp time.Time
+	LinkType  uint32
+	Data      []byte
+}
+
+// pcapng interface, the packets of a section refer to them by index
+type iface struct {
+	linkType uint32
+	snapLen  uint32
+	// tsPerSec is the number of timestamp units per second
+	tsPerSec uint64
+}
+
+// Reader reads the packets of a pcap or a pcapng file
+type Reader struct {
+	r     io.Reader
+	order binary.ByteOrder
+	ng    bool
+
+	// pcap
+	linkType uint32
+	nanos    bool
+
+	// pcapng
+	ifaces []iface
+}
+
+// NewReader returns a reader of the pcap or pcapng file, the format and
+// the byte order are detected from the file header
+func NewReader(r io.Reader) (*Reader, error) {
+	pr := &Reader{r: r}
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return nil, fmt.Errorf("Invalid pcap file: %v", err)
+	}
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		switch order.Uint32(magic) {
+		case magicMicroseconds, magicNanoseconds:
+			pr.order = order
+			pr.nanos = order.Uint32(magic) == magicNanoseconds
+			return pr, pr.readGlobalHeader()
+		case blockSectionHeader:
+			pr.ng = true
+			length := make([]byte, 4)
+			if _, err := io.ReadFull(r, length); err != nil {
+				return nil, fmt.Errorf("Invalid pcapng section header: %v", err)
+			}
+			return pr, pr.readSectionHeader(length)
+		}
+	}
+	return nil, fmt.Errorf("Invalid pcap file magic 0x%x", magic)
+}
+
+func (pr *Reader) readGlobalHeader() error {
+	b := make([]byte, pcapGlobalHeaderLen-4)
+	if _, err := io.ReadFull(pr.r, b); err != nil {
+		return fmt.Errorf("Invalid pcap header: %v", err)
+	}
+	pr.linkType = pr.order.Uint32(b[16:20])
+	return nil
+}
+
+// readSectionHeader reads the section header block once its type and
+// length are read, the byte order of the section and thus of its length is
+// found in it
+func (pr *Reader) readSectionHeader(rawLength []byte) error {
+	b := make([]byte, 4)
+	if _, err := io.ReadFull(pr.r, b); err != nil {
+		return fmt.Errorf("Invalid pcapng section header: %v", err)
+	}
+	switch uint32(byteOrderMagic) {
+	case binary.LittleEndian.Uint32(b):
+		pr.order = binary.LittleEndian
+	case binary.BigEndian.Uint32(b):
+		pr.order = binary.BigEndian
+	default:
+		return fmt.Errorf("Invalid pcapng byte order magic 0x%x", b)
+	}
+	length := pr.order.Uint32(rawLength)
+	if length < 28 || length > maxPcapngBlockLen || length%4 != 0 {
+		return fmt.Errorf("Invalid pcapng section header length %d", length)
+	}
+	// version, section length, options and the trailing length are skipped
+	if _, err := io.CopyN(ioutil.Discard, pr.r, int64(length)-12); err != nil {
+		return fmt.Errorf("Invalid pcapng section header: %v", err)
+	}
+	pr.ifaces = nil
+	return nil
+}
+
+// Next returns the next packet, io.EOF at the end of the file
+func (pr *Reader) Next() (*Packet, error) {
+	if pr.ng {
+		return pr.nextBlock()
+	}
+	hdr := make([]byte, pcapRecordHeaderLen)
+	if _, err := io.ReadFull(pr.r, hdr); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, fmt.Errorf("Invalid pcap record header, truncated")
+		}
+		return nil, err
+	}
+	sec := int64(pr.order.Uint32(hdr[0:4]))
+	frac := int64(pr.order.Uint32(hdr[4:8]))
+	capLen := pr.order.Uint32(hdr[8:12])
+	if capLen > maxPcapngBlockLen {
+		return nil, fmt.Errorf("Invalid pcap record length %d", capLen)
+	}
+	data := make([]byte, capLen)
+	if _, err := io.ReadFull(pr.r, data); err != nil {
+		return nil, fmt.Errorf("Invalid pcap record, truncated")
+	}
+	if !pr.nanos {
+		frac *= 1000
+	}
+	return &Packet{
+		Timestamp: time.Unix(sec, frac),
+		LinkType:  pr.linkType,
+		Data:      data,
+	}, nil
+}
+
+func (pr *Reader) nextBlock() (*Packet, error) {
+	for {
+		hdr := make([]byte, pcapngBlockHeaderLen)
+		if _, err := io.ReadFull(pr.r, hdr); err != nil {
+			if err == io.ErrUnexpectedEOF {
+				return nil, fmt.Errorf("Invalid pcapng block header, truncated")
+			}
+			return nil, err
+		}
+		blockType := pr.order.Uint32(hdr[0:4])
+		if blockType == blockSectionHeader {
+			// a new section, possibly with another byte order
+			if err := pr.readSectionHeader(hdr[4:8]); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		length := pr.order.Uint32(hdr[4:8])
+		if length < 12 || length > maxPcapngBlockLen || length%4 != 0 {
+			return nil, fmt.Errorf("Invalid pcapng block length %d", length)
+		}
+		body := make([]byte, length-pcapngBlockHeaderLen)
+		if _, err := io.ReadFull(pr.r, body); err != nil {
+			return nil, fmt.Errorf("Invalid pcapng block, truncated")
+		}
+		// the trailing block length
+		body = body[:len(body)-4]
+
+		var (
+			p   *Packet
+			err error
+		)
+		switch blockType {
+		case blockInterfaceDesc:
+			err = pr.readInterface(body)
+		case blockEnhancedPacket:
+			p, err = pr.readEnhancedPacket(body)
+		case blockSimplePacket:
+			p, err = pr.readSimplePacket(body)
+		}
+		if err != nil || p != nil {
+			return p, err
+		}
+	}
+}
+
+func (pr *Reader) readInterface(b []byte) error {
+	if len(b) < 8 {
+		return fmt.Errorf("Invalid pcapng interface description block")
+	}
+	ifc := iface{
+		linkType: uint32(pr.order.Uint16(b[0:2])),
+		snapLen:  pr.order.Uint32(b[4:8]),
+		tsPerSec: defaultTimestampsPerSecs,
+	}
+	opts := b[8:]
+	for len(opts) >= 4 {
+		code := pr.order.Uint16(opts[0:2])
+		l := int(pr.order.Uint16(opts[2:4]))
+		if code == optionEndOfOpt || len(opts) < 4+l {
+			break
+		}
+		if code == optionInterfaceTSResol && l >= 1 {
+			resol := opts[4]
+			ifc.tsPerSec = 1
+			for i := 0; i < int(resol&0x7f); i++ {
+				if resol&0x80 != 0 {
+					ifc.tsPerSec *= 2
+				} else {
+					ifc.tsPerSec *= 10
+				}
+			}
+		}
+		opts = opts[4+(l+3)/4*4:]
+	}
+	pr.ifaces = append(pr.ifaces, ifc)
+	return nil
+}
+
+func (pr *Reader) readEnhancedPacket(b []byte) (*Packet, error) {
+	if len(b) < 20 {
+		return nil, fmt.Errorf("Invalid pcapng enhanced packet block")
+	}
+	id := pr.order.Uint32(b[0:4])
+	if int(id) >= len(pr.ifaces) {
+		return nil, fmt.Errorf("Invalid pcapng interface id %d", id)
+	}
+	ifc := pr.ifaces[id]
+	ts := uint64(pr.order.Uint32(b[4:8]))<<32 | uint64(pr.order.Uint32(b[8:12]))
+	capLen := int(pr.order.Uint32(b[12:16]))
+	if len(b) < 20+capLen {
+		return nil, fmt.Errorf("Invalid pcapng packet length %d", capLen)
+	}
+	return &Packet{
+		Timestamp: time.Unix(int64(ts/ifc.tsPerSec),
+			int64(ts%ifc.tsPerSec*uint64(time.Second)/ifc.tsPerSec)),
+		LinkType: ifc.linkType,
+		Data:     b[20 : 20+capLen],
+	}, nil
+}
+
+func (pr *Reader) readSimplePacket(b []byte) (*Packet, error) {
+	if len(pr.ifaces) == 0 || len(b) < 4 {
+		return nil, fmt.Errorf("Invalid pcapng simple packet block")
+	}
+	ifc := pr.ifaces[0]
+	capLen := int(pr.order.Uint32(b[0:4]))
+	if ifc.snapLen > 0 && capLen > int(ifc.snapLen) {
+		capLen = int(ifc.snapLen)
+	}
+	if len(b) < 4+capLen {
+		capLen = len(b) - 4
+	}
+	return &Packet{
+		LinkType: ifc.linkType,
+		Data:     b[4 : 4+capLen],
+	}, nil
+}
@@ -0,0 +1,176 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    reader_test.go
+ * details: Deals with the Unit Test cases for the pcap and pcapng reader
+ *
+ */
+package pcap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+	"time"
+)
+
+var testPayload = []byte{0x00, 0x0a, 0x00, 0x10}
+
+// testFrame returns an Ethernet frame with a UDP datagram from 10.1.1.1
+// to port 4739, padded as a short frame
+func testFrame() []byte {
+	udpLen := 8 + len(testPayload)
+	b := []byte{
+		0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 0x08, 0x00,
+		0x45, 0, 0, byte(20 + udpLen), 0, 1, 0, 0, 64, 17, 0, 0,
+		10, 1, 1, 1, 10, 2, 2, 2,
+		0x30, 0x39, 0x12, 0x83, 0, byte(udpLen), 0, 0,
+	}
+	b = append(b, testPayload...)
+	return append(b, make([]byte, 60-len(b))...)
+}
+
+func pcapFile(order binary.ByteOrder, magic uint32, frac uint32) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, order, []uint32{magic, 0x00040002, 0, 0, 65535,
+		LinkTypeEthernet})
+	frame := testFrame()
+	binary.Write(&buf, order, []uint32{1500000000, frac,
+		uint32(len(frame)), uint32(len(frame))})
+	buf.Write(frame)
+	return buf.Bytes()
+}
+
+func pcapngBlock(order binary.ByteOrder, blockType uint32, body []byte) []byte {
+	for len(body)%4 != 0 {
+		body = append(body, 0)
+	}
+	var buf bytes.Buffer
+	binary.Write(&buf, order, []uint32{blockType, uint32(len(body) + 12)})
+	buf.Write(body)
+	binary.Write(&buf, order, uint32(len(body)+12))
+	return buf.Bytes()
+}
+
+func pcapngFile(order binary.ByteOrder) []byte {
+	var shb, idb, epb bytes.Buffer
+	binary.Write(&shb, order, []uint32{byteOrderMagic, 0x00010000})
+	binary.Write(&shb, order, int64(-1))
+
+	// nanosecond timestamps
+	binary.Write(&idb, order, []uint16{LinkTypeEthernet, 0})
+	binary.Write(&idb, order, uint32(65535))
+	binary.Write(&idb, order, []uint16{optionInterfaceTSResol, 1})
+	idb.Write([]byte{9, 0, 0, 0})
+	binary.Write(&idb, order, []uint16{optionEndOfOpt, 0})
+
+	frame := testFrame()
+	ts := uint64(1500000000*time.Second + 500*time.Millisecond)
+	binary.Write(&epb, order, []uint32{0, uint32(ts >> 32), uint32(ts),
+		uint32(len(frame)), uint32(len(frame))})
+	epb.Write(frame)
+
+	var buf bytes.Buffer
+	buf.Write(pcapngBlock(order, blockSectionHeader, shb.Bytes()))
+	buf.Write(pcapngBlock(order, blockInterfaceDesc, idb.Bytes()))
+	// unknown blocks are skipped
+	buf.Write(pcapngBlock(order, 0x00000005, []byte{1, 2, 3, 4}))
+	buf.Write(pcapngBlock(order, blockEnhancedPacket, epb.Bytes()))
+	return buf.Bytes()
+}
+
+func TestReader(t *testing.T) {
+	half := time.Unix(1500000000, 500000000)
+	tests := []struct {
+		name string
+		file []byte
+	}{
+		{"pcap-le-us", pcapFile(binary.LittleEndian, magicMicroseconds, 500000)},
+		{"pcap-be-us", pcapFile(binary.BigEndian, magicMicroseconds, 500000)},
+		{"pcap-le-ns", pcapFile(binary.LittleEndian, magicNanoseconds, 500000000)},
+		{"pcapng-le", pcapngFile(binary.LittleEndian)},
+		{"pcapng-be", pcapngFile(binary.BigEndian)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r, err := NewReader(bytes.NewReader(test.file))
+			if err != nil {
+				t.Fatalf("NewReader failed: %v", err)
+			}
+			p, err := r.Next()
+			if err != nil {
+				t.Fatalf("Next failed: %v", err)
+			}
+			if !p.Timestamp.Equal(half) {
+				t.Errorf("expected timestamp %v, got %v", half, p.Timestamp)
+			}
+			if p.LinkType != LinkTypeEthernet {
+				t.Errorf("expected link type %d, got %d", LinkTypeEthernet, p.LinkType)
+			}
+			pkt, err := p.Decode()
+			if err != nil {
+				t.Fatalf("Decode failed: %v", err)
+			}
+			src, port, payload, ok := UDPPayload(pkt)
+			if !ok || src != "10.1.1.1" || port != 4739 ||
+				!bytes.Equal(payload, testPayload) {
+				t.Errorf("unexpected datagram %v %s %d %x", ok, src, port, payload)
+			}
+			if _, err := r.Next(); err != io.EOF {
+				t.Errorf("expected EOF, got %v", err)
+			}
+		})
+	}
+}
+
+func TestReaderSections(t *testing.T) {
+	// a section of each byte order, the interfaces are per section
+	file := append(pcapngFile(binary.LittleEndian), pcapngFile(binary.BigEndian)...)
+	r, err := NewReader(bytes.NewReader(file))
+	if err != nil {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		p, err := r.Next()
+		if err != nil {
+			t.Fatalf("Next of section %d failed: %v", i, err)
+		}
+		if half := time.Unix(1500000000, 500000000); !p.Timestamp.Equal(half) {
+			t.Errorf("section %d expected timestamp %v, got %v", i, half, p.Timestamp)
+		}
+	}
+	if r.order != binary.BigEndian {
+		t.Errorf("expected the byte order of the second section")
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Errorf("expected EOF, got %v", err)
+	}
+}
+
+func TestReaderInvalid(t *testing.T) {
+	if _, err := NewReader(bytes.NewReader([]byte{1, 2, 3, 4})); err == nil {
+		t.Errorf("expected an error for an invalid magic")
+	}
+	file := pcapFile(binary.LittleEndian, magicMicroseconds, 0)
+	r, err := NewReader(bytes.NewReader(file[:len(file)-10]))
+	if err != nil {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	if _, err := r.Next(); err == nil || err == io.EOF {
+		t.Errorf("expected an error for a truncated record, got %v", err)
+	}
+}
+
+func TestUDPPayloadFragment(t *testing.T) {
+	frame := testFrame()
+	// more fragments flag
+	frame[20] = 0x20
+	pkt, err := (&Packet{LinkType: LinkTypeEthernet, Data: frame}).Decode()
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if _, _, _, ok := UDPPayload(pkt); ok {
+		t.Errorf("expected a fragment to be skipped")
+	}
+}
@@ -0,0 +1,176 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    mrt.go
+ * details: Reads the origin AS of the prefixes of the BGP RIB dumps in the
+ *          MRT TABLE_DUMP_V2 format (RFC 6396)
+ *
+ */
+package prefixasn
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+const (
+	mrtHeaderLen   = 12
+	mrtTableDumpV2 = 13
+
+	// TABLE_DUMP_V2 subtypes, the ADD-PATH ones (RFC 8050) have a path
+	// identifier in their RIB entries
+	ribIPv4Unicast        = 2
+	ribIPv6Unicast        = 4
+	ribIPv4UnicastAddPath = 8
+	ribIPv6UnicastAddPath = 10
+
+	attrASPath            = 2
+	attrFlagExtendedLen   = 0x10
+	asPathSegmentSet      = 1
+	asPathSegmentSequence = 2
+)
+
+// isMRT tells whether the beginning of a file is a MRT record header, the
+// type of the MRT records fits in a byte where the text files have no zero
+func isMRT(header []byte) bool {
+	return len(header) >= mrtHeaderLen && header[4] == 0
+}
+
+// loadMRT inserts the prefixes of the RIB records of a TABLE_DUMP_V2 file,
+// the origin AS of a prefix is the one of its first RIB entry with an
+// AS_PATH
+func (t *Table) loadMRT(r *bufio.Reader) error {
+	header := make([]byte, mrtHeaderLen)
+	var body []byte
+	dumps := 0
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return fmt.Errorf("MRT header read error: %v", err)
+		}
+		typ := binary.BigEndian.Uint16(header[4:6])
+		subtype := binary.BigEndian.Uint16(header[6:8])
+		length := int(binary.BigEndian.Uint32(header[8:12]))
+		if cap(body) < length {
+			body = make([]byte, length)
+		}
+		body = body[:length]
+		if _, err := io.ReadFull(r, body); err != nil {
+			return fmt.Errorf("MRT record read error: %v", err)
+		}
+		if typ != mrtTableDumpV2 {
+			continue
+		}
+		dumps++
+		var addrLen int
+		switch subtype {
+		case ribIPv4Unicast, ribIPv4UnicastAddPath:
+			addrLen = 4
+		case ribIPv6Unicast, ribIPv6UnicastAddPath:
+			addrLen = 16
+		default:
+			continue
+		}
+		addPath := subtype == ribIPv4UnicastAddPath ||
+			subtype == ribIPv6UnicastAddPath
+		if err := t.insertRIB(body, addrLen, addPath); err != nil {
+			return err
+		}
+	}
+	if dumps == 0 {
+		return fmt.Errorf("Not a MRT TABLE_DUMP_V2 file")
+	}
+	return nil
+}
+
+// insertRIB inserts the prefix of a RIB record
+func (t *Table) insertRIB(b []byte, addrLen int, addPath bool) error {
+	errInvalid := fmt.Errorf("Invalid MRT RIB record")
+	// sequence number, prefix length
+	if len(b) < 5 {
+		return errInvalid
+	}
+	bits := b[4]
+	n := (int(bits) + 7) / 8
+	if int(bits) > addrLen*8 || len(b) < 5+n+2 {
+		return errInvalid
+	}
+	var key [16]byte
+	copy(key[:], b[5:5+n])
+	count := int(binary.BigEndian.Uint16(b[5+n:]))
+	b = b[5+n+2:]
+	for i := 0; i < count; i++ {
+		// peer index, originated time, path identifier
+		skip := 6
+		if addPath {
+			skip += 4
+		}
+		if len(b) < skip+2 {
+			return errInvalid
+		}
+		attrLen := int(binary.BigEndian.Uint16(b[skip:]))
+		if len(b) < skip+2+attrLen {
+			return errInvalid
+		}
+		asn, ok, err := originAS(b[skip+2 : skip+2+attrLen])
+		if err != nil {
+			return err
+		}
+		if ok {
+			t.insert(key, addrLen, bits, asn)
+			return nil
+		}
+		b = b[skip+2+attrLen:]
+	}
+	return nil
+}
+
+// originAS returns the origin AS of the AS_PATH of the path attributes,
+// the last AS of the path, or the first AS of the set ending it
+func originAS(b []byte) (uint32, bool, error) {
+	errInvalid := fmt.Errorf("Invalid MRT path attributes")
+	for len(b) > 0 {
+		if len(b) < 3 {
+			return 0, false, errInvalid
+		}
+		flags, typ := b[0], b[1]
+		hdrLen, attrLen := 3, int(b[2])
+		if flags&attrFlagExtendedLen != 0 {
+			if len(b) < 4 {
+				return 0, false, errInvalid
+			}
+			hdrLen, attrLen = 4, int(binary.BigEndian.Uint16(b[2:4]))
+		}
+		if len(b) < hdrLen+attrLen {
+			return 0, false, errInvalid
+		}
+		if typ != attrASPath {
+			b = b[hdrLen+attrLen:]
+			continue
+		}
+		// the AS numbers are 4 bytes in TABLE_DUMP_V2
+		var asn uint32
+		found := false
+		for path := b[hdrLen : hdrLen+attrLen]; len(path) > 0; {
+			if len(path) < 2 || len(path) < 2+int(path[1])*4 {
+				return 0, false, errInvalid
+			}
+			segType, n := path[0], int(path[1])
+			if n > 0 {
+				switch segType {
+				case asPathSegmentSequence:
+					asn, found = binary.BigEndian.Uint32(path[2+(n-1)*4:]), true
+				case asPathSegmentSet:
+					asn, found = binary.BigEndian.Uint32(path[2:]), true
+				}
+			}
+			path = path[2+n*4:]
+		}
+		return asn, found, nil
+	}
+	return 0, false, nil
+}
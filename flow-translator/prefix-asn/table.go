@@ -0,0 +1,300 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    table.go
+ * details: Prefix to origin AS table, loaded from a MRT RIB dump or a CSV
+ *          file and reloaded when the files change on disk
+ *
+ */
+package prefixasn
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	filewatch "github.com/Juniper/collector/flow-translator/file-watch"
+	"github.com/Juniper/collector/flow-translator/metrics"
+	opts "github.com/Juniper/collector/flow-translator/options"
+)
+
+var (
+	tablePrefixes = metrics.NewGauge("flow_translator_prefix_asn_prefixes",
+		"Prefixes of the prefix to AS table, per address family", "family")
+	tableReloads = metrics.NewCounter("flow_translator_prefix_asn_reloads_total",
+		"Reloads of the prefix to AS table files, per result", "result")
+)
+
+// Route is the longest prefix holding an address, with its origin AS
+type Route struct {
+	Prefix string
+	ASN    uint32
+	Name   string
+}
+
+// Table holds the origin AS of the IPv4 and IPv6 prefixes, and the names
+// of the AS
+type Table struct {
+	v4    trie
+	v6    trie
+	names map[uint32]string
+}
+
+// NewTable returns an empty table
+func NewTable() *Table {
+	return &Table{
+		v4:    trie{addrLen: 4},
+		v6:    trie{addrLen: 16},
+		names: map[uint32]string{},
+	}
+}
+
+// Insert adds a prefix with its origin AS, or replaces its origin AS
+func (t *Table) Insert(prefix *net.IPNet, asn uint32) {
+	var key [16]byte
+	n, _ := prefix.Mask.Size()
+	if ip4 := prefix.IP.To4(); ip4 != nil && n <= 32 {
+		copy(key[:], ip4)
+		t.insert(key, 4, uint8(n), asn)
+		return
+	}
+	copy(key[:], prefix.IP.To16())
+	t.insert(key, 16, uint8(n), asn)
+}
+
+func (t *Table) insert(key [16]byte, addrLen int, n uint8, asn uint32) {
+	if addrLen == 4 {
+		t.v4.insert(key, n, asn)
+	} else {
+		t.v6.insert(key, n, asn)
+	}
+}
+
+// Lookup returns the longest prefix holding an address
+func (t *Table) Lookup(ip net.IP) (Route, bool) {
+	tr := &t.v4
+	var key [16]byte
+	if ip4 := ip.To4(); ip4 != nil {
+		copy(key[:], ip4)
+	} else if ip16 := ip.To16(); ip16 != nil {
+		copy(key[:], ip16)
+		tr = &t.v6
+	} else {
+		return Route{}, false
+	}
+	n := tr.lookup(&key)
+	if n == nil {
+		return Route{}, false
+	}
+	return Route{Prefix: tr.prefix(n), ASN: n.asn, Name: t.names[n.asn]}, true
+}
+
+// Len returns the number of IPv4 and IPv6 prefixes
+func (t *Table) Len() (int, int) {
+	return t.v4.size, t.v6.size
+}
+
+// Load reads the prefixes of a MRT TABLE_DUMP_V2 file or of a CSV file
+// with the prefix, origin AS and optionally AS name columns. The gzip and
+// bzip2 compressed files are decompressed
+func (t *Table) Load(r io.Reader) error {
+	br, err := decompress(r)
+	if err != nil {
+		return err
+	}
+	header, _ := br.Peek(mrtHeaderLen)
+	if isMRT(header) {
+		return t.loadMRT(br)
+	}
+	return readCSV(br, func(line int, fields []string) error {
+		_, prefix, err := net.ParseCIDR(fields[0])
+		if err != nil {
+			return fmt.Errorf("Invalid prefix '%s' on line %d", fields[0], line)
+		}
+		if len(fields) < 2 {
+			return fmt.Errorf("Missing AS number on line %d", line)
+		}
+		asn, err := parseASN(fields[1])
+		if err != nil {
+			return fmt.Errorf("Invalid AS number '%s' on line %d", fields[1], line)
+		}
+		t.Insert(prefix, asn)
+		if len(fields) > 2 && fields[2] != "" {
+			t.names[asn] = fields[2]
+		}
+		return nil
+	})
+}
+
+// LoadNames reads the names of the AS from a CSV file with the AS number
+// and name columns, they take precedence over the names of the prefixes
+func (t *Table) LoadNames(r io.Reader) error {
+	br, err := decompress(r)
+	if err != nil {
+		return err
+	}
+	return readCSV(br, func(line int, fields []string) error {
+		asn, err := parseASN(fields[0])
+		if err != nil {
+			return fmt.Errorf("Invalid AS number '%s' on line %d", fields[0], line)
+		}
+		if len(fields) < 2 {
+			return fmt.Errorf("Missing AS name on line %d", line)
+		}
+		t.names[asn] = fields[1]
+		return nil
+	})
+}
+
+// decompress returns a reader of the decompressed file if it is gzip or
+// bzip2 compressed, as per its magic number
+func decompress(r io.Reader) (*bufio.Reader, error) {
+	br := bufio.NewReaderSize(r, 1<<16)
+	magic, _ := br.Peek(3)
+	switch {
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		return bufio.NewReaderSize(zr, 1<<16), nil
+	case bytes.HasPrefix(magic, []byte("BZh")):
+		return bufio.NewReaderSize(bzip2.NewReader(br), 1<<16), nil
+	}
+	return br, nil
+}
+
+// readCSV calls parse for every line of a CSV file but the comments, the
+// first line is skipped as a header if it fails to parse
+func readCSV(r io.Reader, parse func(line int, fields []string) error) error {
+	cr := csv.NewReader(r)
+	cr.Comment = '#'
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	for line := 1; ; line++ {
+		fields, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := parse(line, fields); err != nil && line > 1 {
+			return err
+		}
+	}
+}
+
+// parseASN parses an AS number, with or without the AS prefix
+func parseASN(s string) (uint32, error) {
+	s = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(s)), "AS")
+	asn, err := strconv.ParseUint(s, 10, 32)
+	return uint32(asn), err
+}
+
+// Database is a table loaded from files
+type Database struct {
+	path      string
+	namesPath string
+	files     *filewatch.Files
+	mu        sync.RWMutex
+	table     *Table
+}
+
+// Open loads the prefixes of a file and the names of the AS of another,
+// namesPath is optional
+func Open(path string, namesPath string) (*Database, error) {
+	db := &Database{path: path, namesPath: namesPath}
+	if namesPath != "" {
+		db.files = filewatch.New(path, namesPath)
+	} else {
+		db.files = filewatch.New(path)
+	}
+	if _, err := db.reload(); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// Path returns the path of the prefixes file
+func (db *Database) Path() string {
+	return db.path
+}
+
+// Len returns the number of IPv4 and IPv6 prefixes
+func (db *Database) Len() (int, int) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return db.table.Len()
+}
+
+// Lookup returns the longest prefix holding an address
+func (db *Database) Lookup(ip net.IP) (Route, bool) {
+	db.mu.RLock()
+	t := db.table
+	db.mu.RUnlock()
+	return t.Lookup(ip)
+}
+
+// reload loads the files again if the modification time or size of any of
+// them changed since they were loaded, and returns whether it did
+func (db *Database) reload() (bool, error) {
+	return db.files.Reload(db.load)
+}
+
+func (db *Database) load() error {
+	t := NewTable()
+	if err := loadFile(db.path, t.Load); err != nil {
+		return err
+	}
+	if db.namesPath != "" {
+		if err := loadFile(db.namesPath, t.LoadNames); err != nil {
+			return err
+		}
+	}
+	db.mu.Lock()
+	db.table = t
+	db.mu.Unlock()
+	v4, v6 := t.Len()
+	tablePrefixes.Set(float64(v4), "ipv4")
+	tablePrefixes.Set(float64(v6), "ipv6")
+	return nil
+}
+
+func loadFile(path string, load func(io.Reader) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := load(f); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	return nil
+}
+
+// Watch checks the files every interval and reloads them when they changed
+func (db *Database) Watch(interval time.Duration) {
+	db.files.Watch(interval, db.load, func(err error) {
+		if err != nil {
+			tableReloads.Inc("error")
+			opts.Logger.Printf("Prefix to AS table %s reload error: %v",
+				db.path, err)
+			return
+		}
+		tableReloads.Inc("success")
+		v4, v6 := db.Len()
+		opts.Logger.Printf("Prefix to AS table %s reloaded, %d IPv4 and "+
+			"%d IPv6 prefixes", db.path, v4, v6)
+	})
+}
@@ -0,0 +1,226 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    table_test.go
+ * details: Deals with the Unit Test cases for the loading of the MRT and CSV
+ *          files and the reload of the prefix to AS table
+ *
+ */
+package prefixasn
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mrtRecord returns a MRT record of the given type, subtype and body
+func mrtRecord(typ uint16, subtype uint16, body []byte) []byte {
+	b := make([]byte, mrtHeaderLen)
+	binary.BigEndian.PutUint32(b, 1522035620)
+	binary.BigEndian.PutUint16(b[4:], typ)
+	binary.BigEndian.PutUint16(b[6:], subtype)
+	binary.BigEndian.PutUint32(b[8:], uint32(len(body)))
+	return append(b, body...)
+}
+
+// ribRecord returns the body of a RIB record of the prefix, with a RIB
+// entry per AS path, a path being AS_SEQUENCE then AS_SET segments
+func ribRecord(prefix string, addPath bool, paths ...[][]uint32) []byte {
+	_, ipnet, _ := net.ParseCIDR(prefix)
+	bits, _ := ipnet.Mask.Size()
+	ip := []byte(ipnet.IP.To4())
+	if ip == nil {
+		ip = ipnet.IP
+	}
+	b := []byte{0, 0, 0, 1, byte(bits)}
+	b = append(b, ip[:(bits+7)/8]...)
+	b = append(b, 0, byte(len(paths)))
+	for _, path := range paths {
+		// ORIGIN, then AS_PATH with an extended length
+		attrs := []byte{0x40, 1, 1, 0}
+		var segments []byte
+		for i, segment := range path {
+			segType := byte(asPathSegmentSequence)
+			if i > 0 {
+				segType = asPathSegmentSet
+			}
+			segments = append(segments, segType, byte(len(segment)))
+			for _, asn := range segment {
+				segments = append(segments, byte(asn>>24), byte(asn>>16),
+					byte(asn>>8), byte(asn))
+			}
+		}
+		if path != nil {
+			attrs = append(attrs, 0x50, attrASPath, 0, byte(len(segments)))
+			attrs = append(attrs, segments...)
+		}
+		b = append(b, 0, 1, 0x5a, 0xb9, 0x0f, 0xa4) // peer index, time
+		if addPath {
+			b = append(b, 0, 0, 0, 7)
+		}
+		b = append(b, 0, byte(len(attrs)))
+		b = append(b, attrs...)
+	}
+	return b
+}
+
+func testMRTDump() []byte {
+	var b []byte
+	// PEER_INDEX_TABLE, skipped
+	b = append(b, mrtRecord(mrtTableDumpV2, 1, []byte{10, 84, 30, 1, 0, 0, 0, 0})...)
+	// BGP4MP message, skipped
+	b = append(b, mrtRecord(16, 4, []byte{0, 1, 2, 3})...)
+	b = append(b, mrtRecord(mrtTableDumpV2, ribIPv4Unicast, ribRecord(
+		"10.84.0.0/16", false, [][]uint32{{64511, 64496}}))...)
+	// the first entry has no AS path, the origin is taken from the second
+	b = append(b, mrtRecord(mrtTableDumpV2, ribIPv4Unicast, ribRecord(
+		"10.84.30.0/24", false, nil, [][]uint32{{64511, 64510, 64497}},
+		[][]uint32{{64511, 64498}}))...)
+	// ending with an AS_SET
+	b = append(b, mrtRecord(mrtTableDumpV2, ribIPv4Unicast, ribRecord(
+		"192.0.2.0/24", false, [][]uint32{{64511}, {64499, 64500}}))...)
+	b = append(b, mrtRecord(mrtTableDumpV2, ribIPv6UnicastAddPath, ribRecord(
+		"2001:db8::/32", true, [][]uint32{{64511, 4200000000}}))...)
+	return b
+}
+
+func TestLoadMRT(t *testing.T) {
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write(testMRTDump())
+	zw.Close()
+	for name, dump := range map[string][]byte{"raw": testMRTDump(), "gzip": gz.Bytes()} {
+		table := NewTable()
+		if err := table.Load(bytes.NewReader(dump)); err != nil {
+			t.Fatalf("Load %s failed: %v", name, err)
+		}
+		if v4, v6 := table.Len(); v4 != 3 || v6 != 1 {
+			t.Errorf("Load %s expected 3 and 1 prefixes, got %d %d", name, v4, v6)
+		}
+		tests := []struct {
+			addr     string
+			expected Route
+		}{
+			{"10.84.29.30", Route{Prefix: "10.84.0.0/16", ASN: 64496}},
+			{"10.84.30.218", Route{Prefix: "10.84.30.0/24", ASN: 64497}},
+			{"192.0.2.1", Route{Prefix: "192.0.2.0/24", ASN: 64499}},
+			{"2001:db8::1", Route{Prefix: "2001:db8::/32", ASN: 4200000000}},
+		}
+		for _, tt := range tests {
+			if route, _ := table.Lookup(net.ParseIP(tt.addr)); route != tt.expected {
+				t.Errorf("Lookup %s %s expected %+v, got %+v", name, tt.addr,
+					tt.expected, route)
+			}
+		}
+	}
+
+	invalid := [][]byte{
+		mrtRecord(16, 4, []byte{0, 1, 2, 3}),
+		testMRTDump()[:len(testMRTDump())-3],
+		mrtRecord(mrtTableDumpV2, ribIPv4Unicast, []byte{0, 0, 0, 1, 33, 10}),
+	}
+	for i, dump := range invalid {
+		if err := NewTable().Load(bytes.NewReader(dump)); err == nil {
+			t.Errorf("Load of invalid dump %d expected an error", i)
+		}
+	}
+}
+
+const testPrefixes = `prefix,asn,name
+# documentation prefixes
+10.84.0.0/16,64496,Example
+10.84.30.0/24,AS64497,
+2001:db8::/32,64498,"Example, Inc."
+`
+
+func TestLoadCSV(t *testing.T) {
+	table := NewTable()
+	if err := table.Load(strings.NewReader(testPrefixes)); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if err := table.LoadNames(strings.NewReader("64497,Other\n")); err != nil {
+		t.Fatalf("LoadNames failed: %v", err)
+	}
+	tests := []struct {
+		addr     string
+		expected Route
+	}{
+		{"10.84.29.30", Route{"10.84.0.0/16", 64496, "Example"}},
+		{"10.84.30.218", Route{"10.84.30.0/24", 64497, "Other"}},
+		{"2001:db8::1", Route{"2001:db8::/32", 64498, "Example, Inc."}},
+	}
+	for _, tt := range tests {
+		if route, _ := table.Lookup(net.ParseIP(tt.addr)); route != tt.expected {
+			t.Errorf("Lookup %s expected %+v, got %+v", tt.addr, tt.expected, route)
+		}
+	}
+
+	for _, invalid := range []string{
+		"10.84.0.0/16,64496\n10.84.300.0/24,64497\n",
+		"10.84.0.0/16,64496\n10.84.30.0/24,private\n",
+		"10.84.0.0/16,64496\n10.84.30.0/24\n",
+	} {
+		if err := NewTable().Load(strings.NewReader(invalid)); err == nil {
+			t.Errorf("Load of '%s' expected an error", invalid)
+		}
+	}
+}
+
+func TestDatabaseReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "prefixasn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "rib.mrt")
+	names := filepath.Join(dir, "asn.csv")
+	ioutil.WriteFile(path, testMRTDump(), 0644)
+	ioutil.WriteFile(names, []byte("64496,Example\n"), 0644)
+	db, err := Open(path, names)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	expected := Route{"10.84.0.0/16", 64496, "Example"}
+	if route, _ := db.Lookup(net.ParseIP("10.84.29.30")); route != expected {
+		t.Errorf("Lookup expected %+v, got %+v", expected, route)
+	}
+	if reloaded, err := db.reload(); reloaded || err != nil {
+		t.Errorf("reload of unchanged files expected none, got %v %v", reloaded, err)
+	}
+
+	// an invalid file keeps the previous table
+	later := time.Now().Add(time.Minute)
+	ioutil.WriteFile(path, testMRTDump()[:100], 0644)
+	os.Chtimes(path, later, later)
+	if reloaded, err := db.reload(); reloaded || err == nil {
+		t.Errorf("reload of invalid file expected an error, got %v %v", reloaded, err)
+	}
+	if route, _ := db.Lookup(net.ParseIP("10.84.29.30")); route != expected {
+		t.Errorf("Lookup expected the previous table %+v, got %+v", expected, route)
+	}
+
+	// the fixed table and the changed names are loaded
+	ioutil.WriteFile(path, testMRTDump(), 0644)
+	ioutil.WriteFile(names, []byte("64496,Renamed\n"), 0644)
+	later = later.Add(time.Minute)
+	os.Chtimes(names, later, later)
+	if reloaded, err := db.reload(); !reloaded || err != nil {
+		t.Fatalf("reload of changed files failed: %v %v", reloaded, err)
+	}
+	expected.Name = "Renamed"
+	if route, _ := db.Lookup(net.ParseIP("10.84.29.30")); route != expected {
+		t.Errorf("Lookup after reload expected %+v, got %+v", expected, route)
+	}
+
+	if _, err := Open(filepath.Join(dir, "missing.mrt"), ""); err == nil {
+		t.Errorf("Open of missing file expected an error")
+	}
+}
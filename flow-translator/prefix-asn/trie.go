@@ -0,0 +1,133 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    trie.go
+ * details: Path compressed binary (radix) trie of the IPv4 and IPv6
+ *          prefixes, looked up by longest prefix match
+ *
+ */
+package prefixasn
+
+import (
+	"math/bits"
+	"net"
+)
+
+// node is a prefix of the trie, route tells the prefix was inserted and
+// is not only where two prefixes branch
+type node struct {
+	key   [16]byte
+	bits  uint8
+	route bool
+	asn   uint32
+	child [2]*node
+}
+
+// trie holds the prefixes of an address family, addrLen is the length of
+// its addresses in bytes
+type trie struct {
+	root    *node
+	addrLen int
+	size    int
+}
+
+// bitAt returns the bit i of the key
+func bitAt(key *[16]byte, i uint8) int {
+	return int(key[i/8]>>(7-i%8)) & 1
+}
+
+// commonBits returns how many leading bits of a and b are the same, up to
+// max
+func commonBits(a *[16]byte, b *[16]byte, max uint8) uint8 {
+	for i := uint8(0); i < max; i += 8 {
+		if x := a[i/8] ^ b[i/8]; x != 0 {
+			if n := i + uint8(bits.LeadingZeros8(x)); n < max {
+				return n
+			}
+			return max
+		}
+	}
+	return max
+}
+
+// maskKey returns the key with the bits after the first n cleared
+func maskKey(key [16]byte, n uint8) [16]byte {
+	if n >= 128 {
+		return key
+	}
+	key[n/8] &= 0xff << (8 - n%8)
+	for i := n/8 + 1; i < 16; i++ {
+		key[i] = 0
+	}
+	return key
+}
+
+// insert adds a prefix of n bits, or replaces its origin AS
+func (t *trie) insert(key [16]byte, n uint8, asn uint32) {
+	key = maskKey(key, n)
+	leaf := &node{key: key, bits: n, route: true, asn: asn}
+	p := &t.root
+	for {
+		cur := *p
+		if cur == nil {
+			*p = leaf
+			t.size++
+			return
+		}
+		max := cur.bits
+		if n < max {
+			max = n
+		}
+		common := commonBits(&cur.key, &key, max)
+		switch {
+		case common == cur.bits && common == n:
+			if !cur.route {
+				t.size++
+			}
+			cur.route, cur.asn = true, asn
+			return
+		case common == cur.bits:
+			// the prefix is within the node
+			p = &cur.child[bitAt(&key, cur.bits)]
+			continue
+		case common == n:
+			// the node is within the prefix
+			leaf.child[bitAt(&cur.key, n)] = cur
+			*p = leaf
+		default:
+			branch := &node{key: maskKey(key, common), bits: common}
+			branch.child[bitAt(&cur.key, common)] = cur
+			branch.child[bitAt(&key, common)] = leaf
+			*p = branch
+		}
+		t.size++
+		return
+	}
+}
+
+// lookup returns the longest prefix holding the address, nil if none
+func (t *trie) lookup(key *[16]byte) *node {
+	var best *node
+	for cur := t.root; cur != nil; {
+		if commonBits(&cur.key, key, cur.bits) < cur.bits {
+			break
+		}
+		if cur.route {
+			best = cur
+		}
+		if int(cur.bits) == t.addrLen*8 {
+			break
+		}
+		cur = cur.child[bitAt(key, cur.bits)]
+	}
+	return best
+}
+
+// prefix returns the prefix of a node in the CIDR notation
+func (t *trie) prefix(n *node) string {
+	ipnet := net.IPNet{
+		IP:   net.IP(append([]byte{}, n.key[:t.addrLen]...)),
+		Mask: net.CIDRMask(int(n.bits), t.addrLen*8),
+	}
+	return ipnet.String()
+}
@@ -0,0 +1,89 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    trie_test.go
+ * details: Deals with the Unit Test cases for the longest prefix match trie
+ *
+ */
+package prefixasn
+
+import (
+	"net"
+	"testing"
+)
+
+func TestTableLookup(t *testing.T) {
+	prefixes := []struct {
+		prefix string
+		asn    uint32
+	}{
+		{"10.84.0.0/16", 64496},
+		{"10.84.30.0/24", 64497},
+		{"10.84.30.160/32", 64498},
+		{"10.84.29.0/24", 64499},
+		{"10.0.0.0/8", 64500},
+		{"10.84.30.0/24", 64501}, // replaces 64497
+		{"192.0.2.0/25", 64502},
+		{"192.0.2.128/25", 64503},
+		{"0.0.0.0/0", 64504},
+		{"2001:db8::/32", 64505},
+		{"2001:db8:1::/48", 64506},
+		{"2001:db8:1::1/128", 64507},
+	}
+	table := NewTable()
+	for _, p := range prefixes {
+		_, prefix, _ := net.ParseCIDR(p.prefix)
+		table.Insert(prefix, p.asn)
+	}
+	if v4, v6 := table.Len(); v4 != 8 || v6 != 3 {
+		t.Errorf("Len expected 8 and 3 prefixes, got %d %d", v4, v6)
+	}
+	tests := []struct {
+		addr     string
+		expected Route
+		found    bool
+	}{
+		{"10.84.30.160", Route{Prefix: "10.84.30.160/32", ASN: 64498}, true},
+		{"10.84.30.161", Route{Prefix: "10.84.30.0/24", ASN: 64501}, true},
+		{"10.84.29.30", Route{Prefix: "10.84.29.0/24", ASN: 64499}, true},
+		{"10.84.31.1", Route{Prefix: "10.84.0.0/16", ASN: 64496}, true},
+		{"10.85.0.1", Route{Prefix: "10.0.0.0/8", ASN: 64500}, true},
+		{"192.0.2.1", Route{Prefix: "192.0.2.0/25", ASN: 64502}, true},
+		{"192.0.2.200", Route{Prefix: "192.0.2.128/25", ASN: 64503}, true},
+		{"8.8.8.8", Route{Prefix: "0.0.0.0/0", ASN: 64504}, true},
+		{"2001:db8:1::1", Route{Prefix: "2001:db8:1::1/128", ASN: 64507}, true},
+		{"2001:db8:1::2", Route{Prefix: "2001:db8:1::/48", ASN: 64506}, true},
+		{"2001:db8:2::1", Route{Prefix: "2001:db8::/32", ASN: 64505}, true},
+		{"2001:db9::1", Route{}, false},
+		{"::ffff:10.84.30.160", Route{Prefix: "10.84.30.160/32", ASN: 64498}, true},
+	}
+	for _, tt := range tests {
+		route, found := table.Lookup(net.ParseIP(tt.addr))
+		if route != tt.expected || found != tt.found {
+			t.Errorf("Lookup %s expected %+v %v, got %+v %v", tt.addr,
+				tt.expected, tt.found, route, found)
+		}
+	}
+	if _, found := table.Lookup(nil); found {
+		t.Errorf("Lookup of no address expected none")
+	}
+}
+
+func TestMaskKey(t *testing.T) {
+	key := [16]byte{0xff, 0xff, 0xff, 0xff, 0xff}
+	tests := []struct {
+		bits     uint8
+		expected [16]byte
+	}{
+		{0, [16]byte{}},
+		{12, [16]byte{0xff, 0xf0}},
+		{16, [16]byte{0xff, 0xff}},
+		{33, [16]byte{0xff, 0xff, 0xff, 0xff, 0x80}},
+		{128, key},
+	}
+	for _, tt := range tests {
+		if masked := maskKey(key, tt.bits); masked != tt.expected {
+			t.Errorf("maskKey %d expected %v, got %v", tt.bits, tt.expected, masked)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    counters.go
+ * details: sFlow v5 counter samples, the generic and ethernet interface
+ *          counter records
+ *
+ */
+package sflow
+
+// counter record formats, enterprise 0
+const (
+	genericIfCountersFormat  = 1
+	ethernetIfCountersFormat = 2
+)
+
+// CounterSample represents the counter sample and expanded counter sample
+type CounterSample struct {
+	SequenceNo       uint32            `json:"SequenceNo"`
+	SourceID         uint32            `json:"SourceID"`
+	IfCounters       *IfCounters       `json:"IfCounters,omitempty"`
+	EthernetCounters *EthernetCounters `json:"EthernetCounters,omitempty"`
+}
+
+// IfCounters represents the generic interface counters record
+type IfCounters struct {
+	IfIndex          uint32 `json:"IfIndex"`
+	IfType           uint32 `json:"IfType"`
+	IfSpeed          uint64 `json:"IfSpeed"`
+	IfDirection      uint32 `json:"IfDirection"`
+	IfStatus         uint32 `json:"IfStatus"`
+	InOctets         uint64 `json:"InOctets"`
+	InUcastPkts      uint32 `json:"InUcastPkts"`
+	InMulticastPkts  uint32 `json:"InMulticastPkts"`
+	InBroadcastPkts  uint32 `json:"InBroadcastPkts"`
+	InDiscards       uint32 `json:"InDiscards"`
+	InErrors         uint32 `json:"InErrors"`
+	InUnknownProtos  uint32 `json:"InUnknownProtos"`
+	OutOctets        uint64 `json:"OutOctets"`
+	OutUcastPkts     uint32 `json:"OutUcastPkts"`
+	OutMulticastPkts uint32 `json:"OutMulticastPkts"`
+	OutBroadcastPkts uint32 `json:"OutBroadcastPkts"`
+	OutDiscards      uint32 `json:"OutDiscards"`
+	OutErrors        uint32 `json:"OutErrors"`
+	PromiscuousMode  uint32 `json:"PromiscuousMode"`
+}
+
+// EthernetCounters represents the ethernet interface counters record
+type EthernetCounters struct {
+	AlignmentErrors           uint32 `json:"AlignmentErrors"`
+	FCSErrors                 uint32 `json:"FCSErrors"`
+	SingleCollisionFrames     uint32 `json:"SingleCollisionFrames"`
+	MultipleCollisionFrames   uint32 `json:"MultipleCollisionFrames"`
+	SQETestErrors             uint32 `json:"SQETestErrors"`
+	DeferredTransmissions     uint32 `json:"DeferredTransmissions"`
+	LateCollisions            uint32 `json:"LateCollisions"`
+	ExcessiveCollisions       uint32 `json:"ExcessiveCollisions"`
+	InternalMacTransmitErrors uint32 `json:"InternalMacTransmitErrors"`
+	CarrierSenseErrors        uint32 `json:"CarrierSenseErrors"`
+	FrameTooLongs             uint32 `json:"FrameTooLongs"`
+	InternalMacReceiveErrors  uint32 `json:"InternalMacReceiveErrors"`
+	SymbolErrors              uint32 `json:"SymbolErrors"`
+}
+
+func (r *reader) uint64() uint64 {
+	return uint64(r.uint32())<<32 | uint64(r.uint32())
+}
+
+// decodeCounterSample decodes the interface counter records of a counter
+// sample, nil is returned when it has none of them
+func decodeCounterSample(r *reader, expanded bool) (*Message, error) {
+	cs := &CounterSample{SequenceNo: r.uint32()}
+	if expanded {
+		srcType := r.uint32()
+		cs.SourceID = srcType<<24 | r.uint32()&0x00ffffff
+	} else {
+		cs.SourceID = r.uint32()
+	}
+	recordsNo := r.uint32()
+	if r.err != nil {
+		return nil, r.err
+	}
+	for i := uint32(0); i < recordsNo; i++ {
+		format := r.uint32()
+		rec := &reader{b: r.opaque(int(r.uint32()))}
+		if r.err != nil {
+			return nil, r.err
+		}
+		if format>>12 != 0 {
+			continue
+		}
+		switch format & 0xfff {
+		case genericIfCountersFormat:
+			cs.IfCounters = &IfCounters{
+				IfIndex:          rec.uint32(),
+				IfType:           rec.uint32(),
+				IfSpeed:          rec.uint64(),
+				IfDirection:      rec.uint32(),
+				IfStatus:         rec.uint32(),
+				InOctets:         rec.uint64(),
+				InUcastPkts:      rec.uint32(),
+				InMulticastPkts:  rec.uint32(),
+				InBroadcastPkts:  rec.uint32(),
+				InDiscards:       rec.uint32(),
+				InErrors:         rec.uint32(),
+				InUnknownProtos:  rec.uint32(),
+				OutOctets:        rec.uint64(),
+				OutUcastPkts:     rec.uint32(),
+				OutMulticastPkts: rec.uint32(),
+				OutBroadcastPkts: rec.uint32(),
+				OutDiscards:      rec.uint32(),
+				OutErrors:        rec.uint32(),
+				PromiscuousMode:  rec.uint32(),
+			}
+		case ethernetIfCountersFormat:
+			cs.EthernetCounters = &EthernetCounters{
+				AlignmentErrors:           rec.uint32(),
+				FCSErrors:                 rec.uint32(),
+				SingleCollisionFrames:     rec.uint32(),
+				MultipleCollisionFrames:   rec.uint32(),
+				SQETestErrors:             rec.uint32(),
+				DeferredTransmissions:     rec.uint32(),
+				LateCollisions:            rec.uint32(),
+				ExcessiveCollisions:       rec.uint32(),
+				InternalMacTransmitErrors: rec.uint32(),
+				CarrierSenseErrors:        rec.uint32(),
+				FrameTooLongs:             rec.uint32(),
+				InternalMacReceiveErrors:  rec.uint32(),
+				SymbolErrors:              rec.uint32(),
+			}
+		default:
+			continue
+		}
+		if rec.err != nil {
+			return nil, rec.err
+		}
+	}
+	if cs.IfCounters == nil && cs.EthernetCounters == nil {
+		return nil, nil
+	}
+	return &Message{Counters: cs}, nil
+}
@@ -0,0 +1,281 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    decoder.go
+ * details: sFlow v5 datagram decoder, every raw packet header of the flow
+ *          samples results in one message in the vflow.sflow JSON shape,
+ *          and every interface counter sample in one counters message
+ *
+ */
+package sflow
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/Juniper/collector/flow-translator/metrics"
+	"github.com/Juniper/collector/flow-translator/packet"
+)
+
+const (
+	// Version is the sFlow version as set in the datagram header
+	Version = 5
+
+	addrTypeIPv4 = 1
+	addrTypeIPv6 = 2
+
+	// sample formats, enterprise 0
+	flowSampleFormat            = 1
+	counterSampleFormat         = 2
+	expandedFlowSampleFormat    = 3
+	expandedCounterSampleFormat = 4
+
+	// flow record formats, enterprise 0
+	rawPacketHeaderFormat = 1
+	extSwitchDataFormat   = 1001
+
+	headerProtocolEthernet = 1
+	headerProtocolIPv4     = 11
+	headerProtocolIPv6     = 12
+)
+
+var skippedSamples = metrics.NewCounter(
+	"flow_translator_sflow_skipped_samples_total",
+	"sFlow samples skipped as they could not be decoded, per agent", "agent")
+
+// DatagramHeader represents the sFlow datagram header
+type DatagramHeader struct {
+	Version    uint32 `json:"Version"`
+	IPVersion  uint32 `json:"IPVersion"`
+	AgentSubID uint32 `json:"AgentSubID"`
+	SequenceNo uint32 `json:"SequenceNo"`
+	SysUpTime  uint32 `json:"SysUpTime"`
+	SamplesNo  uint32 `json:"SamplesNo"`
+	Timestamp  int64  `json:"Timestamp"`
+	IPAddress  string `json:"IPAddress"`
+}
+
+// FlowSample represents the flow sample and expanded flow sample
+type FlowSample struct {
+	SequenceNo   uint32 `json:"SequenceNo"`
+	SourceID     uint32 `json:"SourceID"`
+	SamplingRate uint32 `json:"SamplingRate"`
+	SamplePool   uint32 `json:"SamplePool"`
+	Drops        uint32 `json:"Drops"`
+	Input        uint32 `json:"Input"`
+	Output       uint32 `json:"Output"`
+	RecordsNo    uint32 `json:"RecordsNo"`
+}
+
+// ExtSwitchData represents the extended switch data flow record
+type ExtSwitchData struct {
+	SrcVlan     uint32 `json:"SrcVlan"`
+	SrcPriority uint32 `json:"SrcPriority"`
+	DstVlan     uint32 `json:"DstVlan"`
+	DstPriority uint32 `json:"DstPriority"`
+}
+
+// Message represents one sampled packet, the JSON encoding is the same as
+// the vFlow sFlow messages as received on Kafka. The counters messages only
+// have the Header and the Counters
+type Message struct {
+	Header    *DatagramHeader `json:"Header"`
+	ExtSWData *ExtSwitchData  `json:"ExtSWData,omitempty"`
+	Packet    *packet.Packet  `json:"Packet,omitempty"`
+	Sample    *FlowSample     `json:"Sample,omitempty"`
+	Counters  *CounterSample  `json:"Counters,omitempty"`
+}
+
+// reader reads the XDR encoded sFlow fields
+type reader struct {
+	b   []byte
+	err error
+}
+
+func (r *reader) uint32() uint32 {
+	if r.err != nil {
+		return 0
+	}
+	if len(r.b) < 4 {
+		r.err = fmt.Errorf("Invalid sFlow datagram, truncated")
+		return 0
+	}
+	v := binary.BigEndian.Uint32(r.b[0:4])
+	r.b = r.b[4:]
+	return v
+}
+
+func (r *reader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || len(r.b) < n {
+		r.err = fmt.Errorf("Invalid sFlow datagram, truncated")
+		return nil
+	}
+	v := r.b[:n]
+	r.b = r.b[n:]
+	return v
+}
+
+// opaque reads a length prefixed block, padded to 4 bytes
+func (r *reader) opaque(n int) []byte {
+	v := r.bytes(n)
+	r.bytes((4 - n%4) % 4)
+	return v
+}
+
+// Decode decodes an sFlow v5 datagram received at the received time, one
+// message is returned for every
+// flow sample carrying a raw packet header and for every counter sample
+// carrying interface counters. The samples which can not be decoded are
+// skipped and counted. A datagram without such samples results in a message
+// with only the Header
+func Decode(b []byte, received time.Time) ([]*Message, error) {
+	r := &reader{b: b}
+	hdr := &DatagramHeader{
+		Version:   r.uint32(),
+		IPVersion: r.uint32(),
+		Timestamp: received.UnixNano() / 1000,
+	}
+	if r.err == nil && hdr.Version != Version {
+		return nil, fmt.Errorf("Invalid sFlow version %d", hdr.Version)
+	}
+	switch hdr.IPVersion {
+	case addrTypeIPv4:
+		hdr.IPAddress = net.IP(r.bytes(net.IPv4len)).String()
+	case addrTypeIPv6:
+		hdr.IPAddress = net.IP(r.bytes(net.IPv6len)).String()
+	default:
+		if r.err == nil {
+			return nil, fmt.Errorf("Invalid sFlow agent address type %d",
+				hdr.IPVersion)
+		}
+	}
+	hdr.AgentSubID = r.uint32()
+	hdr.SequenceNo = r.uint32()
+	hdr.SysUpTime = r.uint32()
+	hdr.SamplesNo = r.uint32()
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	var msgs []*Message
+	for i := uint32(0); i < hdr.SamplesNo; i++ {
+		format := r.uint32()
+		sample := &reader{b: r.opaque(int(r.uint32()))}
+		if r.err != nil {
+			return nil, r.err
+		}
+		// enterprise specific samples are skipped
+		if format>>12 != 0 {
+			continue
+		}
+		var (
+			m   *Message
+			err error
+		)
+		switch format & 0xfff {
+		case flowSampleFormat, expandedFlowSampleFormat:
+			m, err = decodeFlowSample(sample, format&0xfff == expandedFlowSampleFormat)
+		case counterSampleFormat, expandedCounterSampleFormat:
+			m, err = decodeCounterSample(sample,
+				format&0xfff == expandedCounterSampleFormat)
+		}
+		if err != nil {
+			// the sample is skipped, its length is known and the next
+			// samples can still be decoded
+			skippedSamples.Inc(hdr.IPAddress)
+			continue
+		}
+		if m != nil {
+			m.Header = hdr
+			msgs = append(msgs, m)
+		}
+	}
+	if len(msgs) == 0 {
+		// for the accounting of the sequence number of the datagram
+		msgs = append(msgs, &Message{Header: hdr})
+	}
+	return msgs, nil
+}
+
+func decodeFlowSample(r *reader, expanded bool) (*Message, error) {
+	fs := &FlowSample{SequenceNo: r.uint32()}
+	if expanded {
+		srcType := r.uint32()
+		fs.SourceID = srcType<<24 | r.uint32()&0x00ffffff
+	} else {
+		fs.SourceID = r.uint32()
+	}
+	fs.SamplingRate = r.uint32()
+	fs.SamplePool = r.uint32()
+	fs.Drops = r.uint32()
+	if expanded {
+		r.uint32() // input format
+		fs.Input = r.uint32()
+		r.uint32() // output format
+		fs.Output = r.uint32()
+	} else {
+		fs.Input = r.uint32()
+		fs.Output = r.uint32()
+	}
+	fs.RecordsNo = r.uint32()
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	m := &Message{Sample: fs}
+	for i := uint32(0); i < fs.RecordsNo; i++ {
+		format := r.uint32()
+		rec := &reader{b: r.opaque(int(r.uint32()))}
+		if r.err != nil {
+			return nil, r.err
+		}
+		if format>>12 != 0 {
+			continue
+		}
+		switch format & 0xfff {
+		case rawPacketHeaderFormat:
+			proto := rec.uint32()
+			rec.uint32() // frame length
+			rec.uint32() // stripped
+			hdr := rec.opaque(int(rec.uint32()))
+			if rec.err != nil {
+				return nil, rec.err
+			}
+			p, err := decodeSampledHeader(proto, hdr)
+			if err != nil {
+				return nil, err
+			}
+			m.Packet = p
+		case extSwitchDataFormat:
+			m.ExtSWData = &ExtSwitchData{
+				SrcVlan:     rec.uint32(),
+				SrcPriority: rec.uint32(),
+				DstVlan:     rec.uint32(),
+				DstPriority: rec.uint32(),
+			}
+			if rec.err != nil {
+				return nil, rec.err
+			}
+		}
+	}
+	if m.Packet == nil {
+		return nil, nil
+	}
+	return m, nil
+}
+
+func decodeSampledHeader(proto uint32, b []byte) (*packet.Packet, error) {
+	switch proto {
+	case headerProtocolEthernet:
+		return packet.DecodeEthernet(b)
+	case headerProtocolIPv4, headerProtocolIPv6:
+		return packet.DecodeIP(b)
+	}
+	// the other header protocols (FDDI, token ring, MPLS...) are skipped
+	return nil, nil
+}
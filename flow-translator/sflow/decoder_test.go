@@ -0,0 +1,256 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    decoder_test.go
+ * details: Deals with the Unit Test cases for the sFlow v5 decoder
+ *
+ */
+package sflow
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+func xdr(values ...uint32) []byte {
+	b := make([]byte, 4*len(values))
+	for i, v := range values {
+		binary.BigEndian.PutUint32(b[4*i:], v)
+	}
+	return b
+}
+
+func opaque(b []byte) []byte {
+	res := append(xdr(uint32(len(b))), b...)
+	return append(res, make([]byte, (4-len(b)%4)%4)...)
+}
+
+func record(format uint32, body []byte) []byte {
+	return append(xdr(format), opaque(body)...)
+}
+
+// tcpFrame is an Ethernet/802.1Q/IPv4/TCP header as sampled by the agent
+// testReceived is the receive time of the test datagrams
+var testReceived = time.Unix(1522108407, 531878000)
+
+var tcpFrame = []byte{
+	0x54, 0xe0, 0x32, 0x88, 0x73, 0x81, 0x00, 0x25, 0x90, 0x94, 0xb4, 0xe6,
+	0x81, 0x00, 0x00, 0x0a, 0x08, 0x00,
+	0x45, 0x00, 0x00, 0x34, 0x92, 0xfa, 0x40, 0x00, 0x40, 0x06, 0x63, 0x30,
+	10, 84, 30, 201, 172, 29, 111, 95,
+	0x23, 0x84, 0xd4, 0xee, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x02,
+	0x80, 0x10, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0xaa,
+}
+
+func buildDatagram(samples ...[]byte) []byte {
+	b := xdr(Version, addrTypeIPv4)
+	b = append(b, net.ParseIP("10.84.30.141").To4()...)
+	b = append(b, xdr(16, 55739, 1104544871, uint32(len(samples)))...)
+	for _, s := range samples {
+		b = append(b, s...)
+	}
+	return b
+}
+
+func buildFlowSample() []byte {
+	rawHeader := append(xdr(headerProtocolEthernet, 1518, 4), opaque(tcpFrame)...)
+	body := xdr(132547, 0, 2560, 1249241330, 0, 505, 0, 2)
+	body = append(body, record(rawPacketHeaderFormat, rawHeader)...)
+	body = append(body, record(extSwitchDataFormat, xdr(10, 0, 20, 0))...)
+	return record(flowSampleFormat, body)
+}
+
+func buildExpandedFlowSample() []byte {
+	ipv6 := make([]byte, 48)
+	ipv6[0] = 0x60
+	binary.BigEndian.PutUint16(ipv6[4:6], 8)
+	ipv6[6] = 17
+	ipv6[7] = 64
+	copy(ipv6[8:24], net.ParseIP("2001:db8::1"))
+	copy(ipv6[24:40], net.ParseIP("2001:db8::2"))
+	binary.BigEndian.PutUint16(ipv6[40:42], 53)
+	binary.BigEndian.PutUint16(ipv6[42:44], 33000)
+	rawHeader := append(xdr(headerProtocolIPv6, 100, 0), opaque(ipv6)...)
+	body := xdr(7, 3, 1024, 100, 5000, 0, 0, 600, 0, 601, 1)
+	body = append(body, record(rawPacketHeaderFormat, rawHeader)...)
+	return record(expandedFlowSampleFormat, body)
+}
+
+func TestDecode(t *testing.T) {
+	counterSample := record(counterSampleFormat, xdr(1, 2, 0))
+	msgs, err := Decode(buildDatagram(buildFlowSample(), counterSample,
+		buildExpandedFlowSample()), testReceived)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if len(msgs) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(msgs))
+	}
+
+	hdr := msgs[0].Header
+	if hdr.IPAddress != "10.84.30.141" || hdr.AgentSubID != 16 ||
+		hdr.SequenceNo != 55739 || hdr.SamplesNo != 3 ||
+		hdr.Timestamp != 1522108407531878 {
+		t.Errorf("unexpected datagram header %+v", hdr)
+	}
+	if msgs[0].Sample.SamplingRate != 2560 || msgs[0].Sample.Input != 505 {
+		t.Errorf("unexpected flow sample %+v", msgs[0].Sample)
+	}
+	if msgs[0].ExtSWData == nil || msgs[0].ExtSWData.DstVlan != 20 {
+		t.Errorf("unexpected extended switch data %+v", msgs[0].ExtSWData)
+	}
+	if msgs[1].Sample.SourceID != 3<<24|1024 || msgs[1].Sample.Output != 601 {
+		t.Errorf("unexpected expanded flow sample %+v", msgs[1].Sample)
+	}
+
+	// the JSON must be in the vflow.sflow shape
+	b, _ := json.Marshal(msgs[0])
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	var m map[string]map[string]interface{}
+	if err := d.Decode(&m); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if _, ok := m["Header"]["Timestamp"].(json.Number); !ok {
+		t.Errorf("Header.Timestamp missing in %s", b)
+	}
+	packet := m["Packet"]
+	l2 := packet["L2"].(map[string]interface{})
+	l3 := packet["L3"].(map[string]interface{})
+	l4 := packet["L4"].(map[string]interface{})
+	expected := []struct {
+		got  interface{}
+		want string
+	}{
+		{l2["SrcMAC"], "00:25:90:94:b4:e6"},
+		{l2["Vlan"], "10"},
+		{l2["EtherType"], "2048"},
+		{l3["TotalLen"], "52"},
+		{l3["Src"], "10.84.30.201"},
+		{l3["Dst"], "172.29.111.95"},
+		{l4["SrcPort"], "9092"},
+		{l4["DstPort"], "54510"},
+		{l4["Flags"], "16"},
+	}
+	for _, e := range expected {
+		if fmt.Sprint(e.got) != e.want {
+			t.Errorf("expected '%v', got '%v' in %s", e.want, e.got, b)
+		}
+	}
+
+	b, _ = json.Marshal(msgs[1])
+	if !bytes.Contains(b, []byte(`"Src":"2001:db8::1"`)) ||
+		!bytes.Contains(b, []byte(`"DstPort":33000`)) {
+		t.Errorf("unexpected IPv6 packet %s", b)
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  []byte
+	}{
+		{name: "Short datagram", msg: xdr(Version)},
+		{name: "Invalid version", msg: xdr(4, addrTypeIPv4, 0)},
+		{name: "Invalid agent address type", msg: xdr(Version, 3, 0)},
+		{name: "Truncated sample", msg: buildDatagram(buildFlowSample())[:60]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Decode(tt.msg, testReceived); err == nil {
+				t.Errorf("%s expected error, got nil", tt.name)
+			}
+		})
+	}
+}
+
+func TestDecodeSkipped(t *testing.T) {
+	// IPv4 header length of 4 bytes
+	badFrame := append([]byte{}, tcpFrame...)
+	badFrame[18] = 0x41
+	rawHeader := append(xdr(headerProtocolEthernet, 1518, 4), opaque(badFrame)...)
+	body := append(xdr(132546, 0, 2560, 1249241330, 0, 505, 0, 1),
+		record(rawPacketHeaderFormat, rawHeader)...)
+	badSample := record(flowSampleFormat, body)
+
+	before := skippedSamples.Value("10.84.30.141")
+	msgs, err := Decode(buildDatagram(badSample, buildFlowSample()), testReceived)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if len(msgs) != 1 || msgs[0].Sample.SequenceNo != 132547 {
+		t.Errorf("expected the message of the valid sample, got %+v", msgs)
+	}
+	if n := skippedSamples.Value("10.84.30.141"); n != before+1 {
+		t.Errorf("expected 1 skipped sample, got %v", n-before)
+	}
+}
+
+func TestDecodeWithoutSamples(t *testing.T) {
+	// the datagram of a bad sample only, kept for its sequence number
+	badFrame := append([]byte{}, tcpFrame...)
+	badFrame[18] = 0x41
+	rawHeader := append(xdr(headerProtocolEthernet, 1518, 4), opaque(badFrame)...)
+	body := append(xdr(132546, 0, 2560, 1249241330, 0, 505, 0, 1),
+		record(rawPacketHeaderFormat, rawHeader)...)
+	msgs, err := Decode(buildDatagram(record(flowSampleFormat, body)),
+		testReceived)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if len(msgs) != 1 || msgs[0].Sample != nil || msgs[0].Counters != nil ||
+		msgs[0].Header == nil {
+		t.Errorf("expected a message with only the header, got %+v", msgs)
+	}
+}
+
+func buildCounterSample(expanded bool) []byte {
+	generic := xdr(505, 6, 0, 1000000000, 1, 3, 0, 4000000000, 100, 2, 1, 7, 8,
+		0, 0, 12345, 200, 0, 0, 9, 10, 0)
+	ethernet := xdr(1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13)
+	body := xdr(42, 505, 2)
+	format := uint32(counterSampleFormat)
+	if expanded {
+		body = xdr(42, 0, 505, 2)
+		format = expandedCounterSampleFormat
+	}
+	body = append(body, record(genericIfCountersFormat, generic)...)
+	body = append(body, record(ethernetIfCountersFormat, ethernet)...)
+	return record(format, body)
+}
+
+func TestDecodeCounters(t *testing.T) {
+	for _, expanded := range []bool{false, true} {
+		msgs, err := Decode(buildDatagram(buildCounterSample(expanded)), testReceived)
+		if err != nil {
+			t.Fatalf("Decode failed: %v", err)
+		}
+		if len(msgs) != 1 || msgs[0].Counters == nil {
+			t.Fatalf("expected a counters message, got %+v", msgs)
+		}
+		cs := msgs[0].Counters
+		if cs.SequenceNo != 42 || cs.SourceID != 505 {
+			t.Errorf("unexpected counter sample %+v", cs)
+		}
+		ifc := cs.IfCounters
+		if ifc == nil || ifc.IfIndex != 505 || ifc.IfSpeed != 1000000000 ||
+			ifc.InOctets != 4000000000 || ifc.InErrors != 8 ||
+			ifc.OutOctets != 12345 || ifc.OutDiscards != 9 {
+			t.Errorf("unexpected interface counters %+v", ifc)
+		}
+		eth := cs.EthernetCounters
+		if eth == nil || eth.AlignmentErrors != 1 || eth.SymbolErrors != 13 {
+			t.Errorf("unexpected ethernet counters %+v", eth)
+		}
+		b, _ := json.Marshal(msgs[0])
+		if bytes.Contains(b, []byte(`"Packet"`)) ||
+			!bytes.Contains(b, []byte(`"IPAddress":"10.84.30.141"`)) {
+			t.Errorf("unexpected counters message %s", b)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    ipfix.go
+ * details: IPFIX collector, decodes the IPFIX messages into the same shape
+ *          as vFlow publishes them on the vflow.ipfix topic
+ *
+ */
+package udplistener
+
+import (
+	"encoding/json"
+	"net"
+	"time"
+
+	"github.com/Juniper/collector/flow-translator/ipfix"
+	opts "github.com/Juniper/collector/flow-translator/options"
+)
+
+func decodeIPFIX(d *ipfix.Decoder) packetDecoder {
+	return func(b []byte, exporter net.IP, received time.Time) ([][]byte, error) {
+		msg, skipped, err := d.Decode(b, exporter, received)
+		if err != nil {
+			return nil, err
+		}
+		if skipped > 0 && opts.Verbose {
+			opts.Logger.Printf("IPFIX %d data sets from %s skipped, template unknown",
+				skipped, msg.AgentID)
+		}
+		// the messages without records are still handed over for the
+		// accounting of their sequence number
+		msg.SkippedSets = skipped
+		out, err := json.Marshal(msg)
+		if err != nil {
+			return nil, err
+		}
+		return [][]byte{out}, nil
+	}
+}
+
+// IPFIXListener receives IPFIX messages on opts.IPFIXListenAddr
+func IPFIXListener() error {
+	d := ipfix.NewDecoder(ipfix.NewTemplateCache())
+	return listen("IPFIX", opts.IPFIXListenAddr,
+		opts.KafkaTopicVFlowIPFIX, decodeIPFIX(d))
+}
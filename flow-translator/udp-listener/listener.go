@@ -0,0 +1,87 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    listener.go
+ * details: UDP listener which receives flow packets directly from the
+ *          exporters and hands the decoded messages to the message handlers
+ *
+ */
+package udplistener
+
+import (
+	"net"
+	"os"
+	"os/signal"
+	"time"
+
+	kc "github.com/Juniper/collector/flow-translator/kafka-consumer"
+	"github.com/Juniper/collector/flow-translator/metrics"
+	msghandler "github.com/Juniper/collector/flow-translator/msg-handler"
+	opts "github.com/Juniper/collector/flow-translator/options"
+)
+
+const maxUDPPacketSize = 65535
+
+// packetDecoder decodes a packet as received from the exporter at the
+// received time into the JSON messages as expected by the message handlers
+type packetDecoder func(b []byte, exporter net.IP,
+	received time.Time) ([][]byte, error)
+
+// listen binds the UDP socket and passes every received packet through
+// decode until an interrupt is received, the decoded messages are handled
+// as if they were received on the given topic
+func listen(name string, addr string, topic string, decode packetDecoder) error {
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		opts.Logger.Fatalf("%s listener invalid address %s: %v", name, addr, err)
+	}
+	conn, err := net.ListenUDP("udp", udpAddr)
+	if err != nil {
+		opts.Logger.Fatalf("%s listener bind error on %s: %v", name, addr, err)
+	}
+	defer conn.Close()
+
+	signalCh := make(chan os.Signal, 1)
+	signal.Notify(signalCh, os.Interrupt)
+
+	opts.Logger.Printf("Starting %s listener on %s", name, addr)
+	kc.StartProducers()
+	metrics.StartServer()
+	msghandler.StartMsgHandlers()
+
+	go func() {
+		buf := make([]byte, maxUDPPacketSize)
+		for {
+			n, raddr, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				opts.Logger.Println(name, "listener read error", err)
+				continue
+			}
+			if opts.Verbose {
+				opts.Logger.Printf("Received %d bytes from %s", n, raddr)
+			}
+			msgs, err := decode(buf[:n], raddr.IP, time.Now())
+			if err != nil {
+				opts.Logger.Printf("%s decode error from %s: %v", name, raddr, err)
+				continue
+			}
+			for _, msg := range msgs {
+				err := msghandler.SendToInChannels(&msghandler.Message{
+					Topic:     topic,
+					Value:     msg,
+					Partition: -1,
+					Offset:    -1,
+					Key:       raddr.IP.String(),
+				})
+				if err != nil {
+					opts.Logger.Println(name, "listener", err)
+				}
+			}
+		}
+	}()
+
+	sig := <-signalCh
+	opts.Logger.Println("Interrupt is detected", sig)
+	opts.Logger.Printf("%s listener Closed", name)
+	return nil
+}
@@ -0,0 +1,39 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    netflow5.go
+ * details: NetFlow v5 collector, decodes the NetFlow v5 packets into the
+ *          shape as expected on the vflow.netflow5 topic
+ *
+ */
+package udplistener
+
+import (
+	"encoding/json"
+	"net"
+	"time"
+
+	"github.com/Juniper/collector/flow-translator/netflow5"
+	opts "github.com/Juniper/collector/flow-translator/options"
+)
+
+func decodeNetflow5(b []byte, exporter net.IP, received time.Time) ([][]byte, error) {
+	msg, err := netflow5.Decode(b, exporter, received)
+	if err != nil {
+		return nil, err
+	}
+	if len(msg.DataSets) == 0 {
+		return nil, nil
+	}
+	out, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	return [][]byte{out}, nil
+}
+
+// Netflow5Listener receives NetFlow v5 packets on opts.Netflow5ListenAddr
+func Netflow5Listener() error {
+	return listen("NetFlow v5", opts.Netflow5ListenAddr,
+		opts.KafkaTopicVFlowNetflow5, decodeNetflow5)
+}
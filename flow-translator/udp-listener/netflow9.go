@@ -0,0 +1,46 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    netflow9.go
+ * details: NetFlow v9 collector, decodes the NetFlow v9 packets into the
+ *          shape as expected on the vflow.netflow9 topic
+ *
+ */
+package udplistener
+
+import (
+	"encoding/json"
+	"net"
+	"time"
+
+	"github.com/Juniper/collector/flow-translator/ipfix"
+	"github.com/Juniper/collector/flow-translator/netflow9"
+	opts "github.com/Juniper/collector/flow-translator/options"
+)
+
+func decodeNetflow9(d *netflow9.Decoder) packetDecoder {
+	return func(b []byte, exporter net.IP, received time.Time) ([][]byte, error) {
+		msg, skipped, err := d.Decode(b, exporter, received)
+		if err != nil {
+			return nil, err
+		}
+		if skipped > 0 && opts.Verbose {
+			opts.Logger.Printf("NetFlow v9 %d flowsets from %s skipped, template unknown",
+				skipped, msg.AgentID)
+		}
+		// the packets without records, e.g. the templates only ones, are
+		// still handed over for the accounting of their sequence number
+		out, err := json.Marshal(msg)
+		if err != nil {
+			return nil, err
+		}
+		return [][]byte{out}, nil
+	}
+}
+
+// Netflow9Listener receives NetFlow v9 packets on opts.Netflow9ListenAddr
+func Netflow9Listener() error {
+	d := netflow9.NewDecoder(ipfix.NewTemplateCache())
+	return listen("NetFlow v9", opts.Netflow9ListenAddr,
+		opts.KafkaTopicVFlowNetflow9, decodeNetflow9(d))
+}
@@ -0,0 +1,162 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    pcap.go
+ * details: Reads the flow packets of a pcap/pcapng file and passes them
+ *          through the same decoders as the UDP listeners
+ *
+ */
+package udplistener
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+
+	"github.com/Juniper/collector/flow-translator/ipfix"
+	kc "github.com/Juniper/collector/flow-translator/kafka-consumer"
+	"github.com/Juniper/collector/flow-translator/metrics"
+	msghandler "github.com/Juniper/collector/flow-translator/msg-handler"
+	"github.com/Juniper/collector/flow-translator/netflow9"
+	opts "github.com/Juniper/collector/flow-translator/options"
+	"github.com/Juniper/collector/flow-translator/pcap"
+)
+
+type pcapDecoder struct {
+	topic  string
+	decode packetDecoder
+}
+
+// newPcapDecoders returns the decoders by name, the template caches are
+// private to the file
+func newPcapDecoders() map[string]pcapDecoder {
+	return map[string]pcapDecoder{
+		opts.DecoderIPFIX: {opts.KafkaTopicVFlowIPFIX,
+			decodeIPFIX(ipfix.NewDecoder(ipfix.NewTemplateCache()))},
+		opts.DecoderNetflow9: {opts.KafkaTopicVFlowNetflow9,
+			decodeNetflow9(netflow9.NewDecoder(ipfix.NewTemplateCache()))},
+		opts.DecoderNetflow5: {opts.KafkaTopicVFlowNetflow5, decodeNetflow5},
+		opts.DecoderSFlow:    {opts.KafkaTopicVFlowSFlow, decodeSFlow},
+	}
+}
+
+// pcapMessage is a decoded message of a pcap file
+type pcapMessage struct {
+	topic    string
+	exporter string
+	value    []byte
+}
+
+// readPcap decodes the UDP datagrams sent to the given ports, ports maps
+// the UDP destination port to the decoder name, handle is called with each
+// decoded message
+func readPcap(r io.Reader, ports map[uint16]string, handle func(pcapMessage)) error {
+	pr, err := pcap.NewReader(r)
+	if err != nil {
+		return err
+	}
+	decoders := newPcapDecoders()
+	for {
+		p, err := pr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		pkt, err := p.Decode()
+		if err != nil {
+			if opts.Verbose {
+				opts.Logger.Printf("pcap packet at %v skipped: %v", p.Timestamp, err)
+			}
+			continue
+		}
+		src, port, payload, ok := pcap.UDPPayload(pkt)
+		if !ok {
+			continue
+		}
+		name, ok := ports[port]
+		if !ok {
+			continue
+		}
+		dec := decoders[name]
+		// the capture time of the packet rather than the time it is read at
+		msgs, err := dec.decode(payload, net.ParseIP(src), p.Timestamp)
+		if err != nil {
+			opts.Logger.Printf("pcap %s decode error from %s: %v", name, src, err)
+			continue
+		}
+		for _, msg := range msgs {
+			handle(pcapMessage{topic: dec.topic, exporter: src, value: msg})
+		}
+	}
+}
+
+// PcapFile decodes the flow packets of a pcap or pcapng file, ports maps the
+// UDP destination port to the decoder name. The decoded messages are written
+// to stdout one per line when toStdout is set, otherwise they are handed to
+// the message handlers and PcapFile returns once they are all handled
+func PcapFile(path string, ports map[uint16]string, toStdout bool) error {
+	for port, name := range ports {
+		if _, ok := newPcapDecoders()[name]; !ok {
+			return fmt.Errorf("Invalid decoder %s for port %d", name, port)
+		}
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("Failed to open %s: %v", path, err)
+	}
+	defer f.Close()
+
+	if toStdout {
+		w := bufio.NewWriter(os.Stdout)
+		defer w.Flush()
+		var werr error
+		err = readPcap(bufio.NewReader(f), ports, func(m pcapMessage) {
+			if werr == nil {
+				_, werr = w.Write(append(m.value, '\n'))
+			}
+		})
+		if err == nil {
+			err = werr
+		}
+		if err != nil {
+			return fmt.Errorf("Failed to read %s: %v", path, err)
+		}
+		return nil
+	}
+
+	opts.Logger.Printf("Reading flow packets of %s", path)
+	kc.StartProducers()
+	metrics.StartServer()
+	msghandler.StartMsgHandlers()
+
+	var wg sync.WaitGroup
+	decoded := 0
+	err = readPcap(bufio.NewReader(f), ports, func(m pcapMessage) {
+		wg.Add(1)
+		err := msghandler.SendToInChannels(&msghandler.Message{
+			Topic:     m.topic,
+			Value:     m.value,
+			Partition: -1,
+			Offset:    -1,
+			Key:       m.exporter,
+			OnAck:     wg.Done,
+		})
+		if err != nil {
+			opts.Logger.Println(err)
+			wg.Done()
+			return
+		}
+		decoded++
+	})
+	wg.Wait()
+	if err != nil {
+		return fmt.Errorf("Failed to read %s: %v", path, err)
+	}
+	opts.Logger.Printf("Decoded %d messages of %s", decoded, path)
+	return nil
+}
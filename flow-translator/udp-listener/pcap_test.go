@@ -0,0 +1,82 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    pcap_test.go
+ * details: Deals with the Unit Test cases for the decoding of the flow
+ *          packets of a pcap file
+ *
+ */
+package udplistener
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+
+	opts "github.com/Juniper/collector/flow-translator/options"
+	"github.com/Juniper/collector/flow-translator/pcap"
+)
+
+// udpFrame returns an Ethernet frame with the UDP datagram from 10.1.1.1
+// to the port
+func udpFrame(port uint16, payload []byte) []byte {
+	udpLen := 8 + len(payload)
+	b := []byte{
+		0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 0x08, 0x00,
+		0x45, 0, byte((20 + udpLen) >> 8), byte(20 + udpLen), 0, 1, 0, 0, 64, 17, 0, 0,
+		10, 1, 1, 1, 10, 2, 2, 2,
+		0x30, 0x39, byte(port >> 8), byte(port), byte(udpLen >> 8), byte(udpLen), 0, 0,
+	}
+	return append(b, payload...)
+}
+
+// pcapFile returns a pcap file of the frames, captured a second apart from
+// 1500000000.5
+func pcapFile(frames ...[]byte) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, []uint32{0xa1b2c3d4, 0x00040002, 0, 0,
+		65535, pcap.LinkTypeEthernet})
+	for i, frame := range frames {
+		binary.Write(&buf, binary.BigEndian, []uint32{1500000000 + uint32(i),
+			500000, uint32(len(frame)), uint32(len(frame))})
+		buf.Write(frame)
+	}
+	return buf.Bytes()
+}
+
+func TestReadPcapTimestamp(t *testing.T) {
+	// the headers only, a NetFlow v9 packet and an sFlow datagram without
+	// any flow or counter sample
+	netflow9 := []byte{0, 9, 0, 0, 0, 0, 0, 1, 0x59, 0x68, 0x2f, 0, 0, 0, 0, 7,
+		0, 0, 0, 1}
+	sflow := []byte{0, 0, 0, 5, 0, 0, 0, 1, 10, 1, 1, 1, 0, 0, 0, 0, 0, 0, 0, 9,
+		0, 0, 0, 1, 0, 0, 0, 0}
+	ports := map[uint16]string{2055: opts.DecoderNetflow9, 6343: opts.DecoderSFlow}
+
+	var msgs []pcapMessage
+	err := readPcap(bytes.NewReader(pcapFile(udpFrame(2055, netflow9),
+		udpFrame(6343, sflow))), ports, func(m pcapMessage) {
+		msgs = append(msgs, m)
+	})
+	if err != nil || len(msgs) != 2 {
+		t.Fatalf("expected 2 messages, got %d: %v", len(msgs), err)
+	}
+
+	// the message timestamps are the capture times of the packets
+	var nf struct{ Timestamp int64 }
+	if err := json.Unmarshal(msgs[0].value, &nf); err != nil ||
+		nf.Timestamp != 1500000000500000 {
+		t.Errorf("expected NetFlow v9 Timestamp 1500000000500000, got %s",
+			msgs[0].value)
+	}
+	var sf struct{ Header struct{ Timestamp int64 } }
+	if err := json.Unmarshal(msgs[1].value, &sf); err != nil ||
+		sf.Header.Timestamp != 1500000001500000 {
+		t.Errorf("expected sFlow Timestamp 1500000001500000, got %s",
+			msgs[1].value)
+	}
+	if msgs[0].topic != opts.KafkaTopicVFlowNetflow9 || msgs[1].exporter != "10.1.1.1" {
+		t.Errorf("unexpected messages %+v", msgs)
+	}
+}
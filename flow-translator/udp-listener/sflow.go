@@ -0,0 +1,40 @@
+/*
+ * Copyright (c) 2018 Juniper Networks, Inc. All rights reserved.
+ *
+ * file:    sflow.go
+ * details: sFlow collector, decodes the sFlow v5 datagrams into the same
+ *          shape as vFlow publishes them on the vflow.sflow topic
+ *
+ */
+package udplistener
+
+import (
+	"encoding/json"
+	"net"
+	"time"
+
+	opts "github.com/Juniper/collector/flow-translator/options"
+	"github.com/Juniper/collector/flow-translator/sflow"
+)
+
+func decodeSFlow(b []byte, exporter net.IP, received time.Time) ([][]byte, error) {
+	msgs, err := sflow.Decode(b, received)
+	if err != nil {
+		return nil, err
+	}
+	res := make([][]byte, 0, len(msgs))
+	for _, msg := range msgs {
+		out, err := json.Marshal(msg)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, out)
+	}
+	return res, nil
+}
+
+// SFlowListener receives sFlow datagrams on opts.SFlowListenAddr
+func SFlowListener() error {
+	return listen("sFlow", opts.SFlowListenAddr,
+		opts.KafkaTopicVFlowSFlow, decodeSFlow)
+}